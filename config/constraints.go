@@ -1,7 +1,9 @@
 package config
 
 import (
+	"cuentas-claras/models"
 	"fmt"
+	"strings"
 )
 
 func AddTransactionConstraints() {
@@ -64,52 +66,60 @@ func AddTransactionConstraints() {
 		`)
 
 		// 3. Consistencia Type + Direction
-		DB.Exec(`
+		// Se recrean para que una BD existente reciba los tipos nuevos
+		inTypes := sqlStringList(models.InTransactionTypes)
+		outTypes := sqlStringList(models.OutTransactionTypes)
+		DB.Exec(`DROP TRIGGER IF EXISTS check_type_direction_insert;`)
+		DB.Exec(`DROP TRIGGER IF EXISTS check_type_direction_update;`)
+		DB.Exec(`DROP TRIGGER IF EXISTS check_valid_type_insert;`)
+		DB.Exec(`DROP TRIGGER IF EXISTS check_valid_type_update;`)
+
+		DB.Exec(fmt.Sprintf(`
 			CREATE TRIGGER IF NOT EXISTS check_type_direction_insert
 			BEFORE INSERT ON transactions
 			FOR EACH ROW
 			WHEN NOT (
-				(NEW.type IN ('income', 'loan_received', 'loan_payment_received') AND NEW.direction = 'in') OR
-				(NEW.type IN ('expense', 'loan_given', 'loan_payment_given') AND NEW.direction = 'out')
+				(NEW.type IN (%s) AND NEW.direction = 'in') OR
+				(NEW.type IN (%s) AND NEW.direction = 'out')
 			)
 			BEGIN
 				SELECT RAISE(ABORT, 'Transaction type and direction are inconsistent');
 			END;
-		`)
+		`, inTypes, outTypes))
 
-		DB.Exec(`
+		DB.Exec(fmt.Sprintf(`
 			CREATE TRIGGER IF NOT EXISTS check_type_direction_update
 			BEFORE UPDATE ON transactions
 			FOR EACH ROW
 			WHEN NOT (
-				(NEW.type IN ('income', 'loan_received', 'loan_payment_received') AND NEW.direction = 'in') OR
-				(NEW.type IN ('expense', 'loan_given', 'loan_payment_given') AND NEW.direction = 'out')
+				(NEW.type IN (%s) AND NEW.direction = 'in') OR
+				(NEW.type IN (%s) AND NEW.direction = 'out')
 			)
 			BEGIN
 				SELECT RAISE(ABORT, 'Transaction type and direction are inconsistent');
 			END;
-		`)
+		`, inTypes, outTypes))
 
 		// 4. Validar tipos permitidos
-		DB.Exec(`
+		DB.Exec(fmt.Sprintf(`
 			CREATE TRIGGER IF NOT EXISTS check_valid_type_insert
 			BEFORE INSERT ON transactions
 			FOR EACH ROW
-			WHEN NEW.type NOT IN ('income', 'expense', 'loan_given', 'loan_received', 'loan_payment_given', 'loan_payment_received')
+			WHEN NEW.type NOT IN (%s, %s)
 			BEGIN
 				SELECT RAISE(ABORT, 'Invalid transaction type');
 			END;
-		`)
+		`, inTypes, outTypes))
 
-		DB.Exec(`
+		DB.Exec(fmt.Sprintf(`
 			CREATE TRIGGER IF NOT EXISTS check_valid_type_update
 			BEFORE UPDATE ON transactions
 			FOR EACH ROW
-			WHEN NEW.type NOT IN ('income', 'expense', 'loan_given', 'loan_received', 'loan_payment_given', 'loan_payment_received')
+			WHEN NEW.type NOT IN (%s, %s)
 			BEGIN
 				SELECT RAISE(ABORT, 'Invalid transaction type');
 			END;
-		`)
+		`, inTypes, outTypes))
 
 		// 5. Amount no puede ser cero
 		DB.Exec(`
@@ -136,33 +146,39 @@ func AddTransactionConstraints() {
 	}
 }
 
-// Constraints para RecurringExpense
-func AddRecurringExpenseConstraints() {
-	// Frecuencias válidas
+// Constraints para RecurringTransaction
+func AddRecurringTransactionConstraints() {
+	// Se recrean sobre recurring_transactions (antes recurring_expenses); la
+	// frecuencia es una RRULE normalizada (empieza con FREQ=)
+	for _, name := range []string{"check_frequency_insert", "check_frequency_update", "check_amount_positive_insert", "check_amount_positive_update"} {
+		DB.Exec(`DROP TRIGGER IF EXISTS ` + name)
+	}
+
 	DB.Exec(`
-		CREATE TRIGGER IF NOT EXISTS check_frequency_insert
-		BEFORE INSERT ON recurring_expenses
+		CREATE TRIGGER check_frequency_insert
+		BEFORE INSERT ON recurring_transactions
 		FOR EACH ROW
-		WHEN NEW.frequency NOT IN ('daily', 'weekly', 'monthly', 'yearly')
+		WHEN NEW.frequency NOT LIKE 'FREQ=%'
 		BEGIN
-			SELECT RAISE(ABORT, 'Frequency must be daily, weekly, monthly, or yearly');
+			SELECT RAISE(ABORT, 'Frequency must be an RRULE starting with FREQ=');
 		END;
 	`)
 
+	// Una fila antigua solo puede actualizarse convirtiéndola a RRULE
 	DB.Exec(`
-		CREATE TRIGGER IF NOT EXISTS check_frequency_update
-		BEFORE UPDATE ON recurring_expenses
+		CREATE TRIGGER check_frequency_update
+		BEFORE UPDATE ON recurring_transactions
 		FOR EACH ROW
-		WHEN NEW.frequency NOT IN ('daily', 'weekly', 'monthly', 'yearly')
+		WHEN NEW.frequency NOT LIKE 'FREQ=%'
 		BEGIN
-			SELECT RAISE(ABORT, 'Frequency must be daily, weekly, monthly, or yearly');
+			SELECT RAISE(ABORT, 'Frequency must be an RRULE starting with FREQ=');
 		END;
 	`)
 
-	// Amount debe ser positivo
+	// Amount debe ser positivo (el signo lo da el tipo al registrar)
 	DB.Exec(`
-		CREATE TRIGGER IF NOT EXISTS check_amount_positive_insert
-		BEFORE INSERT ON recurring_expenses
+		CREATE TRIGGER check_amount_positive_insert
+		BEFORE INSERT ON recurring_transactions
 		FOR EACH ROW
 		WHEN NEW.amount <= 0
 		BEGIN
@@ -171,8 +187,8 @@ func AddRecurringExpenseConstraints() {
 	`)
 
 	DB.Exec(`
-		CREATE TRIGGER IF NOT EXISTS check_amount_positive_update
-		BEFORE UPDATE ON recurring_expenses
+		CREATE TRIGGER check_amount_positive_update
+		BEFORE UPDATE ON recurring_transactions
 		FOR EACH ROW
 		WHEN NEW.amount <= 0
 		BEGIN
@@ -180,5 +196,14 @@ func AddRecurringExpenseConstraints() {
 		END;
 	`)
 
-	fmt.Println("RecurringExpense constraints created successfully")
+	fmt.Println("RecurringTransaction constraints created successfully")
+}
+
+// Lista SQL de strings: 'a', 'b', 'c'
+func sqlStringList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
 }
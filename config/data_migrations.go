@@ -0,0 +1,171 @@
+package config
+
+import (
+	"cuentas-claras/models"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Crear contactos a partir de Loan.PersonName para préstamos sin contacto
+func MigrateLoanContacts() {
+	var loans []models.Loan
+	DB.Where("contact_id IS NULL AND person_name_encrypted <> ''").Find(&loans)
+
+	migrated := 0
+	for _, loan := range loans {
+		contact, err := models.FindOrCreateContact(DB, loan.UserID, loan.PersonName)
+		if err != nil {
+			fmt.Println("Could not migrate loan contact:", loan.ID)
+			continue
+		}
+
+		if err := models.LinkLoansToContact(DB, contact, []uint{loan.ID}); err != nil {
+			fmt.Println("Could not migrate loan contact:", loan.ID)
+			continue
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		fmt.Printf("Migrated %d loans to contacts\n", migrated)
+	}
+}
+
+// Convertir las frecuencias antiguas (daily, weekly, monthly, yearly) a RRULE
+func MigrateRecurringRules() {
+	var templates []models.RecurringTransaction
+	DB.Unscoped().Where("frequency IN ?", models.LegacyFrequencies).Find(&templates)
+
+	migrated := 0
+	for _, recurring := range templates {
+		rule := models.LegacyRecurrence(recurring.Frequency, recurring.StartDate)
+		if err := DB.Unscoped().Model(&recurring).UpdateColumn("frequency", rule).Error; err != nil {
+			fmt.Println("Could not migrate recurring transaction frequency:", recurring.ID)
+			continue
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		fmt.Printf("Migrated %d recurring transactions to RRULE\n", migrated)
+	}
+}
+
+// Los gastos recurrentes pasan a ser plantillas de transacción: conservar sus IDs
+func RenameRecurringExpensesTable() {
+	migrator := DB.Migrator()
+	if !migrator.HasTable("recurring_expenses") || migrator.HasTable("recurring_transactions") {
+		return
+	}
+
+	if err := migrator.RenameTable("recurring_expenses", "recurring_transactions"); err != nil {
+		panic("Failed to rename recurring_expenses: " + err.Error())
+	}
+	fmt.Println("Renamed recurring_expenses to recurring_transactions")
+}
+
+// Actualizar referencias y claves de idempotencia que apuntaban a recurring_expense
+func MigrateRecurringReferences() {
+	result := DB.Exec(`
+		UPDATE transactions
+		SET reference_type = 'recurring_transaction',
+			idempotency_key = CASE WHEN idempotency_key IS NULL THEN NULL
+				ELSE 'recurring_transaction' || SUBSTR(idempotency_key, LENGTH('recurring_expense') + 1) END
+		WHERE reference_type = 'recurring_expense'
+	`)
+	DB.Exec(`
+		UPDATE reminders
+		SET reference_type = CASE WHEN reference_type = 'recurring_expense' THEN 'recurring_transaction' ELSE reference_type END,
+			type = 'recurring_transaction'
+		WHERE type = 'recurring_expense'
+	`)
+
+	if result.RowsAffected > 0 {
+		fmt.Printf("Migrated %d recurring expense transactions\n", result.RowsAffected)
+	}
+}
+
+// Crear el historial de ocurrencias a partir de las transacciones ya registradas.
+// Claves "recurring_transaction:<id>:<fecha>" y sus abonos ":<n>"; las de entrada
+// de transferencias (":in") no cuentan
+func MigrateRecurringOccurrences() {
+	var transactions []models.Transaction
+	DB.Where("idempotency_key LIKE ? AND idempotency_key NOT LIKE ?", "recurring_transaction:%", "%:in").
+		Order("date asc").Find(&transactions)
+
+	// Solo plantillas que aún no tienen historial
+	var withHistory []uint64
+	DB.Model(&models.RecurringOccurrence{}).Distinct().Pluck("recurring_transaction_id", &withHistory)
+	migratedTemplates := map[uint64]bool{}
+	for _, id := range withHistory {
+		migratedTemplates[id] = true
+	}
+
+	type period struct {
+		recurringID uint64
+		day         string
+	}
+	payments := map[period][]models.Transaction{}
+	periods := []period{}
+	for _, transaction := range transactions {
+		parts := strings.Split(*transaction.IdempotencyKey, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		recurringID, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil || migratedTemplates[recurringID] {
+			continue
+		}
+		key := period{recurringID, parts[2]}
+		if _, ok := payments[key]; !ok {
+			periods = append(periods, key)
+		}
+		payments[key] = append(payments[key], transaction)
+	}
+
+	migrated := 0
+	for _, key := range periods {
+		day, err := time.Parse("2006-01-02", key.day)
+		if err != nil {
+			continue
+		}
+
+		var recurring models.RecurringTransaction
+		if err := DB.Unscoped().First(&recurring, key.recurringID).Error; err != nil {
+			continue
+		}
+
+		// La fecha programada conserva la hora de la regla
+		start := recurring.StartDate
+		scheduled := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+
+		occurrence := models.RecurringOccurrence{
+			RecurringTransactionID: recurring.ID,
+			UserID:                 recurring.UserID,
+			ScheduledDate:          scheduled,
+			DueDate:                scheduled,
+			Status:                 models.OccurrencePaid,
+		}
+		for _, transaction := range payments[key] {
+			paidAt := transaction.Date
+			occurrence.PaidAmount = models.RoundMoney(occurrence.PaidAmount + math.Abs(transaction.Amount))
+			occurrence.Payments++
+			occurrence.PaidAt = &paidAt
+			occurrence.TransactionID = &transaction.ID
+		}
+		occurrence.Amount = occurrence.PaidAmount
+
+		if err := DB.Create(&occurrence).Error; err != nil {
+			fmt.Println("Could not migrate recurring occurrence:", key.recurringID, key.day)
+			continue
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		fmt.Printf("Migrated %d recurring occurrences\n", migrated)
+	}
+}
@@ -6,16 +6,39 @@ import (
 )
 
 func RunMigrations() {
+	// Renombrar tablas antes de que AutoMigrate cree las nuevas
+	RenameRecurringExpensesTable()
+
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.DeviceSession{},
 		&models.Account{},
 		&models.Category{},
 		&models.Transaction{},
+		&models.Contact{},
 		&models.Loan{},
 		&models.LoanPayment{},
-		&models.RecurringExpense{},
+		&models.LoanInstallment{},
+		&models.LoanPaymentReversal{},
+		&models.LoanForgiveness{},
+		&models.LoanLink{},
+		&models.LoanShare{},
+		&models.LoanShareAccess{},
+		&models.Group{},
+		&models.GroupMember{},
+		&models.GroupExpense{},
+		&models.GroupExpenseShare{},
+		&models.GroupSettlement{},
+		&models.RecurringTransaction{},
+		&models.RecurringOccurrence{},
+		&models.RecurringSuggestion{},
 		&models.Reminder{}, // ✨ NUEVO
+		&models.NotificationDelivery{},
+		&models.NotificationPreference{},
+		&models.UserEvent{},
+		&models.ScheduledJob{},
+		&models.JobRun{},
+		&models.CalendarFeed{},
 	)
 
 	if err != nil {
@@ -24,7 +47,13 @@ func RunMigrations() {
 
 	// Agregar constraints personalizados para transacciones
 	AddTransactionConstraints()
-	AddRecurringExpenseConstraints()
+	AddRecurringTransactionConstraints()
+
+	// Migrar datos existentes
+	MigrateLoanContacts()
+	MigrateRecurringRules()
+	MigrateRecurringReferences()
+	MigrateRecurringOccurrences()
 
 	fmt.Println("Database migrations completed successfully")
 }
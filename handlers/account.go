@@ -8,20 +8,22 @@ import (
 )
 
 type CreateAccountRequest struct {
-	Name        string `json:"name" validate:"required,min=2,max=100"`
-	Type        string `json:"type" validate:"required,oneof=cash bank credit_card savings"`
-	Currency    string `json:"currency" validate:"required,len=3"`
-	Description string `json:"description,omitempty"`
-	Color       string `json:"color,omitempty"`
-	Icon        string `json:"icon,omitempty"`
+	Name                string  `json:"name" validate:"required,min=2,max=100"`
+	Type                string  `json:"type" validate:"required,oneof=cash bank credit_card savings"`
+	Currency            string  `json:"currency" validate:"required,len=3"`
+	Description         string  `json:"description,omitempty"`
+	Color               string  `json:"color,omitempty"`
+	Icon                string  `json:"icon,omitempty"`
+	LowBalanceThreshold float64 `json:"low_balance_threshold,omitempty" validate:"gte=0"`
 }
 
 type UpdateAccountRequest struct {
-	Name        string `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
-	Description string `json:"description,omitempty"`
-	Color       string `json:"color,omitempty"`
-	Icon        string `json:"icon,omitempty"`
-	IsActive    *bool  `json:"is_active,omitempty"`
+	Name                string   `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
+	Description         string   `json:"description,omitempty"`
+	Color               string   `json:"color,omitempty"`
+	Icon                string   `json:"icon,omitempty"`
+	IsActive            *bool    `json:"is_active,omitempty"`
+	LowBalanceThreshold *float64 `json:"low_balance_threshold,omitempty" validate:"omitempty,gte=0"` // 0 para quitar
 }
 
 func CreateAccount(c *fiber.Ctx) error {
@@ -43,13 +45,14 @@ func CreateAccount(c *fiber.Ctx) error {
 	}
 
 	account := models.Account{
-		UserID:      userID,
-		Name:        req.Name,
-		Type:        req.Type,
-		Currency:    req.Currency,
-		Description: req.Description,
-		Color:       req.Color,
-		Icon:        req.Icon,
+		UserID:              userID,
+		Name:                req.Name,
+		Type:                req.Type,
+		Currency:            req.Currency,
+		Description:         req.Description,
+		Color:               req.Color,
+		Icon:                req.Icon,
+		LowBalanceThreshold: req.LowBalanceThreshold,
 	}
 
 	// Asignar valores por defecto si no se proporcionan
@@ -83,16 +86,17 @@ func GetAccounts(c *fiber.Ctx) error {
 	for i, account := range accounts {
 		balance := account.GetBalance(config.DB)
 		accountsWithBalance[i] = fiber.Map{
-			"id":          account.ID,
-			"name":        account.Name,
-			"type":        account.Type,
-			"currency":    account.Currency,
-			"description": account.Description,
-			"color":       account.Color,
-			"icon":        account.Icon,
-			"is_active":   account.IsActive,
-			"balance":     balance,
-			"created_at":  account.CreatedAt,
+			"id":                    account.ID,
+			"name":                  account.Name,
+			"type":                  account.Type,
+			"currency":              account.Currency,
+			"description":           account.Description,
+			"color":                 account.Color,
+			"icon":                  account.Icon,
+			"is_active":             account.IsActive,
+			"balance":               balance,
+			"created_at":            account.CreatedAt,
+			"low_balance_threshold": account.LowBalanceThreshold,
 		}
 	}
 
@@ -114,17 +118,18 @@ func GetAccount(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{
 		"account": fiber.Map{
-			"id":          account.ID,
-			"name":        account.Name,
-			"type":        account.Type,
-			"currency":    account.Currency,
-			"description": account.Description,
-			"color":       account.Color,
-			"icon":        account.Icon,
-			"is_active":   account.IsActive,
-			"balance":     balance,
-			"created_at":  account.CreatedAt,
-			"updated_at":  account.UpdatedAt,
+			"id":                    account.ID,
+			"name":                  account.Name,
+			"type":                  account.Type,
+			"currency":              account.Currency,
+			"description":           account.Description,
+			"color":                 account.Color,
+			"icon":                  account.Icon,
+			"is_active":             account.IsActive,
+			"balance":               balance,
+			"created_at":            account.CreatedAt,
+			"updated_at":            account.UpdatedAt,
+			"low_balance_threshold": account.LowBalanceThreshold,
 		},
 	})
 }
@@ -169,6 +174,9 @@ func UpdateAccount(c *fiber.Ctx) error {
 	if req.IsActive != nil {
 		account.IsActive = *req.IsActive
 	}
+	if req.LowBalanceThreshold != nil {
+		account.LowBalanceThreshold = *req.LowBalanceThreshold
+	}
 
 	if err := config.DB.Save(&account).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Could not update account"})
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"cuentas-claras/config"
+	"cuentas-claras/i18n"
 	"cuentas-claras/models"
+	"cuentas-claras/services"
 	"cuentas-claras/utils"
 	"time"
 
@@ -18,6 +20,7 @@ type RegisterRequest struct {
 	Email       string `json:"email" validate:"required,email"`
 	Password    string `json:"password" validate:"required,min=6"`
 	PhoneNumber string `json:"phone_number,omitempty"`
+	Locale      string `json:"locale,omitempty" validate:"omitempty,oneof=es-PE en-US"` // Por defecto según Accept-Language
 }
 
 type LoginRequest struct {
@@ -59,6 +62,10 @@ func Register(c *fiber.Ctx) error {
 		Email:       req.Email,
 		Password:    string(hashedPassword),
 		PhoneNumber: req.PhoneNumber,
+		Locale:      req.Locale,
+	}
+	if user.Locale == "" {
+		user.Locale = i18n.Match(c.Get(fiber.HeaderAcceptLanguage))
 	}
 
 	if err := config.DB.Create(&user).Error; err != nil {
@@ -98,6 +105,9 @@ func Login(c *fiber.Ctx) error {
 	}
 
 	// Marcar todas las sesiones anteriores como inactivas (login único)
+	var previousSessionIDs []uint
+	config.DB.Model(&models.DeviceSession{}).Where("user_id = ? AND is_active = true", user.ID).
+		Pluck("id", &previousSessionIDs)
 	config.DB.Model(&models.DeviceSession{}).Where("user_id = ? AND is_active = true", user.ID).
 		Updates(map[string]interface{}{
 			"is_active":     false,
@@ -105,6 +115,13 @@ func Login(c *fiber.Ctx) error {
 			"logout_reason": "New session started",
 		})
 
+	// Avisar a los dispositivos conectados que su sesión terminó
+	for _, sessionID := range previousSessionIDs {
+		services.Events.PublishToSession(user.ID, sessionID, models.EventForceLogout, fiber.Map{
+			"message": "Nueva sesión iniciada desde otro dispositivo",
+		})
+	}
+
 	// Generar tokens
 	refreshTokenID := utils.GenerateRefreshTokenID()
 	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, refreshTokenID)
@@ -145,6 +162,12 @@ func Login(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Could not create device session"})
 	}
 
+	// Aviso de seguridad si este inicio cerró otras sesiones
+	if len(previousSessionIDs) > 0 {
+		reminderService := &services.ReminderService{}
+		reminderService.NotifyNewSession(&deviceSession)
+	}
+
 	return c.JSON(fiber.Map{
 		"access_token":      accessToken,
 		"refresh_token":     refreshToken,
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"cuentas-claras/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Estado del feed de calendario y su URL
+func GetCalendarFeed(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var feed models.CalendarFeed
+	if err := config.DB.Where("user_id = ?", userID).First(&feed).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Calendar feed not enabled"})
+	}
+
+	return c.JSON(fiber.Map{
+		"calendar_feed": feed,
+		"url":           calendarFeedURL(c, feed.Token),
+	})
+}
+
+// Activar el feed o regenerar su token: la URL anterior deja de funcionar
+func RegenerateCalendarFeed(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	token, err := utils.GenerateFeedToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not generate calendar feed"})
+	}
+
+	var feed models.CalendarFeed
+	err = config.DB.Where("user_id = ?", userID).First(&feed).Error
+	created := errors.Is(err, gorm.ErrRecordNotFound)
+	if err != nil && !created {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not generate calendar feed"})
+	}
+
+	feed.UserID = userID
+	feed.SetToken(token)
+	if !created {
+		now := time.Now()
+		feed.RegeneratedAt = &now
+	}
+	if err := config.DB.Save(&feed).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not generate calendar feed"})
+	}
+
+	status, message := 200, "Calendar feed regenerated successfully"
+	if created {
+		status, message = 201, "Calendar feed enabled successfully"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"message":       message,
+		"calendar_feed": feed,
+		"url":           calendarFeedURL(c, token),
+	})
+}
+
+// Desactivar el feed
+func DeleteCalendarFeed(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	result := config.DB.Where("user_id = ?", userID).Delete(&models.CalendarFeed{})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not disable calendar feed"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Calendar feed not enabled"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Calendar feed disabled successfully",
+	})
+}
+
+// Feed .ics público (el token es la autenticación)
+func GetPublicCalendarFeed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+
+	var feed models.CalendarFeed
+	if err := config.DB.Where("token_hash = ?", utils.HashFeedToken(token)).First(&feed).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Calendar feed not found"})
+	}
+	feed.RecordAccess(config.DB)
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="cuentas-claras.ics"`)
+	c.Set(fiber.HeaderCacheControl, "private, max-age=900")
+	return c.SendString(services.BuildCalendarFeed(feed.UserID))
+}
+
+func calendarFeedURL(c *fiber.Ctx, token string) string {
+	return fmt.Sprintf("%s/api/v1/public/calendar/%s.ics", c.BaseURL(), token)
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateContactRequest struct {
+	Name  string `json:"name" validate:"required,min=1,max=100"`
+	Phone string `json:"phone,omitempty" validate:"omitempty,max=30"`
+	Notes string `json:"notes,omitempty" validate:"omitempty,max=500"`
+}
+
+type UpdateContactRequest struct {
+	Name  string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Phone string `json:"phone,omitempty" validate:"omitempty,max=30"`
+	Notes string `json:"notes,omitempty" validate:"omitempty,max=500"`
+}
+
+type MergeContactsRequest struct {
+	ContactIDs []uint `json:"contact_ids" validate:"required,min=1"`
+}
+
+func CreateContact(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req CreateContactRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	// Verificar que no existe un contacto con el mismo nombre (normalizado)
+	var contacts []models.Contact
+	config.DB.Where("user_id = ?", userID).Find(&contacts)
+	for _, existing := range contacts {
+		if models.NormalizeContactName(existing.Name) == models.NormalizeContactName(req.Name) {
+			return c.Status(400).JSON(fiber.Map{"error": "Contact name already exists"})
+		}
+	}
+
+	contact := models.Contact{
+		UserID: userID,
+		Name:   req.Name,
+		Phone:  req.Phone,
+		Notes:  req.Notes,
+	}
+
+	if err := config.DB.Create(&contact).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not create contact"})
+	}
+
+	// Recargar con los campos desencriptados
+	config.DB.First(&contact, contact.ID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Contact created successfully",
+		"contact": contact,
+	})
+}
+
+func GetContacts(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var contacts []models.Contact
+	if err := config.DB.Where("user_id = ?", userID).Find(&contacts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch contacts"})
+	}
+
+	// Ordenar por nombre (encriptado en BD, se ordena en memoria)
+	sort.Slice(contacts, func(i, j int) bool {
+		return models.NormalizeContactName(contacts[i].Name) < models.NormalizeContactName(contacts[j].Name)
+	})
+
+	return c.JSON(fiber.Map{
+		"contacts": contacts,
+	})
+}
+
+func GetContact(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	contactID := c.Params("id")
+
+	var contact models.Contact
+	if err := config.DB.Where("id = ? AND user_id = ?", contactID, userID).First(&contact).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Contact not found"})
+	}
+
+	var loanCount int64
+	config.DB.Model(&models.Loan{}).Where("contact_id = ? AND user_id = ?", contact.ID, userID).Count(&loanCount)
+
+	return c.JSON(fiber.Map{
+		"contact":    contact,
+		"loan_count": loanCount,
+	})
+}
+
+func UpdateContact(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	contactID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Contact not found"})
+	}
+
+	var req UpdateContactRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	contactService := &services.ContactService{}
+	contact, err := contactService.UpdateContact(userID, uint(contactID), services.UpdateContactInput{
+		Name:  req.Name,
+		Phone: req.Phone,
+		Notes: req.Notes,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not update contact")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Contact updated successfully",
+		"contact": contact,
+	})
+}
+
+func DeleteContact(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	contactID := c.Params("id")
+
+	var contact models.Contact
+	if err := config.DB.Where("id = ? AND user_id = ?", contactID, userID).First(&contact).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Contact not found"})
+	}
+
+	// Verificar que no tenga préstamos asociados
+	var loanCount int64
+	config.DB.Model(&models.Loan{}).Where("contact_id = ? AND user_id = ?", contact.ID, userID).Count(&loanCount)
+
+	if loanCount > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot delete contact with loans, merge it instead"})
+	}
+
+	// Soft delete
+	if err := config.DB.Delete(&contact).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not delete contact"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Contact deleted successfully",
+	})
+}
+
+// Fusionar otros contactos en este (sus préstamos pasan a este contacto)
+func MergeContacts(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	contactID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Contact not found"})
+	}
+
+	var req MergeContactsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	contactService := &services.ContactService{}
+	target, sourceIDs, err := contactService.MergeContacts(userID, uint(contactID), req.ContactIDs)
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not merge contacts")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Contacts merged successfully",
+		"contact":    target,
+		"merged_ids": sourceIDs,
+	})
+}
+
+// Libro de préstamos y pagos con un contacto y saldo neto en cada dirección
+func GetContactLedger(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	contactID := c.Params("id")
+
+	var contact models.Contact
+	if err := config.DB.Where("id = ? AND user_id = ?", contactID, userID).First(&contact).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Contact not found"})
+	}
+
+	var loans []models.Loan
+	if err := config.DB.Where("contact_id = ? AND user_id = ?", contact.ID, userID).Order("loan_date asc").Find(&loans).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch loans"})
+	}
+
+	type ledgerEntry struct {
+		Date          time.Time `json:"date"`
+		Kind          string    `json:"kind"` // loan, payment, forgiveness
+		LoanID        uint      `json:"loan_id"`
+		PaymentID     *uint     `json:"payment_id,omitempty"`
+		ForgivenessID *uint     `json:"forgiveness_id,omitempty"`
+		LoanType      string    `json:"loan_type"`
+		Description   string    `json:"description"`
+		Amount        float64   `json:"amount"`
+		Effect        float64   `json:"effect"` // + me deben más, - les debo más
+	}
+
+	entries := []ledgerEntry{}
+	loanSummaries := make([]fiber.Map, len(loans))
+	theyOweMe := 0.0
+	iOweThem := 0.0
+	now := time.Now()
+
+	for i, loan := range loans {
+		snapshot := loan.Snapshot(config.DB, now)
+
+		// Desde mi punto de vista: prestar aumenta lo que me deben, recibir lo que debo
+		sign := 1.0
+		if loan.Type == "received" {
+			sign = -1.0
+			iOweThem += snapshot.PayoffAmount
+		} else {
+			theyOweMe += snapshot.PayoffAmount
+		}
+
+		entries = append(entries, ledgerEntry{
+			Date:        loan.LoanDate,
+			Kind:        "loan",
+			LoanID:      loan.ID,
+			LoanType:    loan.Type,
+			Description: loan.Description,
+			Amount:      loan.Amount,
+			Effect:      sign * loan.Amount,
+		})
+
+		var payments []models.LoanPayment
+		config.DB.Where("loan_id = ? AND transaction_id IS NOT NULL", loan.ID).Find(&payments)
+		for _, payment := range payments {
+			paymentID := payment.ID
+			entries = append(entries, ledgerEntry{
+				Date:        payment.Date,
+				Kind:        "payment",
+				LoanID:      loan.ID,
+				PaymentID:   &paymentID,
+				LoanType:    loan.Type,
+				Description: payment.Description,
+				Amount:      payment.Amount,
+				Effect:      -sign * payment.Amount,
+			})
+		}
+
+		// Lo condonado también reduce el saldo con la contraparte
+		var forgivenesses []models.LoanForgiveness
+		config.DB.Where("loan_id = ?", loan.ID).Find(&forgivenesses)
+		for _, forgiveness := range forgivenesses {
+			forgivenessID := forgiveness.ID
+			entries = append(entries, ledgerEntry{
+				Date:          forgiveness.Date,
+				Kind:          "forgiveness",
+				LoanID:        loan.ID,
+				ForgivenessID: &forgivenessID,
+				LoanType:      loan.Type,
+				Description:   forgiveness.Reason,
+				Amount:        forgiveness.Amount,
+				Effect:        -sign * forgiveness.Amount,
+			})
+		}
+
+		loanSummaries[i] = fiber.Map{
+			"id":                    loan.ID,
+			"type":                  loan.Type,
+			"description":           loan.Description,
+			"amount":                loan.Amount,
+			"status":                loan.Status,
+			"loan_date":             loan.LoanDate,
+			"due_date":              loan.DueDate,
+			"principal_outstanding": snapshot.PrincipalOutstanding,
+			"interest_accrued":      snapshot.InterestAccrued,
+			"balance":               snapshot.PayoffAmount,
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+
+	// Saldo acumulado (sin interés) en cada movimiento
+	running := 0.0
+	ledger := make([]fiber.Map, len(entries))
+	for i, entry := range entries {
+		running += entry.Effect
+		ledger[i] = fiber.Map{
+			"date":            entry.Date,
+			"kind":            entry.Kind,
+			"loan_id":         entry.LoanID,
+			"payment_id":      entry.PaymentID,
+			"forgiveness_id":  entry.ForgivenessID,
+			"loan_type":       entry.LoanType,
+			"description":     entry.Description,
+			"amount":          entry.Amount,
+			"running_balance": models.RoundMoney(running),
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"contact": contact,
+		"loans":   loanSummaries,
+		"entries": ledger,
+		"totals": fiber.Map{
+			"they_owe_me": models.RoundMoney(theyOweMe),
+			"i_owe_them":  models.RoundMoney(iOweThem),
+			"net":         models.RoundMoney(theyOweMe - iOweThem),
+		},
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bufio"
+	"cuentas-claras/services"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Cada cuánto se envía el heartbeat (mantiene viva la conexión detrás de proxies)
+const eventHeartbeatInterval = 25 * time.Second
+
+// Rechazar lo que no sea un upgrade a WebSocket
+func RequireWebSocketUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(426).JSON(fiber.Map{"error": "WebSocket upgrade required"})
+	}
+	return c.Next()
+}
+
+// Eventos en tiempo real por WebSocket (/ws?token=...&last_event_id=...)
+var EventsWebSocket = websocket.New(func(conn *websocket.Conn) {
+	userID, _ := conn.Locals("user_id").(uint)
+	sessionID, _ := conn.Locals("device_session_id").(uint)
+	lastEventID := parseEventID(conn.Query("last_event_id"))
+
+	sub := services.Events.Subscribe(userID, sessionID)
+	defer services.Events.Unsubscribe(sub)
+
+	// Leer solo para detectar el cierre: el cliente no envía nada que haya que procesar
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ended := pumpEvents(sub, lastEventID, closed, func(message services.EventMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(message)
+	})
+
+	if ended {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked"))
+	}
+	conn.Close()
+})
+
+// Eventos en tiempo real por Server-Sent Events, para clientes sin WebSocket.
+// Retoma desde el header Last-Event-ID (o ?last_event_id=)
+func StreamEvents(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	sessionID := c.Locals("device_session_id").(uint)
+
+	lastEventID := parseEventID(c.Get("Last-Event-ID"))
+	if lastEventID == 0 {
+		lastEventID = parseEventID(c.Query("last_event_id"))
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	sub := services.Events.Subscribe(userID, sessionID)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer services.Events.Unsubscribe(sub)
+
+		// Los errores de escritura indican que el cliente se fue
+		fmt.Fprintf(w, "retry: 3000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		pumpEvents(sub, lastEventID, nil, func(message services.EventMessage) error {
+			data, _ := json.Marshal(message)
+			if message.ID != 0 {
+				fmt.Fprintf(w, "id: %d\n", message.ID)
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, data)
+			return w.Flush()
+		})
+	})
+
+	return nil
+}
+
+// Enviar lo pendiente desde lastEventID (si el cliente está retomando) y luego los
+// eventos en vivo con heartbeat. Devuelve true si terminó porque se cerró la sesión
+func pumpEvents(sub *services.EventSubscription, lastEventID uint, closed <-chan struct{}, send func(services.EventMessage) error) bool {
+	if lastEventID > 0 {
+		for _, message := range services.Events.Since(sub.UserID, sub.SessionID, lastEventID) {
+			if err := send(message); err != nil {
+				return false
+			}
+			lastEventID = message.ID
+			if message.EndsSession() {
+				return true
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case message := <-sub.C:
+			if message.ID <= lastEventID {
+				continue // Ya enviado al retomar
+			}
+			if err := send(message); err != nil {
+				return false
+			}
+			lastEventID = message.ID
+			if message.EndsSession() {
+				return true
+			}
+		case <-heartbeat.C:
+			if err := send(services.PingMessage()); err != nil {
+				return false
+			}
+		case <-sub.Done:
+			return false
+		case <-closed:
+			return false
+		}
+	}
+}
+
+func parseEventID(value string) uint {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"cuentas-claras/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Saldo proyectado día a día por cuenta (?days=30&account_id=1&threshold=100)
+func GetForecast(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	days := c.QueryInt("days", 30)
+	if days < 1 || days > 365 {
+		return c.Status(400).JSON(fiber.Map{"error": "Days must be between 1 and 365"})
+	}
+
+	input := services.ForecastInput{Days: days}
+
+	if c.Query("account_id") != "" {
+		accountID := c.QueryInt("account_id")
+		if accountID <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid account_id"})
+		}
+		id := uint(accountID)
+		input.AccountID = &id
+	}
+
+	if c.Query("threshold") != "" {
+		threshold := c.QueryFloat("threshold", -1)
+		if threshold < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Threshold must be zero or positive"})
+		}
+		input.Threshold = &threshold
+	}
+
+	forecastService := &services.ForecastService{}
+	forecast, err := forecastService.Forecast(userID, input)
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not build forecast")
+	}
+
+	return c.JSON(forecast)
+}
@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type GroupMemberRequest struct {
+	Name      string `json:"name,omitempty" validate:"required_without=ContactID,max=100"`
+	ContactID *uint  `json:"contact_id,omitempty"`
+}
+
+type CreateGroupRequest struct {
+	Name        string               `json:"name" validate:"required,min=1,max=100"`
+	Description string               `json:"description,omitempty" validate:"omitempty,max=255"`
+	Currency    string               `json:"currency,omitempty" validate:"omitempty,len=3"`
+	Members     []GroupMemberRequest `json:"members,omitempty" validate:"dive"`
+}
+
+type UpdateGroupRequest struct {
+	Name        string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description string `json:"description,omitempty" validate:"omitempty,max=255"`
+	IsActive    *bool  `json:"is_active,omitempty"`
+}
+
+type GroupExpenseSplitRequest struct {
+	MemberID uint    `json:"member_id" validate:"required"`
+	Value    float64 `json:"value,omitempty" validate:"gte=0"`
+}
+
+type CreateGroupExpenseRequest struct {
+	PaidByMemberID uint                       `json:"paid_by_member_id" validate:"required"`
+	Amount         float64                    `json:"amount" validate:"required,gt=0"`
+	Description    string                     `json:"description" validate:"required,min=1,max=255"`
+	Date           time.Time                  `json:"date" validate:"required"`
+	SplitMethod    string                     `json:"split_method" validate:"required,oneof=equal exact percentage shares"`
+	Splits         []GroupExpenseSplitRequest `json:"splits,omitempty" validate:"dive"`
+}
+
+type CreateGroupSettlementRequest struct {
+	FromMemberID uint       `json:"from_member_id" validate:"required"`
+	ToMemberID   uint       `json:"to_member_id" validate:"required,nefield=FromMemberID"`
+	Amount       float64    `json:"amount" validate:"required,gt=0"`
+	Date         *time.Time `json:"date,omitempty"`
+	AccountID    *uint      `json:"account_id,omitempty"` // Registrar en mi cuenta si participo
+	Notes        string     `json:"notes,omitempty"`
+}
+
+func CreateGroup(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req CreateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	members := make([]services.GroupMemberInput, len(req.Members))
+	for i, member := range req.Members {
+		members[i] = services.GroupMemberInput{Name: member.Name, ContactID: member.ContactID}
+	}
+
+	groupService := &services.GroupService{}
+	group, err := groupService.CreateGroup(userID, services.CreateGroupInput{
+		Name:        req.Name,
+		Description: req.Description,
+		Currency:    req.Currency,
+		Members:     members,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not create group")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Group created successfully",
+		"group":   group,
+	})
+}
+
+func GetGroups(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var groups []models.Group
+	if err := config.DB.Where("user_id = ?", userID).Preload("Members").Order("created_at desc").Find(&groups).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch groups"})
+	}
+
+	// Agregar mi saldo en cada grupo
+	result := make([]fiber.Map, len(groups))
+	for i, group := range groups {
+		balances, _, _ := groupBalances(group.ID)
+		myBalance := 0.0
+		for _, member := range group.Members {
+			if member.IsMe {
+				myBalance = balances[member.ID]
+			}
+		}
+
+		result[i] = fiber.Map{
+			"id":          group.ID,
+			"name":        group.Name,
+			"description": group.Description,
+			"currency":    group.Currency,
+			"is_active":   group.IsActive,
+			"members":     group.Members,
+			"my_balance":  myBalance,
+			"created_at":  group.CreatedAt,
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"groups": result,
+	})
+}
+
+func GetGroup(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	groupID := c.Params("id")
+
+	var group models.Group
+	if err := config.DB.Where("id = ? AND user_id = ?", groupID, userID).
+		Preload("Members").
+		Preload("Expenses", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date desc")
+		}).
+		Preload("Expenses.Shares").
+		First(&group).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	var settlements []models.GroupSettlement
+	config.DB.Where("group_id = ?", group.ID).Order("date desc").Find(&settlements)
+
+	return c.JSON(fiber.Map{
+		"group":       group,
+		"settlements": settlements,
+	})
+}
+
+func UpdateGroup(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	groupID := c.Params("id")
+
+	var req UpdateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	var group models.Group
+	if err := config.DB.Where("id = ? AND user_id = ?", groupID, userID).First(&group).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	// Actualizar campos
+	if req.Name != "" {
+		group.Name = req.Name
+	}
+	if req.Description != "" {
+		group.Description = req.Description
+	}
+	if req.IsActive != nil {
+		group.IsActive = *req.IsActive
+	}
+
+	if err := config.DB.Save(&group).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not update group"})
+	}
+
+	// Recargar con los campos desencriptados
+	config.DB.First(&group, group.ID)
+
+	return c.JSON(fiber.Map{
+		"message": "Group updated successfully",
+		"group":   group,
+	})
+}
+
+func DeleteGroup(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	groupID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	groupService := &services.GroupService{}
+	if err := groupService.DeleteGroup(userID, uint(groupID)); err != nil {
+		return serviceErrorResponse(c, err, "Could not delete group")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Group deleted successfully",
+	})
+}
+
+func AddGroupMember(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	groupID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	var req GroupMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	groupService := &services.GroupService{}
+	member, err := groupService.AddMember(userID, uint(groupID), services.GroupMemberInput{
+		Name:      req.Name,
+		ContactID: req.ContactID,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not add member")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Member added successfully",
+		"member":  member,
+	})
+}
+
+func RemoveGroupMember(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	groupID := c.Params("id")
+	memberID := c.Params("memberId")
+
+	var group models.Group
+	if err := config.DB.Where("id = ? AND user_id = ?", groupID, userID).First(&group).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	var member models.GroupMember
+	if err := config.DB.Where("id = ? AND group_id = ?", memberID, group.ID).First(&member).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Member not found"})
+	}
+
+	if member.IsMe {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot remove yourself from the group"})
+	}
+
+	// Solo se puede quitar a quien no participa en gastos ni liquidaciones
+	var usage int64
+	config.DB.Model(&models.GroupExpense{}).Where("group_id = ? AND paid_by_member_id = ?", group.ID, member.ID).Count(&usage)
+	if usage == 0 {
+		config.DB.Model(&models.GroupExpenseShare{}).
+			Joins("JOIN group_expenses ON group_expenses.id = group_expense_shares.group_expense_id AND group_expenses.deleted_at IS NULL").
+			Where("group_expenses.group_id = ? AND group_expense_shares.member_id = ?", group.ID, member.ID).
+			Count(&usage)
+	}
+	if usage == 0 {
+		config.DB.Model(&models.GroupSettlement{}).
+			Where("group_id = ? AND (from_member_id = ? OR to_member_id = ?)", group.ID, member.ID, member.ID).
+			Count(&usage)
+	}
+	if usage > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot remove member with expenses or settlements"})
+	}
+
+	if err := config.DB.Delete(&member).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not remove member"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Member removed successfully",
+	})
+}
+
+func CreateGroupExpense(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	groupID := c.Params("id")
+
+	var req CreateGroupExpenseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	var group models.Group
+	if err := config.DB.Where("id = ? AND user_id = ?", groupID, userID).Preload("Members").First(&group).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	memberIDs := map[uint]bool{}
+	for _, member := range group.Members {
+		memberIDs[member.ID] = true
+	}
+
+	if !memberIDs[req.PaidByMemberID] {
+		return c.Status(400).JSON(fiber.Map{"error": "Payer is not a member of the group"})
+	}
+
+	// Sin reparto explícito, el gasto igualitario se divide entre todos
+	inputs := make([]models.SplitInput, 0, len(req.Splits))
+	if len(req.Splits) == 0 {
+		if req.SplitMethod != models.SplitEqual {
+			return c.Status(400).JSON(fiber.Map{"error": "Splits are required for this split method"})
+		}
+		for _, member := range group.Members {
+			inputs = append(inputs, models.SplitInput{MemberID: member.ID, Value: 1})
+		}
+	} else {
+		seen := map[uint]bool{}
+		for _, split := range req.Splits {
+			if !memberIDs[split.MemberID] {
+				return c.Status(400).JSON(fiber.Map{"error": "Split member is not a member of the group"})
+			}
+			if seen[split.MemberID] {
+				return c.Status(400).JSON(fiber.Map{"error": "Duplicate member in splits"})
+			}
+			seen[split.MemberID] = true
+			inputs = append(inputs, models.SplitInput{MemberID: split.MemberID, Value: split.Value})
+		}
+	}
+
+	shares, err := models.SplitExpense(req.Amount, req.SplitMethod, inputs)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	expense := models.GroupExpense{
+		GroupID:        group.ID,
+		PaidByMemberID: req.PaidByMemberID,
+		Amount:         models.RoundMoney(req.Amount),
+		Description:    req.Description,
+		Date:           req.Date,
+		SplitMethod:    req.SplitMethod,
+		Shares:         shares,
+	}
+
+	if err := config.DB.Create(&expense).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not create expense"})
+	}
+
+	// Recargar con la descripción desencriptada
+	config.DB.Preload("Shares").First(&expense, expense.ID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Expense created successfully",
+		"expense": expense,
+	})
+}
+
+func DeleteGroupExpense(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	groupID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+	expenseID, err := c.ParamsInt("expenseId")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Expense not found"})
+	}
+
+	groupService := &services.GroupService{}
+	if err := groupService.DeleteExpense(userID, uint(groupID), uint(expenseID)); err != nil {
+		return serviceErrorResponse(c, err, "Could not delete expense")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Expense deleted successfully",
+	})
+}
+
+// Saldos netos, deudas entre pares y transferencias sugeridas para saldar
+func GetGroupBalances(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	groupID := c.Params("id")
+
+	var group models.Group
+	if err := config.DB.Where("id = ? AND user_id = ?", groupID, userID).Preload("Members").First(&group).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	balances, debts, err := groupBalances(group.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not compute balances"})
+	}
+
+	memberBalances := make([]fiber.Map, len(group.Members))
+	for i, member := range group.Members {
+		memberBalances[i] = fiber.Map{
+			"member_id": member.ID,
+			"name":      member.Name,
+			"is_me":     member.IsMe,
+			"balance":   balances[member.ID], // + le deben, - debe
+		}
+	}
+
+	// Orden estable para que la respuesta no cambie entre llamadas
+	sort.Slice(debts, func(i, j int) bool {
+		if debts[i].FromMemberID == debts[j].FromMemberID {
+			return debts[i].ToMemberID < debts[j].ToMemberID
+		}
+		return debts[i].FromMemberID < debts[j].FromMemberID
+	})
+
+	return c.JSON(fiber.Map{
+		"group_id":              group.ID,
+		"currency":              group.Currency,
+		"balances":              memberBalances,
+		"pairwise":              debts,
+		"suggested_settlements": models.SuggestSettlements(balances),
+	})
+}
+
+// Registrar un pago entre integrantes; si participo, puede reflejarse en mi cuenta
+func CreateGroupSettlement(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	groupID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+
+	var req CreateGroupSettlementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	groupService := &services.GroupService{}
+	settlement, err := groupService.CreateSettlement(userID, uint(groupID), services.CreateGroupSettlementInput{
+		FromMemberID: req.FromMemberID,
+		ToMemberID:   req.ToMemberID,
+		Amount:       req.Amount,
+		Date:         req.Date,
+		AccountID:    req.AccountID,
+		Notes:        req.Notes,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not record settlement")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":    "Settlement recorded successfully",
+		"settlement": settlement,
+	})
+}
+
+func DeleteGroupSettlement(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	groupID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Group not found"})
+	}
+	settlementID, err := c.ParamsInt("settlementId")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Settlement not found"})
+	}
+
+	groupService := &services.GroupService{}
+	if err := groupService.DeleteSettlement(userID, uint(groupID), uint(settlementID)); err != nil {
+		return serviceErrorResponse(c, err, "Could not delete settlement")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Settlement deleted successfully",
+	})
+}
+
+// Cargar gastos y liquidaciones de un grupo y calcular sus saldos
+func groupBalances(groupID uint) (map[uint]float64, []models.MemberDebt, error) {
+	var expenses []models.GroupExpense
+	if err := config.DB.Where("group_id = ?", groupID).Preload("Shares").Find(&expenses).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var settlements []models.GroupSettlement
+	if err := config.DB.Where("group_id = ?", groupID).Find(&settlements).Error; err != nil {
+		return nil, nil, err
+	}
+
+	balances, debts := models.ComputeGroupBalances(expenses, settlements)
+	return balances, debts, nil
+}
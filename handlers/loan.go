@@ -3,6 +3,7 @@ package handlers
 import (
 	"cuentas-claras/config"
 	"cuentas-claras/models"
+	"cuentas-claras/services"
 	"cuentas-claras/utils"
 	"time"
 
@@ -13,22 +14,56 @@ type CreateLoanRequest struct {
 	AccountID    uint       `json:"account_id" validate:"required"`
 	Amount       float64    `json:"amount" validate:"required,gt=0"`
 	Description  string     `json:"description" validate:"required,min=1,max=255"`
-	PersonName   string     `json:"person_name" validate:"required,min=1,max=100"`
+	PersonName   string     `json:"person_name,omitempty" validate:"required_without=ContactID,max=100"`
+	ContactID    *uint      `json:"contact_id,omitempty"`
 	Type         string     `json:"type" validate:"required,oneof=given received"`
 	LoanDate     time.Time  `json:"loan_date" validate:"required"`
 	DueDate      *time.Time `json:"due_date,omitempty"`
-	InterestRate float64    `json:"interest_rate,omitempty"`
+	InterestRate float64    `json:"interest_rate,omitempty" validate:"omitempty,gte=0"`
+	InterestType string     `json:"interest_type,omitempty" validate:"omitempty,oneof=simple compound"`
+	RatePeriod   string     `json:"rate_period,omitempty" validate:"omitempty,oneof=monthly annual"`
+	DayCount     string     `json:"day_count,omitempty" validate:"omitempty,oneof=actual/365 actual/360 30/360"`
 	Notes        string     `json:"notes,omitempty"`
+
+	// Plan de cuotas opcional
+	InstallmentCount     int        `json:"installment_count,omitempty" validate:"omitempty,gte=1,lte=600"`
+	InstallmentFrequency string     `json:"installment_frequency,omitempty" validate:"omitempty,oneof=weekly biweekly monthly"`
+	AmortizationMethod   string     `json:"amortization_method,omitempty" validate:"omitempty,oneof=equal_principal french"`
+	FirstInstallmentDate *time.Time `json:"first_installment_date,omitempty"`
 }
 
 type UpdateLoanRequest struct {
 	Description  string     `json:"description,omitempty" validate:"omitempty,min=1,max=255"`
 	PersonName   string     `json:"person_name,omitempty" validate:"omitempty,min=1,max=100"`
+	ContactID    *uint      `json:"contact_id,omitempty"`
 	DueDate      *time.Time `json:"due_date,omitempty"`
-	InterestRate *float64   `json:"interest_rate,omitempty"`
+	InterestRate *float64   `json:"interest_rate,omitempty" validate:"omitempty,gte=0"`
+	InterestType string     `json:"interest_type,omitempty" validate:"omitempty,oneof=simple compound"`
+	RatePeriod   string     `json:"rate_period,omitempty" validate:"omitempty,oneof=monthly annual"`
+	DayCount     string     `json:"day_count,omitempty" validate:"omitempty,oneof=actual/365 actual/360 30/360"`
 	Notes        string     `json:"notes,omitempty"`
 }
 
+type RescheduleLoanRequest struct {
+	InstallmentCount     int        `json:"installment_count" validate:"required,gte=1,lte=600"`
+	InstallmentFrequency string     `json:"installment_frequency,omitempty" validate:"omitempty,oneof=weekly biweekly monthly"`
+	AmortizationMethod   string     `json:"amortization_method,omitempty" validate:"omitempty,oneof=equal_principal french"`
+	FirstInstallmentDate *time.Time `json:"first_installment_date,omitempty"`
+}
+
+type ForgiveLoanRequest struct {
+	Amount     float64    `json:"amount" validate:"required,gt=0"`
+	Date       *time.Time `json:"date,omitempty"`
+	Reason     string     `json:"reason,omitempty" validate:"omitempty,max=500"`
+	CategoryID *uint      `json:"category_id,omitempty"`
+}
+
+type WriteOffLoanRequest struct {
+	Date       *time.Time `json:"date,omitempty"`
+	Reason     string     `json:"reason,omitempty" validate:"omitempty,max=500"`
+	CategoryID *uint      `json:"category_id,omitempty"`
+}
+
 type CreateLoanPaymentRequest struct {
 	AccountID   uint      `json:"account_id" validate:"required"`
 	Amount      float64   `json:"amount" validate:"required,gt=0"`
@@ -37,6 +72,18 @@ type CreateLoanPaymentRequest struct {
 	Notes       string    `json:"notes,omitempty"`
 }
 
+type UpdateLoanPaymentRequest struct {
+	AccountID   *uint      `json:"account_id,omitempty"`
+	Amount      *float64   `json:"amount,omitempty" validate:"omitempty,gt=0"`
+	Date        *time.Time `json:"date,omitempty"`
+	Description string     `json:"description,omitempty" validate:"omitempty,min=1,max=255"`
+	Notes       string     `json:"notes,omitempty"`
+}
+
+type ReverseLoanPaymentRequest struct {
+	Reason string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
 func CreateLoan(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
 
@@ -49,68 +96,30 @@ func CreateLoan(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
 	}
 
-	// Verificar que la cuenta pertenece al usuario
-	var account models.Account
-	if err := config.DB.Where("id = ? AND user_id = ?", req.AccountID, userID).First(&account).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
-	}
-
-	// Crear préstamo
-	loan := models.Loan{
-		UserID:       userID,
-		AccountID:    req.AccountID,
-		Amount:       req.Amount,
-		Description:  req.Description,
-		PersonName:   req.PersonName,
-		Type:         req.Type,
-		LoanDate:     req.LoanDate,
-		DueDate:      req.DueDate,
-		InterestRate: req.InterestRate,
-		Notes:        req.Notes,
-		Status:       "pending",
-	}
-
-	if err := config.DB.Create(&loan).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not create loan"})
-	}
-
-	// Crear transacción automática del préstamo inicial
-	var transactionType string
-	var direction string
-	var amount float64
-
-	if req.Type == "given" {
-		// Préstamo dado: dinero sale de mi cuenta
-		transactionType = "loan_given"
-		direction = "out"
-		amount = -abs(req.Amount)
-	} else {
-		// Préstamo recibido: dinero entra a mi cuenta
-		transactionType = "loan_received"
-		direction = "in"
-		amount = abs(req.Amount)
-	}
-
-	transaction := models.Transaction{
-		UserID:        userID,
-		AccountID:     req.AccountID,
-		Amount:        amount,
-		Direction:     direction,
-		Description:   "Préstamo: " + req.Description,
-		Date:          req.LoanDate,
-		Type:          transactionType,
-		ReferenceID:   &loan.ID,
-		ReferenceType: "loan",
-		Notes:         req.Notes,
-	}
-
-	if err := config.DB.Create(&transaction).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not create transaction"})
+	loanService := &services.LoanService{}
+	loan, err := loanService.CreateLoan(userID, services.CreateLoanInput{
+		AccountID:            req.AccountID,
+		Amount:               req.Amount,
+		Description:          req.Description,
+		PersonName:           req.PersonName,
+		ContactID:            req.ContactID,
+		Type:                 req.Type,
+		LoanDate:             req.LoanDate,
+		DueDate:              req.DueDate,
+		InterestRate:         req.InterestRate,
+		InterestType:         req.InterestType,
+		RatePeriod:           req.RatePeriod,
+		DayCount:             req.DayCount,
+		Notes:                req.Notes,
+		InstallmentCount:     req.InstallmentCount,
+		InstallmentFrequency: req.InstallmentFrequency,
+		AmortizationMethod:   req.AmortizationMethod,
+		FirstInstallmentDate: req.FirstInstallmentDate,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not create loan")
 	}
 
-	// Cargar relaciones para la respuesta
-	config.DB.Preload("Account").First(&loan, loan.ID)
-
 	return c.Status(201).JSON(fiber.Map{
 		"message": "Loan created successfully",
 		"loan":    loan,
@@ -128,10 +137,7 @@ func GetLoans(c *fiber.Ctx) error {
 	// Crear response manualmente
 	loansWithBalance := make([]fiber.Map, len(loans))
 	for i, loan := range loans {
-		// PRIMERO actualizar status
-		loan.UpdateStatus(config.DB)
-
-		// DESPUÉS desencriptar para el response (sin modificar el struct original)
+		// Desencriptar para el response (sin modificar el struct original)
 		description := loan.Description
 		personName := loan.PersonName
 		notes := loan.Notes
@@ -147,7 +153,10 @@ func GetLoans(c *fiber.Ctx) error {
 		}
 
 		totalPaid := loan.GetTotalPaid(config.DB)
-		balance := loan.GetBalance(config.DB)
+		snapshot := loan.Snapshot(config.DB, time.Now())
+
+		var overdueInstallments int64
+		config.DB.Model(&models.LoanInstallment{}).Where("loan_id = ? AND status = ?", loan.ID, "overdue").Count(&overdueInstallments)
 
 		loansWithBalance[i] = fiber.Map{
 			"id":            loan.ID,
@@ -156,17 +165,30 @@ func GetLoans(c *fiber.Ctx) error {
 			"amount":        loan.Amount,
 			"description":   description, // Desencriptado solo para response
 			"person_name":   personName,  // Desencriptado solo para response
+			"contact_id":    loan.ContactID,
 			"type":          loan.Type,
 			"status":        loan.Status,
 			"loan_date":     loan.LoanDate,
 			"due_date":      loan.DueDate,
 			"interest_rate": loan.InterestRate,
+			"interest_type": loan.InterestType,
+			"rate_period":   loan.RatePeriod,
+			"day_count":     loan.DayCount,
 			"notes":         notes, // Desencriptado solo para response
 			"created_at":    loan.CreatedAt,
 			"updated_at":    loan.UpdatedAt,
 			"account":       loan.Account,
 			"total_paid":    totalPaid,
-			"balance":       balance,
+			"balance":       snapshot.PayoffAmount,
+
+			"principal_outstanding": snapshot.PrincipalOutstanding,
+			"interest_accrued":      snapshot.InterestAccrued,
+			"installment_count":     loan.InstallmentCount,
+			"overdue_installments":  overdueInstallments,
+			"linked_loan_id":        loan.LinkedLoanID,
+			"linked_user_id":        loan.LinkedUserID,
+			"disputed_at":           loan.DisputedAt,
+			"dispute_reason":        utils.DecryptField(loan.DisputeReason),
 		}
 	}
 
@@ -180,13 +202,26 @@ func GetLoan(c *fiber.Ctx) error {
 	loanID := c.Params("id")
 
 	var loan models.Loan
-	if err := config.DB.Preload("Account").Preload("Payments.Account").Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
+	if err := config.DB.Preload("Account").Preload("Contact").Preload("Payments.Account").Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
 	}
 
 	totalPaid := loan.GetTotalPaid(config.DB)
-	balance := loan.GetBalance(config.DB)
-	loan.UpdateStatus(config.DB)
+	snapshot := loan.Snapshot(config.DB, time.Now())
+
+	// Recargar pagos y cuotas para incluir el seguimiento actualizado
+	config.DB.Preload("Account").Preload("Reversals").Where("loan_id = ?", loan.ID).Order("date asc").Find(&loan.Payments)
+
+	var forgivenesses []models.LoanForgiveness
+	config.DB.Where("loan_id = ?", loan.ID).Order("date asc").Find(&forgivenesses)
+	config.DB.Where("loan_id = ?", loan.ID).Order("number asc").Find(&loan.Installments)
+
+	overdueInstallments := 0
+	for _, installment := range loan.Installments {
+		if installment.Status == "overdue" {
+			overdueInstallments++
+		}
+	}
 
 	return c.JSON(fiber.Map{
 		"loan": fiber.Map{
@@ -195,25 +230,52 @@ func GetLoan(c *fiber.Ctx) error {
 			"amount":        loan.Amount,
 			"description":   loan.Description,
 			"person_name":   loan.PersonName,
+			"contact_id":    loan.ContactID,
+			"contact":       loan.Contact,
 			"type":          loan.Type,
 			"status":        loan.Status,
 			"loan_date":     loan.LoanDate,
 			"due_date":      loan.DueDate,
 			"interest_rate": loan.InterestRate,
+			"interest_type": loan.InterestType,
+			"rate_period":   loan.RatePeriod,
+			"day_count":     loan.DayCount,
 			"notes":         loan.Notes,
 			"total_paid":    totalPaid,
-			"balance":       balance,
+			"balance":       snapshot.PayoffAmount,
 			"created_at":    loan.CreatedAt,
 			"updated_at":    loan.UpdatedAt,
 			"account":       loan.Account,
 			"payments":      loan.Payments,
+
+			"principal_outstanding": snapshot.PrincipalOutstanding,
+			"interest_accrued":      snapshot.InterestAccrued,
+			"interest_paid":         snapshot.InterestPaid,
+			"principal_paid":        snapshot.PrincipalPaid,
+			"payoff_today":          snapshot.PayoffAmount,
+			"forgiven":              snapshot.Forgiven,
+			"forgivenesses":         forgivenesses,
+
+			"installment_count":     loan.InstallmentCount,
+			"installment_frequency": loan.InstallmentFrequency,
+			"amortization_method":   loan.AmortizationMethod,
+			"installments":          loan.Installments,
+			"overdue_installments":  overdueInstallments,
+			"linked_loan_id":        loan.LinkedLoanID,
+			"linked_user_id":        loan.LinkedUserID,
+			"disputed_at":           loan.DisputedAt,
+			"dispute_reason":        loan.DisputeReason,
 		},
 	})
 }
 
 func UpdateLoan(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	loanID := c.Params("id")
+
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
 
 	var req UpdateLoanRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -224,71 +286,123 @@ func UpdateLoan(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
 	}
 
-	var loan models.Loan
-	if err := config.DB.Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	loanService := &services.LoanService{}
+	loan, err := loanService.UpdateLoan(userID, uint(loanID), services.UpdateLoanInput{
+		Description:  req.Description,
+		PersonName:   req.PersonName,
+		ContactID:    req.ContactID,
+		DueDate:      req.DueDate,
+		InterestRate: req.InterestRate,
+		InterestType: req.InterestType,
+		RatePeriod:   req.RatePeriod,
+		DayCount:     req.DayCount,
+		Notes:        req.Notes,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not update loan")
 	}
 
-	// Actualizar campos
-	if req.Description != "" {
-		loan.Description = req.Description
+	return c.JSON(fiber.Map{
+		"message": "Loan updated successfully",
+		"loan":    loan,
+	})
+}
+
+func DeleteLoan(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
 	}
-	if req.PersonName != "" {
-		loan.PersonName = req.PersonName
+
+	loanService := &services.LoanService{}
+	if err := loanService.DeleteLoan(userID, uint(loanID)); err != nil {
+		return serviceErrorResponse(c, err, "Could not delete loan")
 	}
-	if req.DueDate != nil {
-		loan.DueDate = req.DueDate
+
+	return c.JSON(fiber.Map{
+		"message": "Loan deleted successfully",
+	})
+}
+
+// Reprogramar el saldo pendiente en un nuevo plan de cuotas
+func RescheduleLoan(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
 	}
-	if req.InterestRate != nil {
-		loan.InterestRate = *req.InterestRate
+
+	var req RescheduleLoanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
-	if req.Notes != "" {
-		loan.Notes = req.Notes
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
 	}
 
-	if err := config.DB.Save(&loan).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not update loan"})
+	loanService := &services.LoanService{}
+	installments, err := loanService.RescheduleLoan(userID, uint(loanID), services.RescheduleLoanInput{
+		InstallmentCount:     req.InstallmentCount,
+		InstallmentFrequency: req.InstallmentFrequency,
+		AmortizationMethod:   req.AmortizationMethod,
+		FirstInstallmentDate: req.FirstInstallmentDate,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not reschedule loan")
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Loan updated successfully",
-		"loan":    loan,
+		"message":      "Loan rescheduled successfully",
+		"installments": installments,
 	})
 }
 
-func DeleteLoan(c *fiber.Ctx) error {
+// Perdonar parte del saldo de un préstamo
+func ForgiveLoan(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	loanID := c.Params("id")
 
-	var loan models.Loan
-	if err := config.DB.Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
 	}
 
-	// Verificar que no tenga pagos confirmados
-	var confirmedPayments int64
-	config.DB.Model(&models.LoanPayment{}).Where("loan_id = ? AND transaction_id IS NOT NULL", loanID).Count(&confirmedPayments)
-
-	if confirmedPayments > 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "Cannot delete loan with confirmed payments"})
+	var req ForgiveLoanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	// Soft delete
-	if err := config.DB.Delete(&loan).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not delete loan"})
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Loan deleted successfully",
+	loanService := &services.LoanService{}
+	loan, forgiveness, err := loanService.ForgiveLoan(userID, uint(loanID), req.Amount, services.ForgiveLoanInput{
+		Date:       req.Date,
+		Reason:     req.Reason,
+		CategoryID: req.CategoryID,
 	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not forgive loan")
+	}
+
+	return forgivenessResponse(c, loan, forgiveness)
 }
 
-func CreateLoanPayment(c *fiber.Ctx) error {
+// Dar por perdido todo el saldo pendiente de un préstamo
+func WriteOffLoan(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	loanID := c.Params("id")
 
-	var req CreateLoanPaymentRequest
-	if err := c.BodyParser(&req); err != nil {
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	var req WriteOffLoanRequest
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
@@ -296,43 +410,57 @@ func CreateLoanPayment(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
 	}
 
-	// Verificar que el préstamo existe y pertenece al usuario
-	var loan models.Loan
-	if err := config.DB.Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
+	loanService := &services.LoanService{}
+	loan, forgiveness, err := loanService.WriteOffLoan(userID, uint(loanID), services.ForgiveLoanInput{
+		Date:       req.Date,
+		Reason:     req.Reason,
+		CategoryID: req.CategoryID,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not forgive loan")
+	}
+
+	return forgivenessResponse(c, loan, forgiveness)
+}
+
+func forgivenessResponse(c *fiber.Ctx, loan *models.Loan, forgiveness *models.LoanForgiveness) error {
+	return c.JSON(fiber.Map{
+		"message":     "Loan forgiven successfully",
+		"status":      loan.Status,
+		"balance":     loan.GetBalance(config.DB),
+		"forgiveness": forgiveness,
+	})
+}
+
+func CreateLoanPayment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
 	}
 
-	// Verificar que la cuenta pertenece al usuario
-	var account models.Account
-	if err := config.DB.Where("id = ? AND user_id = ?", req.AccountID, userID).First(&account).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
+	var req CreateLoanPaymentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	// Verificar que el monto no exceda el balance pendiente
-	balance := loan.GetBalance(config.DB)
-	if req.Amount > balance {
-		return c.Status(400).JSON(fiber.Map{"error": "Payment amount exceeds loan balance"})
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
 	}
 
-	// Crear pago (sin confirmar)
-	payment := models.LoanPayment{
-		LoanID:      loan.ID,
-		UserID:      userID,
+	loanService := &services.LoanService{}
+	payment, err := loanService.CreateLoanPayment(userID, uint(loanID), services.CreateLoanPaymentInput{
 		AccountID:   req.AccountID,
 		Amount:      req.Amount,
 		Date:        req.Date,
 		Description: req.Description,
 		Notes:       req.Notes,
-		// TransactionID permanece nil (no confirmado)
-	}
-
-	if err := config.DB.Create(&payment).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not create payment"})
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not create payment")
 	}
 
-	// Cargar relaciones para la respuesta
-	config.DB.Preload("Account").Preload("Loan").First(&payment, payment.ID)
-
 	return c.Status(201).JSON(fiber.Map{
 		"message": "Payment created successfully (pending confirmation)",
 		"payment": payment,
@@ -341,72 +469,127 @@ func CreateLoanPayment(c *fiber.Ctx) error {
 
 func ConfirmLoanPayment(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	paymentID := c.Params("id")
 
-	var payment models.LoanPayment
-	if err := config.DB.Preload("Loan").Preload("Account").Where("id = ? AND user_id = ?", paymentID, userID).First(&payment).Error; err != nil {
+	paymentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Payment not found"})
+	}
+
+	loanService := &services.LoanService{}
+	payment, transaction, err := loanService.ConfirmLoanPayment(userID, uint(paymentID))
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not confirm payment")
+	}
+
+	// Avisar a los demás dispositivos y, si el pago vino de la contraparte, también a ella
+	event := fiber.Map{"payment": payment, "transaction": transaction}
+	services.Events.PublishFrom(userID, c.Locals("device_session_id").(uint), models.EventLoanPaymentConfirmed, event)
+	if linked, err := services.LinkedLoanPayment(config.DB, payment); err == nil && linked != nil {
+		services.Events.Publish(linked.UserID, models.EventLoanPaymentConfirmed, fiber.Map{
+			"payment_id":   linked.ID,
+			"loan_id":      linked.LoanID,
+			"amount":       linked.Amount,
+			"confirmed_by": "counterparty",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":     "Payment confirmed successfully",
+		"payment":     payment,
+		"transaction": transaction,
+	})
+}
+
+// Editar un pago pendiente (aún sin confirmar)
+func UpdateLoanPayment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	paymentID, err := c.ParamsInt("id")
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Payment not found"})
 	}
 
-	// Verificar que no esté ya confirmado
-	if payment.IsConfirmed() {
-		return c.Status(400).JSON(fiber.Map{"error": "Payment already confirmed"})
+	var req UpdateLoanPaymentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	// Determinar tipo de transacción basado en el tipo de préstamo
-	var transactionType string
-	var direction string
-	var amount float64
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	loanService := &services.LoanService{}
+	payment, err := loanService.UpdateLoanPayment(userID, uint(paymentID), services.UpdateLoanPaymentInput{
+		AccountID:   req.AccountID,
+		Amount:      req.Amount,
+		Date:        req.Date,
+		Description: req.Description,
+		Notes:       req.Notes,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not update payment")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Payment updated successfully",
+		"payment": payment,
+	})
+}
+
+// Rechazar (eliminar) un pago pendiente
+func DeleteLoanPayment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
 
-	if payment.Loan.Type == "given" {
-		// Préstamo dado: me están pagando (dinero entra)
-		transactionType = "loan_payment_received"
-		direction = "in"
-		amount = abs(payment.Amount)
-	} else {
-		// Préstamo recibido: estoy pagando (dinero sale)
-		transactionType = "loan_payment_given"
-		direction = "out"
-		amount = -abs(payment.Amount)
+	paymentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Payment not found"})
 	}
 
-	// Crear transacción
-	transaction := models.Transaction{
-		UserID:        userID,
-		AccountID:     payment.AccountID,
-		Amount:        amount,
-		Direction:     direction,
-		Description:   "Pago préstamo: " + payment.Description,
-		Date:          payment.Date,
-		Type:          transactionType,
-		ReferenceID:   &payment.Loan.ID,
-		ReferenceType: "loan",
-		Notes:         payment.Notes,
+	loanService := &services.LoanService{}
+	payment, rejected, err := loanService.DeleteLoanPayment(userID, uint(paymentID))
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not delete payment")
 	}
 
-	if err := config.DB.Create(&transaction).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not create transaction"})
+	// Rechazar un pago que registró la contraparte es un desacuerdo
+	if rejected {
+		return c.JSON(fiber.Map{
+			"message": "Payment rejected, marked as disputed",
+			"payment": payment,
+		})
 	}
 
-	// Actualizar payment con transaction_id
-	payment.TransactionID = &transaction.ID
-	if err := config.DB.Save(&payment).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not update payment"})
+	return c.JSON(fiber.Map{
+		"message": "Payment deleted successfully",
+	})
+}
+
+// Revertir un pago confirmado: anula su transacción y lo devuelve a pendiente
+func ReverseLoanPayment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	paymentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Payment not found"})
 	}
 
-	// Actualizar status del préstamo
-	payment.Loan.UpdateStatus(config.DB)
+	var req ReverseLoanPaymentRequest
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
 
-	// RECARGAR TODO con las relaciones correctas
-	var finalPayment models.LoanPayment
-	config.DB.Preload("Loan.Account").Preload("Account").First(&finalPayment, payment.ID)
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
 
-	var finalTransaction models.Transaction
-	config.DB.Preload("Account").First(&finalTransaction, transaction.ID)
+	loanService := &services.LoanService{}
+	payment, err := loanService.ReverseLoanPayment(userID, uint(paymentID), req.Reason)
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not reverse payment")
+	}
 
 	return c.JSON(fiber.Map{
-		"message":     "Payment confirmed successfully",
-		"payment":     finalPayment,
-		"transaction": finalTransaction,
+		"message": "Payment reversed successfully",
+		"payment": payment,
 	})
 }
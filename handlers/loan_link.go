@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InviteLoanCounterpartyRequest struct {
+	Email   string `json:"email" validate:"required,email"`
+	Message string `json:"message,omitempty" validate:"omitempty,max=500"`
+}
+
+type AcceptLoanInvitationRequest struct {
+	AccountID      uint  `json:"account_id" validate:"required"`
+	ExistingLoanID *uint `json:"existing_loan_id,omitempty"` // Vincular un préstamo que ya registré
+}
+
+type DisputeRequest struct {
+	Reason string `json:"reason" validate:"required,min=1,max=500"`
+}
+
+// Invitar a otro usuario registrado a llevar este préstamo en espejo. La respuesta
+// es la misma exista o no el email, para no revelar quién está registrado
+func InviteLoanCounterparty(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	var req InviteLoanCounterpartyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	loanService := &services.LoanService{}
+	if err := loanService.InviteLoanCounterparty(userID, uint(loanID), req.Email, req.Message); err != nil {
+		return serviceErrorResponse(c, err, "Could not create invitation")
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"message": "If the email belongs to a registered user, they will receive the invitation",
+	})
+}
+
+// Invitaciones recibidas (con el préstamo visto desde mi lado) y enviadas
+func GetLoanInvitations(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var received []models.LoanLink
+	if err := config.DB.Preload("Loan").Preload("Inviter").
+		Where("invitee_id = ?", userID).Order("created_at desc").Find(&received).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch invitations"})
+	}
+
+	var sent []models.LoanLink
+	if err := config.DB.Preload("Invitee").
+		Where("inviter_id = ?", userID).Order("created_at desc").Find(&sent).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch invitations"})
+	}
+
+	receivedResponse := make([]fiber.Map, len(received))
+	for i, link := range received {
+		receivedResponse[i] = fiber.Map{
+			"id":           link.ID,
+			"status":       link.Status,
+			"message":      link.Message,
+			"from":         link.Inviter.Name,
+			"created_at":   link.CreatedAt,
+			"responded_at": link.RespondedAt,
+			"loan": fiber.Map{
+				"type":          models.MirrorLoanType(link.Loan.Type),
+				"amount":        link.Loan.Amount,
+				"description":   link.Loan.Description,
+				"loan_date":     link.Loan.LoanDate,
+				"due_date":      link.Loan.DueDate,
+				"interest_rate": link.Loan.InterestRate,
+			},
+		}
+	}
+
+	sentResponse := make([]fiber.Map, len(sent))
+	for i, link := range sent {
+		sentResponse[i] = fiber.Map{
+			"id":             link.ID,
+			"loan_id":        link.LoanID,
+			"status":         link.Status,
+			"message":        link.Message,
+			"to":             link.Invitee.Email,
+			"mirror_loan_id": link.MirrorLoanID,
+			"created_at":     link.CreatedAt,
+			"responded_at":   link.RespondedAt,
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"received": receivedResponse,
+		"sent":     sentResponse,
+	})
+}
+
+// Aceptar una invitación: crear el préstamo espejo o vincular uno existente
+func AcceptLoanInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	linkID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Invitation not found"})
+	}
+
+	var req AcceptLoanInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	loanService := &services.LoanService{}
+	mirror, err := loanService.AcceptLoanInvitation(userID, uint(linkID), services.AcceptLoanInvitationInput{
+		AccountID:      req.AccountID,
+		ExistingLoanID: req.ExistingLoanID,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not accept invitation")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Invitation accepted successfully",
+		"loan":    mirror,
+	})
+}
+
+// Rechazar una invitación recibida
+func DeclineLoanInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	return respondLoanInvitation(c, "invitee_id = ?", userID, models.LoanLinkDeclined, "Invitation declined successfully")
+}
+
+// Retirar una invitación enviada
+func RevokeLoanInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	return respondLoanInvitation(c, "inviter_id = ?", userID, models.LoanLinkRevoked, "Invitation revoked successfully")
+}
+
+// Cerrar una invitación pendiente con el status indicado
+func respondLoanInvitation(c *fiber.Ctx, ownerClause string, userID uint, status string, message string) error {
+	var link models.LoanLink
+	if err := config.DB.Where("id = ?", c.Params("id")).Where(ownerClause, userID).First(&link).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Invitation not found"})
+	}
+
+	if link.Status != models.LoanLinkPending {
+		return c.Status(400).JSON(fiber.Map{"error": "Invitation is no longer pending"})
+	}
+
+	if err := config.DB.Model(&link).UpdateColumns(map[string]interface{}{
+		"status":       status,
+		"responded_at": time.Now(),
+	}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not update invitation"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+// Marcar un préstamo vinculado en disputa
+func DisputeLoan(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	var req DisputeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	loanService := &services.LoanService{}
+	loan, err := loanService.DisputeLoan(userID, uint(loanID), req.Reason)
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not dispute loan")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Loan disputed successfully",
+		"loan":    loan,
+	})
+}
+
+// Resolver la disputa de un préstamo (en ambos lados)
+func ResolveLoanDispute(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	loanID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	loanService := &services.LoanService{}
+	loan, err := loanService.ResolveLoanDispute(userID, uint(loanID))
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not resolve dispute")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Dispute resolved successfully",
+		"loan":    loan,
+	})
+}
+
+// Marcar un pago vinculado en disputa
+func DisputeLoanPayment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	paymentID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Payment not found"})
+	}
+
+	var req DisputeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	loanService := &services.LoanService{}
+	payment, err := loanService.DisputeLoanPayment(userID, uint(paymentID), req.Reason)
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not dispute payment")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Payment disputed successfully",
+		"payment": payment,
+	})
+}
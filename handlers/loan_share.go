@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"cuentas-claras/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateLoanShareRequest struct {
+	ExpiresInDays       int   `json:"expires_in_days" validate:"omitempty,min=1,max=90"` // Por defecto 30
+	AllowPaymentReports *bool `json:"allow_payment_reports,omitempty"`                   // Por defecto true
+}
+
+// Pago reportado por la contraparte (formulario o JSON)
+type ReportSharedLoanPaymentRequest struct {
+	Amount float64 `json:"amount" form:"amount" validate:"required,gt=0"`
+	Date   string  `json:"date" form:"date" validate:"required"` // YYYY-MM-DD o RFC3339
+	Note   string  `json:"note" form:"note" validate:"omitempty,max=255"`
+}
+
+// Crear un enlace firmado para compartir el estado de cuenta con la contraparte
+func CreateLoanShare(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	loanID := c.Params("id")
+
+	var req CreateLoanShareRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	var loan models.Loan
+	if err := config.DB.Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	expiresInDays := req.ExpiresInDays
+	if expiresInDays == 0 {
+		expiresInDays = 30
+	}
+
+	allowPaymentReports := true
+	if req.AllowPaymentReports != nil {
+		allowPaymentReports = *req.AllowPaymentReports
+	}
+
+	share := models.LoanShare{
+		LoanID:              loan.ID,
+		UserID:              userID,
+		ShareID:             utils.GenerateRefreshTokenID(),
+		ExpiresAt:           time.Now().AddDate(0, 0, expiresInDays),
+		AllowPaymentReports: allowPaymentReports,
+	}
+
+	if err := config.DB.Create(&share).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not create share link"})
+	}
+
+	// El default de GORM reemplaza false al crear
+	if !allowPaymentReports {
+		config.DB.Model(&share).UpdateColumn("allow_payment_reports", false)
+		share.AllowPaymentReports = false
+	}
+
+	token, err := utils.GenerateLoanShareToken(share.ShareID, share.LoanID, share.ExpiresAt)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not generate share token"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Share link created successfully",
+		"share":   share,
+		"token":   token,
+		"url":     loanShareURL(c, token),
+	})
+}
+
+// Enlaces del préstamo (el token se vuelve a firmar para los vigentes)
+func GetLoanShares(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	loanID := c.Params("id")
+
+	var loan models.Loan
+	if err := config.DB.Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	var shares []models.LoanShare
+	config.DB.Where("loan_id = ?", loan.ID).Order("created_at desc").Find(&shares)
+
+	result := make([]fiber.Map, 0, len(shares))
+	for _, share := range shares {
+		item := fiber.Map{
+			"share":  share,
+			"status": "active",
+		}
+		if reason := share.DenyReason(); reason != "" {
+			item["status"] = reason
+		} else if token, err := utils.GenerateLoanShareToken(share.ShareID, share.LoanID, share.ExpiresAt); err == nil {
+			item["url"] = loanShareURL(c, token)
+		}
+		result = append(result, item)
+	}
+
+	return c.JSON(fiber.Map{"shares": result})
+}
+
+// Revocar un enlace: deja de funcionar aunque no haya vencido
+func RevokeLoanShare(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	share, err := findOwnedLoanShare(c, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Share link not found"})
+	}
+
+	if share.IsRevoked() {
+		return c.Status(400).JSON(fiber.Map{"error": "Share link already revoked"})
+	}
+
+	now := time.Now()
+	if err := config.DB.Model(share).UpdateColumn("revoked_at", now).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not revoke share link"})
+	}
+	share.RevokedAt = &now
+
+	return c.JSON(fiber.Map{
+		"message": "Share link revoked successfully",
+		"share":   share,
+	})
+}
+
+// Historial de accesos de un enlace (incluidos los rechazados)
+func GetLoanShareAccesses(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	share, err := findOwnedLoanShare(c, userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Share link not found"})
+	}
+
+	var accesses []models.LoanShareAccess
+	config.DB.Where("loan_share_id = ?", share.ID).Order("created_at desc").Find(&accesses)
+
+	return c.JSON(fiber.Map{
+		"share":    share,
+		"accesses": accesses,
+	})
+}
+
+// Estado de cuenta público (sin cuentas ni notas privadas)
+func GetSharedLoanStatement(c *fiber.Ctx) error {
+	share, err := findLoanShareByToken(c.Params("token"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Share link not found"})
+	}
+
+	format := c.Query("format", "html")
+	if format != "html" && format != "pdf" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid format, use html or pdf"})
+	}
+
+	access := loanShareAccess(c, "view_statement")
+	if reason := share.DenyReason(); reason != "" {
+		access.Reason = reason
+		share.RecordAccess(config.DB, access)
+		return c.Status(410).JSON(fiber.Map{"error": "Share link " + reason})
+	}
+
+	var loan models.Loan
+	if err := config.DB.First(&loan, share.LoanID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	access.Allowed = true
+	share.RecordAccess(config.DB, access)
+
+	opts := services.LoanStatementOptions{
+		HidePrivate:     true,
+		PaymentReported: c.QueryBool("reported", false),
+	}
+	if share.AllowPaymentReports && !loan.IsClosed() {
+		opts.PaymentReportURL = c.Path() + "/payments"
+	}
+	return writeLoanStatement(c, &loan, format, opts)
+}
+
+// La contraparte reporta "pagué X el día Y": queda pendiente hasta que yo lo confirme
+func ReportSharedLoanPayment(c *fiber.Ctx) error {
+	share, err := findLoanShareByToken(c.Params("token"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Share link not found"})
+	}
+
+	access := loanShareAccess(c, "report_payment")
+	reason := share.DenyReason()
+	if reason == "" && !share.AllowPaymentReports {
+		reason = "reports_disabled"
+	}
+	if reason != "" {
+		access.Reason = reason
+		share.RecordAccess(config.DB, access)
+		if reason == "reports_disabled" {
+			return c.Status(403).JSON(fiber.Map{"error": "Payment reports are disabled for this link"})
+		}
+		return c.Status(410).JSON(fiber.Map{"error": "Share link " + reason})
+	}
+
+	// Los intentos rechazados también quedan registrados
+	reject := func(status int, message string) error {
+		access.Reason = "invalid_request"
+		share.RecordAccess(config.DB, access)
+		return c.Status(status).JSON(fiber.Map{"error": message})
+	}
+
+	var req ReportSharedLoanPaymentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return reject(400, "Invalid request")
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return reject(400, "Validation failed")
+	}
+
+	date, err := parseReportedDate(req.Date)
+	if err != nil {
+		return reject(400, "Invalid date, use YYYY-MM-DD")
+	}
+	if date.After(time.Now()) {
+		return reject(400, "Payment date cannot be in the future")
+	}
+
+	loanService := &services.LoanService{}
+	payment, err := loanService.ReportLoanPayment(share.LoanID, services.ReportLoanPaymentInput{
+		ShareID: share.ID,
+		Amount:  req.Amount,
+		Date:    date,
+		Note:    req.Note,
+	})
+	var serviceErr *services.ServiceError
+	if errors.As(err, &serviceErr) {
+		return reject(serviceErr.Status, serviceErr.Message)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not report payment"})
+	}
+
+	access.Allowed = true
+	access.LoanPaymentID = &payment.ID
+	share.RecordAccess(config.DB, access)
+
+	// Desde el formulario HTML, volver al estado de cuenta con el aviso
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationForm) {
+		return c.Redirect(strings.TrimSuffix(c.Path(), "/payments")+"?reported=1", fiber.StatusSeeOther)
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Payment reported successfully (pending confirmation)",
+		"payment": fiber.Map{
+			"id":     payment.ID,
+			"amount": payment.Amount,
+			"date":   payment.Date,
+		},
+	})
+}
+
+// Enlace del usuario a partir de :id (préstamo) y :shareId
+func findOwnedLoanShare(c *fiber.Ctx, userID uint) (*models.LoanShare, error) {
+	var share models.LoanShare
+	err := config.DB.Where("id = ? AND loan_id = ? AND user_id = ?", c.Params("shareId"), c.Params("id"), userID).
+		First(&share).Error
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// Enlace a partir del token firmado (vencido o revocado se decide después)
+func findLoanShareByToken(token string) (*models.LoanShare, error) {
+	shareID, err := utils.ValidateLoanShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var share models.LoanShare
+	if err := config.DB.Where("share_id = ?", shareID).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// Datos del visitante para el registro de accesos
+func loanShareAccess(c *fiber.Ctx, action string) models.LoanShareAccess {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return models.LoanShareAccess{
+		Action:    action,
+		IPAddress: c.IP(),
+		UserAgent: userAgent,
+	}
+}
+
+// URL pública del estado de cuenta compartido
+func loanShareURL(c *fiber.Ctx, token string) string {
+	return fmt.Sprintf("%s/api/v1/public/loan-shares/%s", c.BaseURL(), token)
+}
+
+// Fecha del formulario (YYYY-MM-DD) o de un cliente JSON (RFC3339)
+func parseReportedDate(value string) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
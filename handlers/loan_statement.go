@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Estado de cuenta imprimible del préstamo (?format=html|pdf&hide_private=true)
+func GetLoanStatement(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	loanID := c.Params("id")
+
+	format := c.Query("format", "html")
+	if format != "html" && format != "pdf" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid format, use html or pdf"})
+	}
+
+	var loan models.Loan
+	if err := config.DB.Where("id = ? AND user_id = ?", loanID, userID).First(&loan).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Loan not found"})
+	}
+
+	opts := services.LoanStatementOptions{HidePrivate: c.QueryBool("hide_private", false)}
+	return writeLoanStatement(c, &loan, format, opts)
+}
+
+// Generar el estado de cuenta y enviarlo en el formato pedido
+func writeLoanStatement(c *fiber.Ctx, loan *models.Loan, format string, opts services.LoanStatementOptions) error {
+	loanService := &services.LoanService{}
+	statement, err := loanService.BuildStatement(loan, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not build statement"})
+	}
+
+	var buf bytes.Buffer
+	if format == "pdf" {
+		if err := services.RenderLoanStatementPDF(&buf, statement); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Could not render statement"})
+		}
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="estado-prestamo-%d.pdf"`, loan.ID))
+		return c.Send(buf.Bytes())
+	}
+
+	if err := services.RenderLoanStatementHTML(&buf, statement); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not render statement"})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(buf.Bytes())
+}
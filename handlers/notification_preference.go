@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UpdateNotificationPreferenceRequest struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	Push    *bool `json:"push,omitempty"`
+	Email   *bool `json:"email,omitempty"`
+	Webhook *bool `json:"webhook,omitempty"`
+	InApp   *bool `json:"in_app,omitempty"`
+
+	// Solo recurring_due y loan_due: días antes del vencimiento (0 = el mismo día)
+	LeadDays []int `json:"lead_days,omitempty" validate:"omitempty,max=5,dive,gte=0,lte=30"`
+
+	// Solo overdue
+	RepeatEveryDays *int   `json:"repeat_every_days,omitempty" validate:"omitempty,gte=0,lte=30"` // 0 = avisar una sola vez
+	StartPriority   string `json:"start_priority,omitempty" validate:"omitempty,oneof=low normal high urgent"`
+	EscalateEvery   *int   `json:"escalate_every,omitempty" validate:"omitempty,gte=0,lte=30"` // 0 = sin escalamiento
+}
+
+// Preferencias de todos los tipos de notificación (las por defecto si no se cambiaron)
+func GetNotificationPreferences(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	preferences := []fiber.Map{}
+	for _, notificationType := range models.NotificationTypes {
+		preference := models.LoadNotificationPreference(config.DB, userID, notificationType)
+		preferences = append(preferences, notificationPreferenceResponse(&preference))
+	}
+
+	return c.JSON(fiber.Map{"preferences": preferences})
+}
+
+// Cambiar las preferencias de un tipo
+func UpdateNotificationPreference(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	notificationType, ok := notificationTypeParam(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid notification type: use one of " + strings.Join(models.NotificationTypes, ", ")})
+	}
+
+	var req UpdateNotificationPreferenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	preference := models.LoadNotificationPreference(config.DB, userID, notificationType)
+
+	if req.LeadDays != nil {
+		if !preference.UsesLeadDays() {
+			return c.Status(400).JSON(fiber.Map{"error": "lead_days only apply to recurring_due and loan_due"})
+		}
+		if len(req.LeadDays) == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "lead_days needs at least one day; set enabled to false to turn reminders off"})
+		}
+	}
+	if (req.RepeatEveryDays != nil || req.StartPriority != "" || req.EscalateEvery != nil) && !preference.UsesEscalation() {
+		return c.Status(400).JSON(fiber.Map{"error": "repeat_every_days, start_priority and escalate_every only apply to overdue"})
+	}
+
+	// Actualizar campos si están presentes
+	if req.Enabled != nil {
+		preference.Enabled = *req.Enabled
+	}
+	if req.Push != nil {
+		preference.Push = *req.Push
+	}
+	if req.Email != nil {
+		preference.Email = *req.Email
+	}
+	if req.Webhook != nil {
+		preference.Webhook = *req.Webhook
+	}
+	if req.InApp != nil {
+		preference.InApp = *req.InApp
+	}
+	if req.LeadDays != nil {
+		preference.SetLeadDays(req.LeadDays)
+	}
+	if req.RepeatEveryDays != nil {
+		preference.RepeatEveryDays = req.RepeatEveryDays
+	}
+	if req.StartPriority != "" {
+		preference.StartPriority = req.StartPriority
+	}
+	if req.EscalateEvery != nil {
+		preference.EscalateEvery = *req.EscalateEvery
+	}
+
+	if err := config.DB.Save(&preference).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not update notification preference"})
+	}
+
+	rescheduleForPreference(userID, notificationType)
+
+	return c.JSON(fiber.Map{
+		"message":    "Notification preference updated successfully",
+		"preference": notificationPreferenceResponse(&preference),
+	})
+}
+
+// Volver a los valores por defecto de un tipo
+func ResetNotificationPreference(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	notificationType, ok := notificationTypeParam(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid notification type: use one of " + strings.Join(models.NotificationTypes, ", ")})
+	}
+
+	if err := config.DB.Where("user_id = ? AND type = ?", userID, notificationType).Delete(&models.NotificationPreference{}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not reset notification preference"})
+	}
+
+	rescheduleForPreference(userID, notificationType)
+
+	preference := models.DefaultNotificationPreference(userID, notificationType)
+	return c.JSON(fiber.Map{
+		"message":    "Notification preference reset successfully",
+		"preference": notificationPreferenceResponse(&preference),
+	})
+}
+
+func notificationTypeParam(c *fiber.Ctx) (string, bool) {
+	notificationType := c.Params("type")
+	for _, known := range models.NotificationTypes {
+		if known == notificationType {
+			return notificationType, true
+		}
+	}
+	return "", false
+}
+
+// Los recordatorios de vencimiento pendientes se crearon con los días de aviso anteriores
+func rescheduleForPreference(userID uint, notificationType string) {
+	if notificationType == models.NotifyRecurringDue || notificationType == models.NotifyLoanDue {
+		reminderService := &services.ReminderService{}
+		reminderService.RescheduleRemindersForUser(userID)
+	}
+}
+
+// Solo los campos que aplican al tipo
+func notificationPreferenceResponse(preference *models.NotificationPreference) fiber.Map {
+	response := fiber.Map{
+		"type":    preference.Type,
+		"enabled": preference.Enabled,
+		"channels": fiber.Map{
+			"push":    preference.Push,
+			"email":   preference.Email,
+			"webhook": preference.Webhook,
+			"in_app":  preference.InApp,
+		},
+	}
+	if preference.UsesLeadDays() {
+		response["lead_days"] = preference.LeadDayList()
+	}
+	if preference.UsesEscalation() {
+		response["repeat_every_days"] = preference.RepeatEveryDays // null = por defecto (1 en recurrentes, 3 en préstamos)
+		response["start_priority"] = preference.StartPriority
+		response["escalate_every"] = preference.EscalateEvery
+	}
+	return response
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SkipOccurrenceRequest struct {
+	Note string `json:"note,omitempty" validate:"omitempty,max=255"` // Motivo
+}
+
+type PostponeOccurrenceRequest struct {
+	Date time.Time `json:"date" validate:"required"` // Nueva fecha de vencimiento
+	Note string    `json:"note,omitempty" validate:"omitempty,max=255"`
+}
+
+// Omitir la ocurrencia pendiente sin registrar movimiento
+func SkipRecurringTransaction(c *fiber.Ctx) error {
+	return skipRecurringOccurrence(c, recurringTransactionsRoute)
+}
+
+// Posponer la ocurrencia pendiente (antes del siguiente vencimiento)
+func PostponeRecurringTransaction(c *fiber.Ctx) error {
+	return postponeRecurringOccurrence(c, recurringTransactionsRoute)
+}
+
+// Historial de ocurrencias de una plantilla (?status=paid)
+func GetRecurringTransactionOccurrences(c *fiber.Ctx) error {
+	return getRecurringOccurrences(c, recurringTransactionsRoute)
+}
+
+// Pagos a tiempo vs atrasados de todas las plantillas (?months=12)
+func GetRecurringTransactionsAdherence(c *fiber.Ctx) error {
+	return getRecurringAdherence(c, recurringTransactionsRoute)
+}
+
+func skipRecurringOccurrence(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	recurring, err := findRecurringTransaction(config.DB, userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	var req SkipOccurrenceRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+		}
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	recurringTransactionService := &services.RecurringTransactionService{}
+	updated, occurrence, err := recurringTransactionService.Skip(userID, recurring.ID, req.Note)
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not skip occurrence")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "Occurrence skipped successfully",
+		"occurrence":    occurrence,
+		"next_due_date": updated.NextDueDate,
+		"is_active":     updated.IsActive,
+		route.single:    updated.ID,
+	})
+}
+
+func postponeRecurringOccurrence(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	recurring, err := findRecurringTransaction(config.DB, userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	var req PostponeOccurrenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	recurringTransactionService := &services.RecurringTransactionService{}
+	updated, occurrence, err := recurringTransactionService.Postpone(userID, recurring.ID, req.Date, req.Note)
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not postpone occurrence")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "Occurrence postponed successfully",
+		"occurrence":    occurrence,
+		"next_due_date": updated.NextDueDate,
+		route.single:    updated.ID,
+	})
+}
+
+func getRecurringOccurrences(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	status := c.Query("status")
+	if status != "" && !models.IsOccurrenceStatus(status) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid status: use due, paid, partially_paid, skipped or postponed"})
+	}
+
+	recurring, err := findRecurringTransaction(config.DB, userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	var occurrences []models.RecurringOccurrence
+	err = config.DB.Where("recurring_transaction_id = ?", recurring.ID).
+		Preload("Transaction.Account").Order("scheduled_date desc").Find(&occurrences).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch occurrences"})
+	}
+
+	// Resumen del último año (incluye el periodo pendiente aunque aún no tenga registro)
+	now := time.Now()
+	loc := models.UserLocation(config.DB, userID)
+	since := now.AddDate(-1, 0, 0)
+	var summary models.AdherenceSummary
+	for i := range occurrences {
+		if !occurrences[i].ScheduledDate.Before(since) && !occurrences[i].ScheduledDate.After(now) {
+			summary.Add(&occurrences[i], now, loc)
+		}
+	}
+	if pending := pendingOccurrence(recurring, occurrences); pending != nil && !pending.ScheduledDate.After(now) {
+		summary.Add(pending, now, loc)
+	}
+	summary.Finish()
+
+	// El filtro por estado solo afecta a la lista, no al resumen
+	if status != "" {
+		filtered := []models.RecurringOccurrence{}
+		for _, occurrence := range occurrences {
+			if occurrence.Status == status {
+				filtered = append(filtered, occurrence)
+			}
+		}
+		occurrences = filtered
+	}
+
+	return c.JSON(fiber.Map{
+		"occurrences": occurrences,
+		"adherence":   summary,
+	})
+}
+
+func getRecurringAdherence(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	months := c.QueryInt("months", 12)
+	if months < 1 || months > 36 {
+		return c.Status(400).JSON(fiber.Map{"error": "Months must be between 1 and 36"})
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, -months, 0)
+	loc := models.UserLocation(config.DB, userID)
+
+	var templates []models.RecurringTransaction
+	if err := scopeRecurringTransactions(config.DB.Where("user_id = ?", userID), route).Find(&templates).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch " + strings.ToLower(route.label) + "s"})
+	}
+
+	ids := make([]uint, len(templates))
+	for i := range templates {
+		ids[i] = templates[i].ID
+	}
+
+	var occurrences []models.RecurringOccurrence
+	config.DB.Where("recurring_transaction_id IN ? AND scheduled_date >= ? AND scheduled_date <= ?", ids, since, now).
+		Find(&occurrences)
+
+	byTemplate := map[uint][]models.RecurringOccurrence{}
+	for _, occurrence := range occurrences {
+		byTemplate[occurrence.RecurringTransactionID] = append(byTemplate[occurrence.RecurringTransactionID], occurrence)
+	}
+
+	var overall models.AdherenceSummary
+	result := []fiber.Map{}
+	for i := range templates {
+		recurring := &templates[i]
+		history := byTemplate[recurring.ID]
+
+		var summary models.AdherenceSummary
+		for j := range history {
+			summary.Add(&history[j], now, loc)
+			overall.Add(&history[j], now, loc)
+		}
+		if pending := pendingOccurrence(recurring, history); pending != nil && !pending.ScheduledDate.After(now) && !pending.ScheduledDate.Before(since) {
+			summary.Add(pending, now, loc)
+			overall.Add(pending, now, loc)
+		}
+		if summary.Total == 0 {
+			continue
+		}
+		summary.Finish()
+
+		result = append(result, fiber.Map{
+			"id":          recurring.ID,
+			"type":        recurring.Type,
+			"description": recurring.Description,
+			"adherence":   summary,
+		})
+	}
+	overall.Finish()
+
+	return c.JSON(fiber.Map{
+		"since":      since,
+		"adherence":  overall,
+		route.plural: result,
+	})
+}
+
+// Periodo pendiente de una plantilla activa que aún no tiene registro (nadie lo pagó,
+// omitió ni pospuso); nil si ya existe o la plantilla no tiene más vencimientos
+func pendingOccurrence(recurring *models.RecurringTransaction, occurrences []models.RecurringOccurrence) *models.RecurringOccurrence {
+	if !recurring.IsActive || recurring.HasEnded() {
+		return nil
+	}
+	for _, occurrence := range occurrences {
+		if occurrence.IsOpen() || occurrence.ScheduledDate.Equal(recurring.NextDueDate) {
+			return nil
+		}
+	}
+	return &models.RecurringOccurrence{
+		RecurringTransactionID: recurring.ID,
+		UserID:                 recurring.UserID,
+		ScheduledDate:          recurring.NextDueDate,
+		DueDate:                recurring.NextDueDate,
+		Status:                 models.OccurrenceDue,
+		Amount:                 recurring.Amount,
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Ajustes opcionales al aceptar una sugerencia (por defecto se usan los detectados)
+type AcceptRecurringSuggestionRequest struct {
+	AccountID    *uint      `json:"account_id,omitempty"`
+	CategoryID   *uint      `json:"category_id,omitempty"`
+	Amount       *float64   `json:"amount,omitempty" validate:"omitempty,gt=0"`
+	Description  string     `json:"description,omitempty" validate:"omitempty,min=1,max=255"`
+	StartDate    *time.Time `json:"start_date,omitempty"` // Por defecto el próximo cobro esperado
+	AutoGenerate bool       `json:"auto_generate,omitempty"`
+	Notes        string     `json:"notes,omitempty"`
+}
+
+// Sugerencias pendientes: gastos que parecen recurrentes y aún no tienen plantilla
+func GetRecurringSuggestions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	detector := &services.SubscriptionDetector{}
+	suggestions, err := detector.Pending(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch recurring suggestions"})
+	}
+
+	return c.JSON(fiber.Map{
+		"recurring_suggestions": suggestions,
+		"total":                 len(suggestions),
+	})
+}
+
+// Volver a analizar el historial ahora (el job diario lo hace para todos)
+func RefreshRecurringSuggestions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	detector := &services.SubscriptionDetector{}
+	if err := detector.Refresh(userID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not analyse transactions"})
+	}
+
+	suggestions, err := detector.Pending(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch recurring suggestions"})
+	}
+
+	return c.JSON(fiber.Map{
+		"recurring_suggestions": suggestions,
+		"total":                 len(suggestions),
+	})
+}
+
+// Crear el gasto recurrente de una sugerencia en un solo paso
+func AcceptRecurringSuggestion(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	suggestionID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Recurring suggestion not found"})
+	}
+
+	var req AcceptRecurringSuggestionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+		}
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	detector := &services.SubscriptionDetector{}
+	suggestion, err := detector.FindPending(userID, uint(suggestionID))
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not accept recurring suggestion")
+	}
+
+	create := CreateRecurringTransactionRequest{
+		Type:         "expense",
+		AccountID:    suggestion.AccountID,
+		CategoryID:   suggestion.CategoryID,
+		Amount:       suggestion.Amount,
+		Description:  suggestion.Description,
+		Frequency:    suggestion.Frequency,
+		StartDate:    suggestion.NextDueDate,
+		AutoGenerate: req.AutoGenerate,
+		Notes:        req.Notes,
+	}
+	if req.AccountID != nil {
+		create.AccountID = *req.AccountID
+	}
+	if req.CategoryID != nil {
+		create.CategoryID = req.CategoryID
+	}
+	if req.Amount != nil {
+		create.Amount = *req.Amount
+	}
+	if req.Description != "" {
+		create.Description = req.Description
+	}
+	if req.StartDate != nil {
+		create.StartDate = *req.StartDate
+	}
+
+	recurring, status, message := newRecurringTransaction(userID, create)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": message})
+	}
+
+	if err := detector.Accept(suggestion, recurring); err != nil {
+		return serviceErrorResponse(c, err, "Could not accept recurring suggestion")
+	}
+
+	// Cargar relaciones para la respuesta
+	preloadRecurringTransaction(config.DB).First(recurring, recurring.ID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":               "Recurring suggestion accepted successfully",
+		"recurring_suggestion":  suggestion,
+		"recurring_transaction": recurring,
+	})
+}
+
+// Descartar una sugerencia: el mismo patrón no vuelve a sugerirse
+func DismissRecurringSuggestion(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	suggestionID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Recurring suggestion not found"})
+	}
+
+	detector := &services.SubscriptionDetector{}
+	suggestion, err := detector.Dismiss(userID, uint(suggestionID))
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not dismiss recurring suggestion")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":              "Recurring suggestion dismissed successfully",
+		"recurring_suggestion": suggestion,
+	})
+}
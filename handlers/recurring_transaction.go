@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CreateRecurringTransactionRequest struct {
+	Type         string     `json:"type" validate:"required,oneof=income expense loan_given loan_received loan_payment_given loan_payment_received transfer"`
+	AccountID    uint       `json:"account_id" validate:"required"`
+	ToAccountID  *uint      `json:"to_account_id,omitempty"` // Requerido en transfer
+	CategoryID   *uint      `json:"category_id,omitempty"`
+	LoanID       *uint      `json:"loan_id,omitempty"` // Cuotas de un préstamo (loan_payment_*)
+	Amount       float64    `json:"amount" validate:"required,gt=0"`
+	Description  string     `json:"description" validate:"required,min=1,max=255"`
+	Frequency    string     `json:"frequency" validate:"required,max=255"` // daily, weekly, monthly, yearly o RRULE
+	StartDate    time.Time  `json:"start_date" validate:"required"`
+	EndDate      *time.Time `json:"end_date,omitempty"`
+	AutoGenerate bool       `json:"auto_generate,omitempty"` // Registrar la transacción al vencer
+	Notes        string     `json:"notes,omitempty"`
+}
+
+type UpdateRecurringTransactionRequest struct {
+	AccountID    *uint      `json:"account_id,omitempty"`
+	ToAccountID  *uint      `json:"to_account_id,omitempty"`
+	CategoryID   *uint      `json:"category_id,omitempty"`
+	Amount       *float64   `json:"amount,omitempty" validate:"omitempty,gt=0"`
+	Description  string     `json:"description,omitempty" validate:"omitempty,min=1,max=255"`
+	Frequency    string     `json:"frequency,omitempty" validate:"omitempty,max=255"`
+	EndDate      *time.Time `json:"end_date,omitempty"`
+	Notes        string     `json:"notes,omitempty"`
+	IsActive     *bool      `json:"is_active,omitempty"`
+	AutoGenerate *bool      `json:"auto_generate,omitempty"`
+}
+
+type ExecuteRecurringTransactionRequest struct {
+	AccountID *uint      `json:"account_id,omitempty"` // Puede ser diferente al original
+	Amount    *float64   `json:"amount,omitempty" validate:"omitempty,gt=0"`
+	Date      *time.Time `json:"date,omitempty"`    // Fecha real del movimiento
+	Notes     string     `json:"notes,omitempty"`   // Notas del movimiento específico
+	Partial   bool       `json:"partial,omitempty"` // Abono: el periodo sigue pendiente hasta completar el monto
+}
+
+type PreviewRecurrenceRequest struct {
+	Frequency string     `json:"frequency" validate:"required,max=255"`
+	StartDate time.Time  `json:"start_date" validate:"required"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+	Count     int        `json:"count,omitempty" validate:"omitempty,min=1,max=100"` // Por defecto 12
+}
+
+// Nombres en las respuestas: /recurring-expenses conserva los de antes y solo ve gastos
+type recurringRoute struct {
+	onlyExpenses bool
+	single       string
+	plural       string
+	label        string
+}
+
+var (
+	recurringTransactionsRoute = recurringRoute{false, "recurring_transaction", "recurring_transactions", "Recurring transaction"}
+	recurringExpensesRoute     = recurringRoute{true, "recurring_expense", "recurring_expenses", "Recurring expense"}
+)
+
+func CreateRecurringTransaction(c *fiber.Ctx) error {
+	var req CreateRecurringTransactionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	return createRecurringTransaction(c, req, recurringTransactionsRoute)
+}
+
+func GetRecurringTransactions(c *fiber.Ctx) error {
+	return getRecurringTransactions(c, recurringTransactionsRoute)
+}
+
+func GetRecurringTransaction(c *fiber.Ctx) error {
+	return getRecurringTransaction(c, recurringTransactionsRoute)
+}
+
+func UpdateRecurringTransaction(c *fiber.Ctx) error {
+	return updateRecurringTransaction(c, recurringTransactionsRoute)
+}
+
+func DeleteRecurringTransaction(c *fiber.Ctx) error {
+	return deleteRecurringTransaction(c, recurringTransactionsRoute)
+}
+
+// Registrar la ocurrencia pendiente de una plantilla
+func ExecuteRecurringTransaction(c *fiber.Ctx) error {
+	return executeRecurringTransaction(c, recurringTransactionsRoute)
+}
+
+// Próximos N vencimientos de una plantilla (?count=12, máximo 100)
+func PreviewRecurringTransaction(c *fiber.Ctx) error {
+	return previewRecurringTransaction(c, recurringTransactionsRoute)
+}
+
+// Previsualizar una regla antes de crear la plantilla
+func PreviewRecurrence(c *fiber.Ctx) error {
+	var req PreviewRecurrenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	rule, err := models.NormalizeRecurrence(req.Frequency, req.StartDate)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid frequency: use daily, weekly, monthly, yearly or an RRULE"})
+	}
+
+	count := req.Count
+	if count == 0 {
+		count = 12
+	}
+
+	recurring := models.RecurringTransaction{
+		Frequency:   rule,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		NextDueDate: req.StartDate,
+		IsActive:    true,
+	}
+
+	return c.JSON(fiber.Map{
+		"frequency":   rule,
+		"occurrences": recurring.UpcomingOccurrences(count),
+	})
+}
+
+func createRecurringTransaction(c *fiber.Ctx, req CreateRecurringTransactionRequest, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	recurring, status, message := saveRecurringTransaction(userID, req, route)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": message})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":    route.label + " created successfully",
+		route.single: recurring,
+	})
+}
+
+// Validar y guardar una plantilla nueva con sus recordatorios. Devuelve el código y
+// mensaje de error (status 0 si se creó)
+func saveRecurringTransaction(userID uint, req CreateRecurringTransactionRequest, route recurringRoute) (*models.RecurringTransaction, int, string) {
+	recurring, status, message := newRecurringTransaction(userID, req)
+	if status != 0 {
+		return nil, status, message
+	}
+
+	if err := config.DB.Create(recurring).Error; err != nil {
+		return nil, 500, "Could not create " + strings.ToLower(route.label)
+	}
+
+	finishRecurringTransaction(recurring)
+	return recurring, 0, ""
+}
+
+// Armar y validar una plantilla nueva (sin guardarla)
+func newRecurringTransaction(userID uint, req CreateRecurringTransactionRequest) (*models.RecurringTransaction, int, string) {
+	rule, err := models.NormalizeRecurrence(req.Frequency, req.StartDate)
+	if err != nil {
+		return nil, 400, "Invalid frequency: use daily, weekly, monthly, yearly or an RRULE"
+	}
+
+	recurring := models.RecurringTransaction{
+		UserID:       userID,
+		AccountID:    req.AccountID,
+		CategoryID:   req.CategoryID,
+		Type:         req.Type,
+		ToAccountID:  req.ToAccountID,
+		LoanID:       req.LoanID,
+		Amount:       req.Amount,
+		Description:  req.Description,
+		Frequency:    rule,
+		StartDate:    req.StartDate,
+		EndDate:      req.EndDate,
+		AutoGenerate: req.AutoGenerate,
+		Notes:        req.Notes,
+	}
+
+	if status, message := validateRecurringTransaction(userID, &recurring); status != 0 {
+		return nil, status, message
+	}
+
+	// Primer vencimiento: la primera ocurrencia de la regla desde start_date
+	firstDueDate, ok := recurring.OccurrenceFrom(req.StartDate)
+	if !ok {
+		return nil, 400, "Recurrence has no occurrences"
+	}
+	recurring.NextDueDate = firstDueDate
+
+	return &recurring, 0, ""
+}
+
+// Crear los recordatorios de una plantilla ya guardada y cargar sus relaciones
+func finishRecurringTransaction(recurring *models.RecurringTransaction) {
+	reminderService := &services.ReminderService{}
+	reminderService.CreateRemindersForRecurringTransaction(recurring)
+
+	// Cargar relaciones para la respuesta
+	preloadRecurringTransaction(config.DB).First(recurring, recurring.ID)
+}
+
+func getRecurringTransactions(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	// Filtros opcionales
+	isActive := c.Query("is_active", "true") // Por defecto solo activos
+	frequency := c.Query("frequency")
+	recurringType := c.Query("type")
+
+	query := scopeRecurringTransactions(config.DB.Where("user_id = ?", userID), route)
+
+	// Aplicar filtros
+	if isActive == "true" {
+		query = query.Where("is_active = true")
+	} else if isActive == "false" {
+		query = query.Where("is_active = false")
+	}
+	// Si es "all", no filtrar por is_active
+
+	// daily, weekly, monthly o yearly: filtrar por el FREQ de la regla
+	if frequency != "" {
+		query = query.Where("frequency LIKE ?", "FREQ="+strings.ToUpper(frequency)+"%")
+	}
+	if recurringType != "" {
+		query = query.Where("type = ?", recurringType)
+	}
+
+	var templates []models.RecurringTransaction
+	if err := preloadRecurringTransaction(query).Order("next_due_date asc").Find(&templates).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch " + strings.ToLower(route.label) + "s"})
+	}
+
+	// Agregar información útil (vencimientos según la zona del usuario)
+	loc := models.UserLocation(config.DB, userID)
+	result := make([]fiber.Map, len(templates))
+	for i := range templates {
+		result[i] = recurringTransactionResponse(&templates[i], loc)
+	}
+
+	return c.JSON(fiber.Map{
+		route.plural: result,
+	})
+}
+
+func getRecurringTransaction(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	recurring, err := findRecurringTransaction(preloadRecurringTransaction(config.DB), userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	response := recurringTransactionResponse(recurring, models.UserLocation(config.DB, userID))
+	response["updated_at"] = recurring.UpdatedAt
+
+	return c.JSON(fiber.Map{
+		route.single: response,
+	})
+}
+
+func updateRecurringTransaction(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req UpdateRecurringTransactionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	recurring, err := findRecurringTransaction(config.DB, userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	// Actualizar campos
+	if req.AccountID != nil {
+		recurring.AccountID = *req.AccountID
+	}
+	if req.ToAccountID != nil {
+		recurring.ToAccountID = req.ToAccountID
+	}
+	if req.CategoryID != nil {
+		recurring.CategoryID = req.CategoryID
+	}
+	if req.Amount != nil {
+		recurring.Amount = *req.Amount
+	}
+	if req.Description != "" {
+		recurring.Description = req.Description
+	}
+	if req.Frequency != "" {
+		rule, err := models.NormalizeRecurrence(req.Frequency, recurring.StartDate)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid frequency: use daily, weekly, monthly, yearly or an RRULE"})
+		}
+		recurring.Frequency = rule
+	}
+	if req.EndDate != nil {
+		recurring.EndDate = req.EndDate
+	}
+	if req.Notes != "" {
+		recurring.Notes = req.Notes
+	}
+	if req.IsActive != nil {
+		recurring.IsActive = *req.IsActive
+	}
+	if req.AutoGenerate != nil {
+		recurring.AutoGenerate = *req.AutoGenerate
+	}
+
+	// Verificar cuentas, categoría y préstamo con los valores nuevos
+	if status, message := validateRecurringTransaction(userID, recurring); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": message})
+	}
+
+	recurringService := &services.RecurringTransactionService{}
+	if err := recurringService.Update(recurring, services.UpdateRecurringTransactionInput{
+		Rescheduled:   req.Frequency != "" || req.EndDate != nil,
+		AmountChanged: req.Amount != nil,
+	}); err != nil {
+		return serviceErrorResponse(c, err, "Could not update "+strings.ToLower(route.label))
+	}
+
+	// Cargar relaciones para la respuesta
+	preloadRecurringTransaction(config.DB).First(recurring, recurring.ID)
+
+	return c.JSON(fiber.Map{
+		"message":    route.label + " updated successfully",
+		route.single: recurring,
+	})
+}
+
+func deleteRecurringTransaction(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	recurring, err := findRecurringTransaction(config.DB, userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	// Soft delete
+	if err := config.DB.Delete(recurring).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not delete " + strings.ToLower(route.label)})
+	}
+
+	reminderService := &services.ReminderService{}
+	reminderService.CancelRemindersForRecurringTransaction(recurring.ID)
+
+	return c.JSON(fiber.Map{
+		"message": route.label + " deleted successfully",
+	})
+}
+
+func executeRecurringTransaction(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	recurring, err := findRecurringTransaction(config.DB, userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	var req ExecuteRecurringTransactionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	recurringTransactionService := &services.RecurringTransactionService{}
+	transaction, updated, occurrence, err := recurringTransactionService.Execute(userID, recurring.ID, services.ExecuteRecurringTransactionInput{
+		AccountID: req.AccountID,
+		Amount:    req.Amount,
+		Date:      req.Date,
+		Notes:     req.Notes,
+		Partial:   req.Partial,
+	})
+	if err != nil {
+		return serviceErrorResponse(c, err, "Could not execute "+strings.ToLower(route.label))
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       route.label + " executed successfully",
+		"transaction":   transaction,
+		"occurrence":    occurrence,
+		"next_due_date": updated.NextDueDate,
+		route.single:    updated.ID,
+	})
+}
+
+func previewRecurringTransaction(c *fiber.Ctx, route recurringRoute) error {
+	userID := c.Locals("user_id").(uint)
+
+	count := c.QueryInt("count", 12)
+	if count < 1 || count > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "Count must be between 1 and 100"})
+	}
+
+	recurring, err := findRecurringTransaction(config.DB, userID, c.Params("id"), route)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": route.label + " not found"})
+	}
+
+	return c.JSON(fiber.Map{
+		"frequency":   recurring.Frequency,
+		"occurrences": recurring.UpcomingOccurrences(count),
+	})
+}
+
+// Verificar que cuentas, categoría y préstamo son del usuario y encajan con el tipo.
+// Devuelve el código y mensaje de error, o 0 si todo está bien
+func validateRecurringTransaction(userID uint, recurring *models.RecurringTransaction) (int, string) {
+	var account models.Account
+	if err := config.DB.Where("id = ? AND user_id = ?", recurring.AccountID, userID).First(&account).Error; err != nil {
+		return 404, "Account not found"
+	}
+
+	if recurring.CategoryID != nil {
+		var category models.Category
+		if err := config.DB.Where("id = ? AND user_id = ?", *recurring.CategoryID, userID).First(&category).Error; err != nil {
+			return 404, "Category not found"
+		}
+	}
+
+	// Transferencia: otra cuenta del usuario con la misma moneda
+	if recurring.IsTransfer() {
+		if recurring.ToAccountID == nil {
+			return 400, "Transfers require to_account_id"
+		}
+		var toAccount models.Account
+		if err := config.DB.Where("id = ? AND user_id = ?", *recurring.ToAccountID, userID).First(&toAccount).Error; err != nil {
+			return 404, "Account not found"
+		}
+		if toAccount.ID == account.ID || toAccount.Currency != account.Currency {
+			return 400, "Transfer needs two different accounts with the same currency"
+		}
+	} else if recurring.ToAccountID != nil {
+		return 400, "Only transfers can have to_account_id"
+	}
+
+	// Cuotas: el tipo debe coincidir con el sentido del préstamo
+	if recurring.LoanID != nil {
+		var loan models.Loan
+		if err := config.DB.Where("id = ? AND user_id = ?", *recurring.LoanID, userID).First(&loan).Error; err != nil {
+			return 404, "Loan not found"
+		}
+		if recurring.Type != services.LoanPaymentTransactionType(&loan) {
+			return 400, "Type must be " + services.LoanPaymentTransactionType(&loan) + " for this loan"
+		}
+	}
+
+	return 0, ""
+}
+
+func findRecurringTransaction(db *gorm.DB, userID uint, id string, route recurringRoute) (*models.RecurringTransaction, error) {
+	var recurring models.RecurringTransaction
+	err := scopeRecurringTransactions(db.Where("id = ? AND user_id = ?", id, userID), route).First(&recurring).Error
+	if err != nil {
+		return nil, err
+	}
+	return &recurring, nil
+}
+
+// /recurring-expenses solo ve las plantillas de gasto
+func scopeRecurringTransactions(query *gorm.DB, route recurringRoute) *gorm.DB {
+	if route.onlyExpenses {
+		return query.Where("type = ?", "expense")
+	}
+	return query
+}
+
+func preloadRecurringTransaction(db *gorm.DB) *gorm.DB {
+	return db.Preload("Account").Preload("ToAccount").Preload("Category").Preload("Loan")
+}
+
+func recurringTransactionResponse(recurring *models.RecurringTransaction, loc *time.Location) fiber.Map {
+	return fiber.Map{
+		"id":            recurring.ID,
+		"type":          recurring.Type,
+		"account":       recurring.Account,
+		"to_account":    recurring.ToAccount,
+		"category":      recurring.Category,
+		"loan":          recurring.Loan,
+		"amount":        recurring.Amount,
+		"description":   recurring.Description,
+		"frequency":     recurring.Frequency,
+		"start_date":    recurring.StartDate,
+		"end_date":      recurring.EndDate,
+		"next_due_date": recurring.NextDueDate,
+		"is_active":     recurring.IsActive,
+		"auto_generate": recurring.AutoGenerate,
+		"notes":         recurring.Notes,
+		"created_at":    recurring.CreatedAt,
+		"is_overdue":    recurring.IsOverdue(loc),
+		"is_due_today":  recurring.IsDueToday(loc),
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateReminderRequest struct {
+	Title       string    `json:"title" validate:"required,min=1,max=255"`
+	Description string    `json:"description,omitempty" validate:"max=255"`
+	RemindAt    time.Time `json:"remind_at" validate:"required"`
+	Priority    string    `json:"priority,omitempty" validate:"omitempty,oneof=low normal high urgent"` // Por defecto normal
+}
+
+type SnoozeReminderRequest struct {
+	Until time.Time `json:"until" validate:"required"`
+}
+
+// Bandeja de recordatorios (?status=unread|upcoming|sent&type=loan&page=1&limit=20)
+func GetReminders(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 || limit < 1 || limit > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "Page must be positive and limit between 1 and 100"})
+	}
+
+	query := config.DB.Model(&models.Reminder{}).Where("user_id = ? AND is_active = true", userID)
+
+	switch c.Query("status") {
+	case "":
+	case "unread":
+		query = query.Where("is_sent = true AND read_at IS NULL")
+	case "upcoming":
+		query = query.Where("is_sent = false")
+	case "sent":
+		query = query.Where("is_sent = true")
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid status: use unread, upcoming or sent"})
+	}
+
+	if reminderType := c.Query("type"); reminderType != "" {
+		query = query.Where("type = ?", reminderType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch reminders"})
+	}
+
+	// Los próximos en el orden en que llegarán; el resto, lo más reciente primero
+	order := "remind_at desc"
+	if c.Query("status") == "upcoming" {
+		order = "remind_at asc"
+	}
+
+	var reminders []models.Reminder
+	if err := query.Order(order).Offset((page - 1) * limit).Limit(limit).Find(&reminders).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch reminders"})
+	}
+
+	return c.JSON(fiber.Map{
+		"reminders": reminders,
+		"total":     total,
+		"page":      page,
+		"limit":     limit,
+	})
+}
+
+// Cantidad de recordatorios sin leer (badge de la app)
+func GetUnreadRemindersCount(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var count int64
+	err := config.DB.Model(&models.Reminder{}).
+		Where("user_id = ? AND is_active = true AND is_sent = true AND read_at IS NULL", userID).
+		Count(&count).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not count reminders"})
+	}
+
+	return c.JSON(fiber.Map{
+		"unread_count": count,
+	})
+}
+
+func GetReminder(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var reminder models.Reminder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&reminder).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Reminder not found"})
+	}
+
+	return c.JSON(fiber.Map{
+		"reminder": reminder,
+	})
+}
+
+// Crear un recordatorio personalizado
+func CreateReminder(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req CreateReminderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	priority := req.Priority
+	if priority == "" {
+		priority = "normal"
+	}
+
+	reminder := models.Reminder{
+		UserID:      userID,
+		Title:       req.Title,
+		Description: req.Description,
+		RemindAt:    req.RemindAt,
+		Priority:    priority,
+	}
+
+	reminderService := &services.ReminderService{}
+	if err := reminderService.CreateCustomReminder(&reminder); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not create reminder"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":  "Reminder created successfully",
+		"reminder": reminder,
+	})
+}
+
+// Marcar un recordatorio como leído
+func MarkReminderRead(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var reminder models.Reminder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&reminder).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Reminder not found"})
+	}
+
+	if !reminder.IsSent {
+		return c.Status(400).JSON(fiber.Map{"error": "Reminder has not been sent yet"})
+	}
+
+	if reminder.ReadAt == nil {
+		now := time.Now()
+		reminder.ReadAt = &now
+		if err := config.DB.Model(&reminder).Update("read_at", now).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Could not update reminder"})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "Reminder marked as read",
+		"reminder": reminder,
+	})
+}
+
+// Marcar como leídos todos los recordatorios enviados
+func MarkAllRemindersRead(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	result := config.DB.Model(&models.Reminder{}).
+		Where("user_id = ? AND is_sent = true AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not update reminders"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Reminders marked as read",
+		"updated": result.RowsAffected,
+	})
+}
+
+// Posponer un recordatorio: vuelve a llegar (sin leer) en la fecha indicada
+func SnoozeReminder(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req SnoozeReminderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Validation failed"})
+	}
+
+	if !req.Until.After(time.Now()) {
+		return c.Status(400).JSON(fiber.Map{"error": "Snooze date must be in the future"})
+	}
+
+	var reminder models.Reminder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&reminder).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Reminder not found"})
+	}
+
+	if !reminder.IsActive {
+		return c.Status(400).JSON(fiber.Map{"error": "Reminder is not active"})
+	}
+
+	reminder.RemindAt = req.Until
+	reminder.IsSent = false
+	reminder.SentAt = nil
+	reminder.ReadAt = nil
+	if err := config.DB.Save(&reminder).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not snooze reminder"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "Reminder snoozed successfully",
+		"reminder": reminder,
+	})
+}
+
+// Envíos del recordatorio por canal, con sus intentos
+func GetReminderDeliveries(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var reminder models.Reminder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&reminder).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Reminder not found"})
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := config.DB.Where("reminder_id = ?", reminder.ID).Order("created_at asc").Find(&deliveries).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not fetch deliveries"})
+	}
+
+	return c.JSON(fiber.Map{
+		"deliveries": deliveries,
+	})
+}
+
+// Desactivar un recordatorio: deja de enviarse y sale de la bandeja
+func DeactivateReminder(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var reminder models.Reminder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&reminder).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Reminder not found"})
+	}
+
+	reminder.IsActive = false
+	if err := config.DB.Model(&reminder).Update("is_active", false).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not deactivate reminder"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "Reminder deactivated successfully",
+		"reminder": reminder,
+	})
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"cuentas-claras/services"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Traducir el error de un servicio a la respuesta HTTP
+func serviceErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	var serviceErr *services.ServiceError
+	if errors.As(err, &serviceErr) {
+		return c.Status(serviceErr.Status).JSON(fiber.Map{"error": serviceErr.Message})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": fallback})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"cuentas-claras/config"
 	"cuentas-claras/models"
+	"cuentas-claras/services"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -54,19 +55,11 @@ func CreateTransaction(c *fiber.Ctx) error {
 	}
 
 	// Determinar direction basado en el type y amount
-	var direction string
-	var amount float64
-
-	switch req.Type {
-	case "income", "loan_received", "loan_payment_received":
-		direction = "in"
-		amount = abs(req.Amount) // Asegurar que sea positivo
-	case "expense", "loan_given", "loan_payment_given":
-		direction = "out"
-		amount = -abs(req.Amount) // Asegurar que sea negativo
-	default:
+	direction, ok := models.DirectionForType(req.Type)
+	if !ok {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid transaction type"})
 	}
+	amount := models.SignedAmount(direction, req.Amount) // Positivo si entra, negativo si sale
 
 	transaction := models.Transaction{
 		UserID:      userID,
@@ -87,6 +80,9 @@ func CreateTransaction(c *fiber.Ctx) error {
 	// Cargar relaciones para la respuesta
 	config.DB.Preload("Account").Preload("Category").First(&transaction, transaction.ID)
 
+	// Avisar a los demás dispositivos del usuario
+	services.Events.PublishFrom(userID, c.Locals("device_session_id").(uint), models.EventTransactionCreated, transaction)
+
 	return c.Status(201).JSON(fiber.Map{
 		"message":     "Transaction created successfully",
 		"transaction": transaction,
@@ -184,13 +180,8 @@ func UpdateTransaction(c *fiber.Ctx) error {
 
 	// Actualizar campos
 	if req.Amount != nil {
-		// Mantener consistencia direction/amount basado en el type existente
-		switch transaction.Type {
-		case "income", "loan_received", "loan_payment_received":
-			transaction.Amount = abs(*req.Amount)
-		case "expense", "loan_given", "loan_payment_given":
-			transaction.Amount = -abs(*req.Amount)
-		}
+		// Mantener consistencia direction/amount basado en la direction existente
+		transaction.Amount = models.SignedAmount(transaction.Direction, *req.Amount)
 	}
 	if req.Description != "" {
 		transaction.Description = req.Description
@@ -233,11 +224,3 @@ func DeleteTransaction(c *fiber.Ctx) error {
 		"message": "Transaction deleted successfully",
 	})
 }
-
-// Función auxiliar para valor absoluto
-func abs(x float64) float64 {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"cuentas-claras/config"
+	"cuentas-claras/i18n"
 	"cuentas-claras/models"
+	"cuentas-claras/services"
+	"cuentas-claras/utils"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -33,9 +37,12 @@ func GetProfile(c *fiber.Ctx) error {
 			"notifications_enabled": user.NotificationsEnabled,
 			"push_notifications":    user.PushNotifications,
 			"in_app_notifications":  user.InAppNotifications,
+			"email_notifications":   user.EmailNotifications,
+			"webhook_configured":    user.WebhookURL != "",
 			"timezone":              user.Timezone,
-			"quiet_hours_start":     user.QuietHoursStart,
-			"quiet_hours_end":       user.QuietHoursEnd,
+			"locale":                user.Locale,
+			"quiet_hours_start":     models.FormatClock(user.QuietHoursStart),
+			"quiet_hours_end":       models.FormatClock(user.QuietHoursEnd),
 			"created_at":            user.CreatedAt,
 		},
 		"active_sessions": len(activeSessions),
@@ -47,17 +54,59 @@ func UpdateProfile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
 
 	var req struct {
-		Name                 string `json:"name,omitempty"`
-		PhoneNumber          string `json:"phone_number,omitempty"`
-		NotificationsEnabled *bool  `json:"notifications_enabled,omitempty"`
-		PushNotifications    *bool  `json:"push_notifications,omitempty"`
-		Timezone             string `json:"timezone,omitempty"`
+		Name                 string  `json:"name,omitempty"`
+		PhoneNumber          string  `json:"phone_number,omitempty"`
+		NotificationsEnabled *bool   `json:"notifications_enabled,omitempty"`
+		PushNotifications    *bool   `json:"push_notifications,omitempty"`
+		InAppNotifications   *bool   `json:"in_app_notifications,omitempty"`
+		EmailNotifications   *bool   `json:"email_notifications,omitempty"`
+		WebhookURL           *string `json:"webhook_url,omitempty"`       // http(s); "" para quitar
+		Timezone             string  `json:"timezone,omitempty"`          // IANA, p. ej. America/Lima
+		Locale               string  `json:"locale,omitempty"`            // es-PE, en-US
+		QuietHoursStart      *string `json:"quiet_hours_start,omitempty"` // "HH:MM"; "" para quitar
+		QuietHoursEnd        *string `json:"quiet_hours_end,omitempty"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
+	if req.Timezone != "" {
+		if _, err := models.LoadTimezone(req.Timezone); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid timezone: use an IANA name like America/Lima"})
+		}
+	}
+
+	if req.Locale != "" && !i18n.Supported(req.Locale) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid locale: use one of " + strings.Join(i18n.Locales, ", ")})
+	}
+
+	// Las horas de silencio van juntas: ambas o ninguna
+	if (req.QuietHoursStart == nil) != (req.QuietHoursEnd == nil) {
+		return c.Status(400).JSON(fiber.Map{"error": "quiet_hours_start and quiet_hours_end must be sent together"})
+	}
+	var quietStart, quietEnd *time.Time
+	if req.QuietHoursStart != nil && (*req.QuietHoursStart != "" || *req.QuietHoursEnd != "") {
+		start, err := models.ParseClock(*req.QuietHoursStart)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid quiet hours: use HH:MM"})
+		}
+		end, err := models.ParseClock(*req.QuietHoursEnd)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid quiet hours: use HH:MM"})
+		}
+		if start.Equal(end) {
+			return c.Status(400).JSON(fiber.Map{"error": "Quiet hours start and end must be different"})
+		}
+		quietStart, quietEnd = &start, &end
+	}
+
+	if req.WebhookURL != nil && *req.WebhookURL != "" {
+		if err := services.ValidateWebhookURL(*req.WebhookURL); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid webhook_url: " + err.Error()})
+		}
+	}
+
 	// Buscar usuario
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
@@ -77,16 +126,54 @@ func UpdateProfile(c *fiber.Ctx) error {
 	if req.PushNotifications != nil {
 		user.PushNotifications = *req.PushNotifications
 	}
+	if req.InAppNotifications != nil {
+		user.InAppNotifications = *req.InAppNotifications
+	}
+	if req.EmailNotifications != nil {
+		user.EmailNotifications = *req.EmailNotifications
+	}
+	// Cada URL nueva lleva un secreto nuevo para firmar; se muestra solo en esta respuesta
+	webhookSecret := ""
+	if req.WebhookURL != nil {
+		user.WebhookURL = *req.WebhookURL
+		user.WebhookSecret = ""
+		if user.WebhookURL != "" {
+			secret, err := utils.GenerateWebhookSecret()
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Could not update profile"})
+			}
+			user.WebhookSecret, webhookSecret = secret, secret
+		}
+	}
+	timezoneChanged := req.Timezone != "" && req.Timezone != user.Timezone
 	if req.Timezone != "" {
 		user.Timezone = req.Timezone
 	}
+	localeChanged := req.Locale != "" && req.Locale != user.Locale
+	if req.Locale != "" {
+		user.Locale = req.Locale
+	}
+	if req.QuietHoursStart != nil {
+		user.QuietHoursStart = quietStart
+		user.QuietHoursEnd = quietEnd
+	}
 
 	// Guardar cambios
 	if err := config.DB.Save(&user).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Could not update profile"})
 	}
 
-	return c.JSON(fiber.Map{
+	// Los recordatorios pendientes se programaron con la zona (o el idioma) anterior
+	if timezoneChanged || localeChanged {
+		reminderService := &services.ReminderService{}
+		reminderService.RescheduleRemindersForUser(userID)
+		if localeChanged {
+			// También los ya enviados y los de vencido o registro automático
+			reminderService.RerenderRemindersForUser(userID)
+		}
+	}
+
+	response := fiber.Map{
 		"message": "Profile updated successfully",
 		"user": fiber.Map{
 			"id":                    user.ID,
@@ -95,9 +182,20 @@ func UpdateProfile(c *fiber.Ctx) error {
 			"phone_number":          user.PhoneNumber,
 			"notifications_enabled": user.NotificationsEnabled,
 			"push_notifications":    user.PushNotifications,
+			"in_app_notifications":  user.InAppNotifications,
+			"email_notifications":   user.EmailNotifications,
+			"webhook_configured":    user.WebhookURL != "",
 			"timezone":              user.Timezone,
+			"locale":                user.Locale,
+			"quiet_hours_start":     models.FormatClock(user.QuietHoursStart),
+			"quiet_hours_end":       models.FormatClock(user.QuietHoursEnd),
 		},
-	})
+	}
+	if webhookSecret != "" {
+		response["webhook_secret"] = webhookSecret
+	}
+
+	return c.JSON(response)
 }
 
 func Logout(c *fiber.Ctx) error {
@@ -116,6 +214,11 @@ func Logout(c *fiber.Ctx) error {
 
 	config.DB.Save(&deviceSession)
 
+	// Cerrar también las conexiones en tiempo real de esta sesión
+	services.Events.PublishToSession(deviceSession.UserID, deviceSession.ID, models.EventForceLogout, fiber.Map{
+		"message": "Sesión cerrada",
+	})
+
 	return c.JSON(fiber.Map{
 		"message": "Logged out successfully",
 	})
@@ -0,0 +1,179 @@
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Idioma por defecto y los disponibles (un archivo locales/<locale>.json por cada uno)
+const DefaultLocale = "es-PE"
+
+var Locales = []string{"es-PE", "en-US"}
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Textos y formatos de un idioma
+type bundle struct {
+	Locale             string            `json:"-"`
+	DateFormat         string            `json:"date_format"`     // Layout de Go
+	DateTimeFormat     string            `json:"datetime_format"` // Layout de Go
+	DecimalSeparator   string            `json:"decimal_separator"`
+	ThousandsSeparator string            `json:"thousands_separator"`
+	MoneyFormat        string            `json:"money_format"`     // {symbol} y {amount}
+	CurrencySymbols    map[string]string `json:"currency_symbols"` // Sin símbolo se usa el código ISO
+	Messages           map[string]string `json:"messages"`         // Plantillas text/template
+
+	templates map[string]*template.Template
+}
+
+var bundles = loadBundles()
+
+func loadBundles() map[string]*bundle {
+	loaded := map[string]*bundle{}
+	for _, locale := range Locales {
+		data, err := localeFiles.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			log.Fatalf("i18n: missing bundle %s: %v", locale, err)
+		}
+
+		b := &bundle{Locale: locale}
+		if err := json.Unmarshal(data, b); err != nil {
+			log.Fatalf("i18n: invalid bundle %s: %v", locale, err)
+		}
+
+		b.templates = map[string]*template.Template{}
+		for key, text := range b.Messages {
+			tmpl, err := template.New(key).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				log.Fatalf("i18n: invalid message %s in %s: %v", key, locale, err)
+			}
+			b.templates[key] = tmpl
+		}
+		loaded[locale] = b
+	}
+	return loaded
+}
+
+// Si el idioma está disponible
+func Supported(locale string) bool {
+	_, ok := bundles[locale]
+	return ok
+}
+
+// Elegir el idioma disponible para un header Accept-Language ("en-US,en;q=0.9").
+// Coincide primero el locale exacto y luego el idioma ("en" → en-US)
+func Match(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if tag == "" {
+			continue
+		}
+		for _, locale := range Locales {
+			if strings.EqualFold(tag, locale) {
+				return locale
+			}
+		}
+		language := strings.SplitN(tag, "-", 2)[0]
+		for _, locale := range Locales {
+			if strings.EqualFold(language, strings.SplitN(locale, "-", 2)[0]) {
+				return locale
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+// Textos y formatos en el idioma y la zona horaria de un usuario
+type Localizer struct {
+	bundle *bundle
+	loc    *time.Location
+}
+
+// Localizer para un idioma (el por defecto si no está disponible) y una zona
+func New(locale string, loc *time.Location) *Localizer {
+	b, ok := bundles[locale]
+	if !ok {
+		b = bundles[DefaultLocale]
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &Localizer{bundle: b, loc: loc}
+}
+
+func (l *Localizer) Locale() string { return l.bundle.Locale }
+
+// Texto de un mensaje con sus datos. Si falta en el idioma se usa el por defecto y,
+// si tampoco existe, la clave
+func (l *Localizer) T(key string, data interface{}) string {
+	tmpl, ok := l.bundle.templates[key]
+	if !ok {
+		if tmpl, ok = bundles[DefaultLocale].templates[key]; !ok {
+			return key
+		}
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		log.Printf("i18n: could not render %s (%s): %v", key, l.bundle.Locale, err)
+		return key
+	}
+	return out.String()
+}
+
+// Monto con el símbolo de la moneda y los separadores del idioma ("S/ 1,234.50", "$1,234.50")
+func (l *Localizer) Money(amount float64, currency string) string {
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	number := groupThousands(cents/100, l.bundle.ThousandsSeparator) +
+		l.bundle.DecimalSeparator + fmt.Sprintf("%02d", cents%100)
+
+	var formatted string
+	if symbol, ok := l.bundle.CurrencySymbols[currency]; ok {
+		formatted = strings.NewReplacer("{symbol}", symbol, "{amount}", number).Replace(l.bundle.MoneyFormat)
+	} else {
+		formatted = currency + " " + number
+	}
+
+	if amount < 0 && cents > 0 {
+		return "-" + formatted
+	}
+	return formatted
+}
+
+// Día de calendario (vencimientos, fecha de una transacción): se toma tal como se guardó
+func (l *Localizer) Day(date time.Time) string {
+	return date.Format(l.bundle.DateFormat)
+}
+
+// Fecha y hora de un instante en la zona del usuario
+func (l *Localizer) Time(t time.Time) string {
+	return t.In(l.loc).Format(l.bundle.DateTimeFormat)
+}
+
+func groupThousands(n int64, separator string) string {
+	digits := fmt.Sprintf("%d", n)
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var b strings.Builder
+	first := len(digits) % 3
+	if first > 0 {
+		b.WriteString(digits[:first])
+	}
+	for i := first; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
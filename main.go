@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	_ "time/tzdata" // Zonas IANA aunque el sistema no tenga zoneinfo
 
 	"cuentas-claras/config"
 	"cuentas-claras/routes"
@@ -26,9 +27,8 @@ func main() {
 	// Ejecutar migraciones
 	config.RunMigrations()
 
-	// Iniciar job de recordatorios ✨ NUEVO
-	reminderService := &services.ReminderService{}
-	reminderService.StartDailyJob()
+	// Jobs en segundo plano (recordatorios, registro automático, reintentos, limpieza)
+	services.StartJobs()
 
 	// Crear app Fiber
 	app := fiber.New(fiber.Config{
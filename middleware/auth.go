@@ -104,6 +104,15 @@ func RequireAuth(c *fiber.Ctx) error {
 	})
 }
 
+// Aceptar el access token en ?token= (WebSocket y EventSource no permiten headers);
+// usar antes de RequireAuth
+func TokenFromQuery(c *fiber.Ctx) error {
+	if c.Get("Authorization") == "" && c.Query("token") != "" {
+		c.Request().Header.Set("Authorization", "Bearer "+c.Query("token"))
+	}
+	return c.Next()
+}
+
 // Middleware opcional - no requiere autenticación pero extrae info si existe
 func OptionalAuth(c *fiber.Ctx) error {
 	authHeader := c.Get("Authorization")
@@ -19,6 +19,9 @@ type Account struct {
 	Icon     string `json:"icon" gorm:"default:'account_balance_wallet'"`
 	IsActive bool   `json:"is_active" gorm:"default:true"`
 
+	// Saldo mínimo deseado: el pronóstico avisa si la proyección baja de aquí
+	LowBalanceThreshold float64 `json:"low_balance_threshold" gorm:"default:0"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"cuentas-claras/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Feed iCalendar (.ics) de vencimientos; uno por usuario, con token secreto regenerable
+type CalendarFeed struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	UserID    uint   `json:"user_id" gorm:"not null;uniqueIndex"`
+	TokenHash string `json:"-" gorm:"not null;uniqueIndex"`            // Para buscar el feed por token
+	Token     string `json:"-" gorm:"not null;column:token_encrypted"` // 🔒 ENCRIPTADO, para volver a mostrar la URL
+
+	// Actividad
+	RegeneratedAt *time.Time `json:"regenerated_at,omitempty"`
+	LastAccessAt  *time.Time `json:"last_access_at,omitempty"`
+	AccessCount   int        `json:"access_count" gorm:"default:0"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relaciones
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// Asignar un token nuevo (el anterior deja de funcionar al guardar)
+func (cf *CalendarFeed) SetToken(token string) {
+	cf.Token = token
+	cf.TokenHash = utils.HashFeedToken(token)
+}
+
+// Hook ANTES de guardar - encriptar
+func (cf *CalendarFeed) BeforeSave(tx *gorm.DB) error {
+	if cf.Token != "" {
+		cf.Token = utils.EncryptField(cf.Token)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (cf *CalendarFeed) AfterFind(tx *gorm.DB) error {
+	if cf.Token != "" {
+		cf.Token = utils.DecryptField(cf.Token)
+	}
+	return nil
+}
+
+// Registrar un acceso sin pasar por los hooks
+func (cf *CalendarFeed) RecordAccess(db *gorm.DB) {
+	now := time.Now()
+	db.Model(cf).UpdateColumns(map[string]interface{}{
+		"last_access_at": now,
+		"access_count":   gorm.Expr("access_count + 1"),
+	})
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"cuentas-claras/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Contact struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	// Información de la contraparte
+	Name  string `json:"name" gorm:"not null;column:name_encrypted"`   // 🔒 ENCRIPTADO
+	Phone string `json:"phone" gorm:"column:phone_encrypted"`          // 🔒 ENCRIPTADO
+	Notes string `json:"notes" gorm:"size:500;column:notes_encrypted"` // 🔒 ENCRIPTADO
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	User  User   `json:"-" gorm:"foreignKey:UserID"`
+	Loans []Loan `json:"loans,omitempty" gorm:"foreignKey:ContactID"`
+}
+
+// Hook ANTES de guardar - encriptar
+func (ct *Contact) BeforeSave(tx *gorm.DB) error {
+	if ct.Name != "" {
+		ct.Name = utils.EncryptField(ct.Name)
+	}
+	if ct.Phone != "" {
+		ct.Phone = utils.EncryptField(ct.Phone)
+	}
+	if ct.Notes != "" {
+		ct.Notes = utils.EncryptField(ct.Notes)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (ct *Contact) AfterFind(tx *gorm.DB) error {
+	if ct.Name != "" {
+		ct.Name = utils.DecryptField(ct.Name)
+	}
+	if ct.Phone != "" {
+		ct.Phone = utils.DecryptField(ct.Phone)
+	}
+	if ct.Notes != "" {
+		ct.Notes = utils.DecryptField(ct.Notes)
+	}
+	return nil
+}
+
+// Normalizar un nombre para comparar ("Juan ", "juan" y "JUÁN" son iguales)
+func NormalizeContactName(name string) string {
+	replacer := strings.NewReplacer(
+		"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	)
+	name = replacer.Replace(strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Buscar un contacto del usuario por nombre normalizado o crearlo
+// (los nombres están encriptados, así que se comparan en memoria)
+func FindOrCreateContact(db *gorm.DB, userID uint, name string) (*Contact, error) {
+	var contacts []Contact
+	if err := db.Where("user_id = ?", userID).Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+
+	key := NormalizeContactName(name)
+	for i := range contacts {
+		if NormalizeContactName(contacts[i].Name) == key {
+			return &contacts[i], nil
+		}
+	}
+
+	contact := Contact{
+		UserID: userID,
+		Name:   strings.TrimSpace(name),
+	}
+	if err := db.Create(&contact).Error; err != nil {
+		return nil, err
+	}
+
+	// Devolver con los campos desencriptados
+	if err := db.First(&contact, contact.ID).Error; err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// Vincular préstamos a un contacto manteniendo PersonName sincronizado SIN pasar por hooks
+func LinkLoansToContact(db *gorm.DB, contact *Contact, loanIDs []uint) error {
+	if len(loanIDs) == 0 {
+		return nil
+	}
+	return db.Model(&Loan{}).Where("id IN ? AND user_id = ?", loanIDs, contact.UserID).
+		UpdateColumns(map[string]interface{}{
+			"contact_id":            contact.ID,
+			"person_name_encrypted": utils.EncryptField(contact.Name),
+		}).Error
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"cuentas-claras/utils"
 	"time"
 
 	"gorm.io/gorm"
@@ -25,8 +26,8 @@ type DeviceSession struct {
 
 	// Tokens y control
 	RefreshTokenHash string `json:"-" gorm:"not null"`
-	RefreshTokenID   string `json:"-" gorm:"unique;not null;index"` // UUID único
-	FCMToken         string `json:"-" gorm:"column:fcm_token_encrypted"`
+	RefreshTokenID   string `json:"-" gorm:"unique;not null;index"`      // UUID único
+	FCMToken         string `json:"-" gorm:"column:fcm_token_encrypted"` // 🔒 ENCRIPTADO
 	IsFCMActive      bool   `json:"is_fcm_active" gorm:"default:true"`
 	IsActive         bool   `json:"is_active" gorm:"default:true"`
 
@@ -43,3 +44,19 @@ type DeviceSession struct {
 	// Relaciones
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
+
+// Hook ANTES de guardar - encriptar
+func (ds *DeviceSession) BeforeSave(tx *gorm.DB) error {
+	if ds.FCMToken != "" {
+		ds.FCMToken = utils.EncryptField(ds.FCMToken)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar (los tokens guardados antes en claro se leen igual)
+func (ds *DeviceSession) AfterFind(tx *gorm.DB) error {
+	if ds.FCMToken != "" {
+		ds.FCMToken = utils.DecryptField(ds.FCMToken)
+	}
+	return nil
+}
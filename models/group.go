@@ -0,0 +1,350 @@
+package models
+
+import (
+	"cuentas-claras/utils"
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Métodos de reparto de un gasto compartido
+const (
+	SplitEqual      = "equal"
+	SplitExact      = "exact"
+	SplitPercentage = "percentage"
+	SplitShares     = "shares"
+)
+
+// Grupo de gastos compartidos (viaje, departamento, etc.)
+type Group struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UserID      uint   `json:"user_id" gorm:"not null;index"`
+	Name        string `json:"name" gorm:"not null;column:name_encrypted"`      // 🔒 ENCRIPTADO
+	Description string `json:"description" gorm:"column:description_encrypted"` // 🔒 ENCRIPTADO
+	Currency    string `json:"currency" gorm:"not null;default:'PEN'"`
+	IsActive    bool   `json:"is_active" gorm:"default:true"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	User     User           `json:"-" gorm:"foreignKey:UserID"`
+	Members  []GroupMember  `json:"members,omitempty" gorm:"foreignKey:GroupID"`
+	Expenses []GroupExpense `json:"expenses,omitempty" gorm:"foreignKey:GroupID"`
+}
+
+// Integrante de un grupo: yo (IsMe) o una contraparte
+type GroupMember struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	GroupID   uint   `json:"group_id" gorm:"not null;index"`
+	ContactID *uint  `json:"contact_id,omitempty"`
+	Name      string `json:"name" gorm:"not null;column:name_encrypted"` // 🔒 ENCRIPTADO
+	IsMe      bool   `json:"is_me" gorm:"default:false"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Gasto pagado por un integrante y repartido entre varios
+type GroupExpense struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	GroupID        uint      `json:"group_id" gorm:"not null;index"`
+	PaidByMemberID uint      `json:"paid_by_member_id" gorm:"not null"`
+	Amount         float64   `json:"amount" gorm:"not null"`
+	Description    string    `json:"description" gorm:"not null;column:description_encrypted"` // 🔒 ENCRIPTADO
+	Date           time.Time `json:"date" gorm:"not null"`
+	SplitMethod    string    `json:"split_method" gorm:"not null"` // equal, exact, percentage, shares
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	Shares []GroupExpenseShare `json:"shares,omitempty" gorm:"foreignKey:GroupExpenseID"`
+}
+
+// Parte de un gasto que corresponde a un integrante
+type GroupExpenseShare struct {
+	ID             uint    `json:"id" gorm:"primaryKey"`
+	GroupExpenseID uint    `json:"group_expense_id" gorm:"not null;index"`
+	MemberID       uint    `json:"member_id" gorm:"not null"`
+	Value          float64 `json:"value"`                  // Monto, porcentaje o partes según SplitMethod
+	Amount         float64 `json:"amount" gorm:"not null"` // Lo que le toca pagar
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Pago entre integrantes para saldar deudas del grupo
+type GroupSettlement struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	GroupID       uint      `json:"group_id" gorm:"not null;index"`
+	FromMemberID  uint      `json:"from_member_id" gorm:"not null"`
+	ToMemberID    uint      `json:"to_member_id" gorm:"not null"`
+	Amount        float64   `json:"amount" gorm:"not null"`
+	Date          time.Time `json:"date" gorm:"not null"`
+	TransactionID *uint     `json:"transaction_id,omitempty"` // Si se registró en una de mis cuentas
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Hook ANTES de guardar - encriptar
+func (g *Group) BeforeSave(tx *gorm.DB) error {
+	if g.Name != "" {
+		g.Name = utils.EncryptField(g.Name)
+	}
+	if g.Description != "" {
+		g.Description = utils.EncryptField(g.Description)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (g *Group) AfterFind(tx *gorm.DB) error {
+	if g.Name != "" {
+		g.Name = utils.DecryptField(g.Name)
+	}
+	if g.Description != "" {
+		g.Description = utils.DecryptField(g.Description)
+	}
+	return nil
+}
+
+// Hook ANTES de guardar - encriptar
+func (gm *GroupMember) BeforeSave(tx *gorm.DB) error {
+	if gm.Name != "" {
+		gm.Name = utils.EncryptField(gm.Name)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (gm *GroupMember) AfterFind(tx *gorm.DB) error {
+	if gm.Name != "" {
+		gm.Name = utils.DecryptField(gm.Name)
+	}
+	return nil
+}
+
+// Hook ANTES de guardar - encriptar
+func (ge *GroupExpense) BeforeSave(tx *gorm.DB) error {
+	if ge.Description != "" {
+		ge.Description = utils.EncryptField(ge.Description)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (ge *GroupExpense) AfterFind(tx *gorm.DB) error {
+	if ge.Description != "" {
+		ge.Description = utils.DecryptField(ge.Description)
+	}
+	return nil
+}
+
+// Entrada de reparto: integrante y valor según el método
+type SplitInput struct {
+	MemberID uint
+	Value    float64
+}
+
+// Calcular cuánto le toca a cada integrante (en céntimos para no perder dinero)
+func SplitExpense(amount float64, method string, inputs []SplitInput) ([]GroupExpenseShare, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("at least one member is required")
+	}
+
+	totalCents := toCents(amount)
+	weights := make([]float64, len(inputs))
+
+	switch method {
+	case SplitEqual:
+		for i := range inputs {
+			weights[i] = 1
+		}
+	case SplitExact:
+		sum := int64(0)
+		for _, input := range inputs {
+			sum += toCents(input.Value)
+		}
+		if sum != totalCents {
+			return nil, errors.New("exact amounts must add up to the expense amount")
+		}
+		shares := make([]GroupExpenseShare, len(inputs))
+		for i, input := range inputs {
+			shares[i] = GroupExpenseShare{MemberID: input.MemberID, Value: input.Value, Amount: RoundMoney(input.Value)}
+		}
+		return shares, nil
+	case SplitPercentage:
+		sum := 0.0
+		for i, input := range inputs {
+			weights[i] = input.Value
+			sum += input.Value
+		}
+		if math.Abs(sum-100) > 0.001 {
+			return nil, errors.New("percentages must add up to 100")
+		}
+	case SplitShares:
+		for i, input := range inputs {
+			weights[i] = input.Value
+		}
+	default:
+		return nil, errors.New("invalid split method")
+	}
+
+	totalWeight := 0.0
+	for _, w := range weights {
+		if w < 0 {
+			return nil, errors.New("split values cannot be negative")
+		}
+		totalWeight += w
+	}
+	if totalWeight <= 0 {
+		return nil, errors.New("split values must be positive")
+	}
+
+	// Repartir céntimos por proporción y asignar el sobrante a los primeros
+	cents := make([]int64, len(inputs))
+	assigned := int64(0)
+	for i, w := range weights {
+		cents[i] = int64(math.Floor(float64(totalCents) * w / totalWeight))
+		assigned += cents[i]
+	}
+	for i := 0; assigned < totalCents; i = (i + 1) % len(cents) {
+		if weights[i] > 0 {
+			cents[i]++
+			assigned++
+		}
+	}
+
+	shares := make([]GroupExpenseShare, len(inputs))
+	for i, input := range inputs {
+		value := input.Value
+		if method == SplitEqual {
+			value = 1
+		}
+		shares[i] = GroupExpenseShare{MemberID: input.MemberID, Value: value, Amount: float64(cents[i]) / 100}
+	}
+	return shares, nil
+}
+
+// Saldo entre dos integrantes: From le debe Amount a To
+type MemberDebt struct {
+	FromMemberID uint    `json:"from_member_id"`
+	ToMemberID   uint    `json:"to_member_id"`
+	Amount       float64 `json:"amount"`
+}
+
+// Calcular saldo neto por integrante (+ le deben, - debe) y saldos entre pares
+func ComputeGroupBalances(expenses []GroupExpense, settlements []GroupSettlement) (map[uint]float64, []MemberDebt) {
+	net := map[uint]int64{}
+	pair := map[[2]uint]int64{} // [a, b] con a < b: positivo = a le debe a b
+
+	owe := func(from, to uint, cents int64) {
+		if from == to || cents == 0 {
+			return
+		}
+		net[from] -= cents
+		net[to] += cents
+		if from < to {
+			pair[[2]uint{from, to}] += cents
+		} else {
+			pair[[2]uint{to, from}] -= cents
+		}
+	}
+
+	for _, expense := range expenses {
+		for _, share := range expense.Shares {
+			owe(share.MemberID, expense.PaidByMemberID, toCents(share.Amount))
+		}
+	}
+
+	// Un pago de From a To reduce lo que From le debe a To
+	for _, settlement := range settlements {
+		owe(settlement.ToMemberID, settlement.FromMemberID, toCents(settlement.Amount))
+	}
+
+	balances := map[uint]float64{}
+	for member, cents := range net {
+		balances[member] = float64(cents) / 100
+	}
+
+	debts := []MemberDebt{}
+	for key, cents := range pair {
+		switch {
+		case cents > 0:
+			debts = append(debts, MemberDebt{FromMemberID: key[0], ToMemberID: key[1], Amount: float64(cents) / 100})
+		case cents < 0:
+			debts = append(debts, MemberDebt{FromMemberID: key[1], ToMemberID: key[0], Amount: float64(-cents) / 100})
+		}
+	}
+	return balances, debts
+}
+
+// Saldo en céntimos de un integrante (para el cálculo de transferencias)
+type memberBalance struct {
+	id    uint
+	cents int64
+}
+
+// Sugerir transferencias para saldar el grupo: el mayor deudor paga al mayor
+// acreedor hasta que todos queden en cero (a lo sumo n-1 transferencias)
+func SuggestSettlements(balances map[uint]float64) []MemberDebt {
+	var debtors, creditors []memberBalance
+	for id, balance := range balances {
+		cents := toCents(balance)
+		if cents < 0 {
+			debtors = append(debtors, memberBalance{id, -cents})
+		} else if cents > 0 {
+			creditors = append(creditors, memberBalance{id, cents})
+		}
+	}
+
+	transfers := []MemberDebt{}
+	for len(debtors) > 0 && len(creditors) > 0 {
+		d := largestBalance(debtors)
+		cr := largestBalance(creditors)
+
+		amount := debtors[d].cents
+		if creditors[cr].cents < amount {
+			amount = creditors[cr].cents
+		}
+		transfers = append(transfers, MemberDebt{
+			FromMemberID: debtors[d].id,
+			ToMemberID:   creditors[cr].id,
+			Amount:       float64(amount) / 100,
+		})
+
+		debtors[d].cents -= amount
+		creditors[cr].cents -= amount
+		if debtors[d].cents == 0 {
+			debtors = append(debtors[:d], debtors[d+1:]...)
+		}
+		if creditors[cr].cents == 0 {
+			creditors = append(creditors[:cr], creditors[cr+1:]...)
+		}
+	}
+	return transfers
+}
+
+// Índice del mayor saldo (desempata por id para resultados estables)
+func largestBalance(balances []memberBalance) int {
+	best := 0
+	for i, balance := range balances {
+		if balance.cents > balances[best].cents || (balance.cents == balances[best].cents && balance.id < balances[best].id) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Convertir un monto a céntimos
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
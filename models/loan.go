@@ -8,9 +8,10 @@ import (
 )
 
 type Loan struct {
-	ID        uint `json:"id" gorm:"primaryKey"`
-	UserID    uint `json:"user_id" gorm:"not null"`
-	AccountID uint `json:"account_id" gorm:"not null"` // Cuenta inicial del préstamo
+	ID        uint  `json:"id" gorm:"primaryKey"`
+	UserID    uint  `json:"user_id" gorm:"not null"`
+	AccountID uint  `json:"account_id" gorm:"not null"`        // Cuenta inicial del préstamo
+	ContactID *uint `json:"contact_id,omitempty" gorm:"index"` // Contraparte
 
 	// Información básica
 	Amount      float64 `json:"amount" gorm:"not null"`
@@ -19,20 +20,38 @@ type Loan struct {
 	Type        string  `json:"type" gorm:"not null"`                                     // 'given' o 'received'
 
 	// Control y fechas
-	Status       string     `json:"status" gorm:"default:'pending'"` // pending, partial_paid, paid
+	Status       string     `json:"status" gorm:"default:'pending'"` // pending, partial_paid, paid, settled, written_off, disputed
 	LoanDate     time.Time  `json:"loan_date" gorm:"not null"`
 	DueDate      *time.Time `json:"due_date,omitempty"`
-	InterestRate float64    `json:"interest_rate" gorm:"default:0"`
+	InterestRate float64    `json:"interest_rate" gorm:"default:0"`               // Porcentaje por RatePeriod
 	Notes        string     `json:"notes" gorm:"size:500;column:notes_encrypted"` // 🔒 ENCRIPTADO
 
+	// Configuración de interés
+	InterestType string `json:"interest_type" gorm:"default:'simple'"` // simple, compound
+	RatePeriod   string `json:"rate_period" gorm:"default:'annual'"`   // monthly, annual
+	DayCount     string `json:"day_count" gorm:"default:'actual/365'"` // actual/365, actual/360, 30/360
+
+	// Plan de cuotas (opcional)
+	InstallmentCount     int    `json:"installment_count" gorm:"default:0"`
+	InstallmentFrequency string `json:"installment_frequency,omitempty"` // weekly, biweekly, monthly
+	AmortizationMethod   string `json:"amortization_method,omitempty"`   // equal_principal, french
+
+	// Vínculo con el préstamo espejo de otro usuario
+	LinkedLoanID  *uint      `json:"linked_loan_id,omitempty"`
+	LinkedUserID  *uint      `json:"linked_user_id,omitempty"`
+	DisputedAt    *time.Time `json:"disputed_at,omitempty"`
+	DisputeReason string     `json:"dispute_reason,omitempty" gorm:"size:500;column:dispute_reason_encrypted"` // 🔒 ENCRIPTADO
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relaciones
-	User     User          `json:"-" gorm:"foreignKey:UserID"`
-	Account  Account       `json:"account,omitempty" gorm:"foreignKey:AccountID"`
-	Payments []LoanPayment `json:"payments,omitempty" gorm:"foreignKey:LoanID"`
+	User         User              `json:"-" gorm:"foreignKey:UserID"`
+	Account      Account           `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	Contact      *Contact          `json:"contact,omitempty" gorm:"foreignKey:ContactID"`
+	Payments     []LoanPayment     `json:"payments,omitempty" gorm:"foreignKey:LoanID"`
+	Installments []LoanInstallment `json:"installments,omitempty" gorm:"foreignKey:LoanID"`
 }
 
 // Hook ANTES de guardar - encriptar
@@ -46,6 +65,9 @@ func (l *Loan) BeforeSave(tx *gorm.DB) error {
 	if l.Notes != "" {
 		l.Notes = utils.EncryptField(l.Notes)
 	}
+	if l.DisputeReason != "" {
+		l.DisputeReason = utils.EncryptField(l.DisputeReason)
+	}
 	return nil
 }
 
@@ -60,6 +82,9 @@ func (l *Loan) AfterFind(tx *gorm.DB) error {
 	if l.Notes != "" {
 		l.Notes = utils.DecryptField(l.Notes)
 	}
+	if l.DisputeReason != "" {
+		l.DisputeReason = utils.DecryptField(l.DisputeReason)
+	}
 	return nil
 }
 
@@ -73,28 +98,71 @@ func (l *Loan) GetTotalPaid(db *gorm.DB) float64 {
 	return totalPaid
 }
 
-// Calcular balance pendiente
+// Calcular balance pendiente (capital + interés devengado a hoy)
 func (l *Loan) GetBalance(db *gorm.DB) float64 {
-	totalPaid := l.GetTotalPaid(db)
-	return l.Amount - totalPaid
+	return l.Snapshot(db, time.Now()).PayoffAmount
+}
+
+// Verificar si tiene plan de cuotas
+func (l *Loan) HasInstallmentPlan() bool {
+	return l.InstallmentCount > 0
+}
+
+// Status de un préstamo vinculado cuyas partes no están de acuerdo
+const LoanStatusDisputed = "disputed"
+
+// Status en los que el préstamo ya no tiene saldo pendiente
+var ClosedLoanStatuses = []string{"paid", "settled", "written_off"}
+
+// Verificar si el préstamo está cerrado (pagado, saldado o dado por perdido)
+func (l *Loan) IsClosed() bool {
+	for _, status := range ClosedLoanStatuses {
+		if l.Status == status {
+			return true
+		}
+	}
+	return false
 }
 
 // Actualizar status automáticamente SIN tocar campos encriptados
-func (l *Loan) UpdateStatus(db *gorm.DB) {
+func (l *Loan) UpdateStatus(db *gorm.DB) error {
 	totalPaid := l.GetTotalPaid(db)
+	snapshot := l.Snapshot(db, time.Now())
+	if err := l.SyncPaymentSplits(db, snapshot); err != nil {
+		return err
+	}
+	if err := l.SyncInstallments(db); err != nil {
+		return err
+	}
 
+	// Solo está cerrado cuando capital e interés están saldados; si hubo
+	// condonación queda saldado (settled) o dado por perdido (written_off)
 	var newStatus string
-	if totalPaid == 0 {
-		newStatus = "pending"
-	} else if totalPaid < l.Amount {
-		newStatus = "partial_paid"
-	} else {
+	if !snapshot.IsSettled() {
+		if totalPaid == 0 && snapshot.Forgiven == 0 {
+			newStatus = "pending"
+		} else {
+			newStatus = "partial_paid"
+		}
+	} else if snapshot.Forgiven == 0 {
 		newStatus = "paid"
+	} else if l.IsWrittenOff(db) {
+		newStatus = "written_off"
+	} else {
+		newStatus = "settled"
+	}
+
+	// Un préstamo en disputa lo sigue estando hasta que se resuelva
+	if l.IsDisputed() {
+		newStatus = LoanStatusDisputed
 	}
 
 	// Solo actualizar el status SIN pasar por hooks
 	if l.Status != newStatus {
-		db.Model(l).Update("status", newStatus)
+		if err := db.Model(l).UpdateColumn("status", newStatus).Error; err != nil {
+			return err
+		}
 		l.Status = newStatus // Actualizar el campo local también
 	}
+	return nil
 }
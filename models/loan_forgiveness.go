@@ -0,0 +1,85 @@
+package models
+
+import (
+	"cuentas-claras/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tipos de condonación
+const (
+	ForgivenessWriteOff = "write_off"   // Se da por perdido todo el saldo
+	ForgivenessPartial  = "forgiveness" // Se perdona una parte
+)
+
+// Condonación (total o parcial) del saldo de un préstamo
+//
+// Cada una genera dos transacciones en la cuenta del préstamo que se
+// compensan entre sí: una que cierra el saldo como lo haría un pago
+// (loan_settlement_in/out) y otra que registra la pérdida o ganancia
+// (bad_debt/debt_forgiveness). Así el balance de la cuenta no cambia,
+// pero el saldo del préstamo queda reconocido como gasto o ingreso.
+type LoanForgiveness struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	LoanID uint `json:"loan_id" gorm:"not null;index"`
+	UserID uint `json:"user_id" gorm:"not null"`
+
+	// Información de la condonación
+	Kind   string    `json:"kind" gorm:"not null"` // write_off, forgiveness
+	Amount float64   `json:"amount" gorm:"not null"`
+	Date   time.Time `json:"date" gorm:"not null"`
+	Reason string    `json:"reason" gorm:"size:500;column:reason_encrypted"` // 🔒 ENCRIPTADO
+
+	// Transacciones generadas
+	SettlementTransactionID uint `json:"settlement_transaction_id" gorm:"not null"`
+	TransactionID           uint `json:"transaction_id" gorm:"not null"` // Pérdida o ganancia
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// Hook ANTES de guardar - encriptar
+func (lf *LoanForgiveness) BeforeSave(tx *gorm.DB) error {
+	if lf.Reason != "" {
+		lf.Reason = utils.EncryptField(lf.Reason)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (lf *LoanForgiveness) AfterFind(tx *gorm.DB) error {
+	if lf.Reason != "" {
+		lf.Reason = utils.DecryptField(lf.Reason)
+	}
+	return nil
+}
+
+// Tipos de transacción de una condonación según el tipo de préstamo
+func ForgivenessTransactionTypes(loanType string) (settlement string, result string) {
+	if loanType == "given" {
+		return "loan_settlement_in", "bad_debt"
+	}
+	return "loan_settlement_out", "debt_forgiveness"
+}
+
+// Total condonado de un préstamo
+func (l *Loan) GetTotalForgiven(db *gorm.DB) float64 {
+	var total float64
+	db.Model(&LoanForgiveness{}).
+		Where("loan_id = ?", l.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total)
+	return total
+}
+
+// Verificar si el préstamo fue dado por perdido
+func (l *Loan) IsWrittenOff(db *gorm.DB) bool {
+	var count int64
+	db.Model(&LoanForgiveness{}).Where("loan_id = ? AND kind = ?", l.ID, ForgivenessWriteOff).Count(&count)
+	return count > 0
+}
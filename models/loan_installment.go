@@ -0,0 +1,305 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Métodos de amortización soportados
+const (
+	AmortizationEqualPrincipal = "equal_principal"
+	AmortizationFrench         = "french"
+)
+
+type LoanInstallment struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	LoanID uint `json:"loan_id" gorm:"not null;index"`
+	UserID uint `json:"user_id" gorm:"not null"`
+
+	// Información de la cuota
+	Number          int       `json:"number" gorm:"not null"`
+	DueDate         time.Time `json:"due_date" gorm:"not null"`
+	PrincipalAmount float64   `json:"principal_amount" gorm:"not null"`
+	InterestAmount  float64   `json:"interest_amount" gorm:"default:0"`
+	Amount          float64   `json:"amount" gorm:"not null"` // Capital + interés
+
+	// Seguimiento (se recalcula con Loan.UpdateStatus)
+	PaidAmount float64    `json:"paid_amount" gorm:"default:0"`
+	Status     string     `json:"status" gorm:"default:'pending'"` // pending, partial_paid, paid, overdue
+	PaidAt     *time.Time `json:"paid_at,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// Verificar si la cuota está vencida en la zona del usuario
+func (li *LoanInstallment) IsOverdue(loc *time.Location) bool {
+	return li.Status != "paid" && DaysPastDue(li.DueDate, time.Now(), loc) > 0
+}
+
+// Saldo pendiente de la cuota
+func (li *LoanInstallment) Remaining() float64 {
+	return RoundMoney(math.Max(li.Amount-li.PaidAmount, 0))
+}
+
+// Fracción de año que representa una cuota según su frecuencia
+func installmentYears(frequency string) float64 {
+	switch frequency {
+	case "weekly":
+		return 7.0 / 365
+	case "biweekly":
+		return 14.0 / 365
+	default:
+		return 1.0 / 12
+	}
+}
+
+// Fecha de vencimiento de la cuota n (0 = primera) sin desfasar fin de mes
+func installmentDueDate(first time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case "weekly":
+		return first.AddDate(0, 0, 7*n)
+	case "biweekly":
+		return first.AddDate(0, 0, 14*n)
+	default:
+		return addMonthsClamped(first, n)
+	}
+}
+
+// Sumar meses manteniendo el día o ajustándolo al último día del mes
+func addMonthsClamped(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	firstOfTarget := time.Date(y, m+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfTarget.AddDate(0, 1, -1).Day()
+	if d > lastDay {
+		d = lastDay
+	}
+	return firstOfTarget.AddDate(0, 0, d-1)
+}
+
+// Tasa nominal de un periodo de cuota (solo para calcular la cuota fija)
+func (l *Loan) installmentRate() float64 {
+	if l.InterestRate <= 0 {
+		return 0
+	}
+
+	periods := installmentYears(l.InstallmentFrequency) * l.periodsPerYear()
+	if l.InterestType == InterestTypeCompound {
+		return math.Pow(1+l.InterestRate/100, periods) - 1
+	}
+	return l.InterestRate / 100 * periods
+}
+
+// Generar el cronograma de cuotas para un capital que devenga desde from; la cuota i
+// vence firstOffset+i periodos después de anchor. El interés de cada cuota es el que
+// Snapshot devenga entre vencimientos con el conteo de días del préstamo, así pagar
+// cada cuota en su fecha salda el préstamo
+func (l *Loan) BuildInstallments(principal float64, from time.Time, anchor time.Time, firstOffset int, count int, startNumber int) []LoanInstallment {
+	if count <= 0 || principal <= 0 {
+		return nil
+	}
+
+	rate := l.installmentRate()
+	balance := principal
+
+	// Cuota fija del sistema francés
+	annuity := principal / float64(count)
+	if rate > 0 {
+		annuity = principal * rate / (1 - math.Pow(1+rate, -float64(count)))
+	}
+
+	installments := make([]LoanInstallment, count)
+	previous := from
+	for i := 0; i < count; i++ {
+		dueDate := installmentDueDate(anchor, l.InstallmentFrequency, firstOffset+i)
+		interest := RoundMoney(l.interestFor(balance, previous, dueDate))
+		previous = dueDate
+
+		var principalPart float64
+		if l.AmortizationMethod == AmortizationFrench {
+			principalPart = RoundMoney(annuity - interest)
+		} else {
+			principalPart = RoundMoney(principal / float64(count))
+		}
+
+		// La última cuota absorbe las diferencias de redondeo
+		if i == count-1 {
+			principalPart = RoundMoney(balance)
+		}
+		balance -= principalPart
+
+		installments[i] = LoanInstallment{
+			LoanID:          l.ID,
+			UserID:          l.UserID,
+			Number:          startNumber + i,
+			DueDate:         dueDate,
+			PrincipalAmount: principalPart,
+			InterestAmount:  interest,
+			Amount:          RoundMoney(principalPart + interest),
+			Status:          "pending",
+		}
+	}
+
+	return installments
+}
+
+// Asignar los pagos confirmados a las cuotas en orden y actualizar su estado
+func (l *Loan) SyncInstallments(db *gorm.DB) error {
+	var installments []LoanInstallment
+	if err := db.Where("loan_id = ?", l.ID).Order("number asc").Find(&installments).Error; err != nil {
+		return err
+	}
+	if len(installments) == 0 {
+		return nil
+	}
+
+	var payments []LoanPayment
+	if err := db.Where("loan_id = ? AND transaction_id IS NOT NULL", l.ID).Find(&payments).Error; err != nil {
+		return err
+	}
+
+	// Las condonaciones también cubren cuotas
+	var forgivenesses []LoanForgiveness
+	if err := db.Where("loan_id = ?", l.ID).Find(&forgivenesses).Error; err != nil {
+		return err
+	}
+	for _, forgiveness := range forgivenesses {
+		payments = append(payments, LoanPayment{Date: forgiveness.Date, Amount: forgiveness.Amount})
+	}
+
+	sort.SliceStable(payments, func(i, j int) bool {
+		if payments[i].Date.Equal(payments[j].Date) {
+			return payments[i].ID < payments[j].ID
+		}
+		return payments[i].Date.Before(payments[j].Date)
+	})
+
+	paid := make([]float64, len(installments))
+	paidAt := make([]*time.Time, len(installments))
+	current := 0
+	for _, payment := range payments {
+		left := payment.Amount
+		for left > 0.005 && current < len(installments) {
+			applied := math.Min(left, installments[current].Amount-paid[current])
+			paid[current] += applied
+			left -= applied
+			if paid[current] >= installments[current].Amount-0.005 {
+				date := payment.Date
+				paidAt[current] = &date
+				current++
+			}
+		}
+	}
+
+	// Vencida desde el día siguiente al vencimiento en la zona del usuario (como IsOverdue)
+	now := time.Now()
+	loc := UserLocation(db, l.UserID)
+	for i, installment := range installments {
+		status := "pending"
+		if paidAt[i] != nil {
+			status = "paid"
+		} else if DaysPastDue(installment.DueDate, now, loc) > 0 {
+			status = "overdue"
+		} else if paid[i] > 0 {
+			status = "partial_paid"
+		}
+
+		paidAmount := RoundMoney(paid[i])
+		if installment.Status == status && installment.PaidAmount == paidAmount {
+			continue
+		}
+
+		// Solo actualizar seguimiento SIN pasar por hooks
+		if err := db.Model(&LoanInstallment{}).Where("id = ?", installment.ID).
+			UpdateColumns(map[string]interface{}{
+				"paid_amount": paidAmount,
+				"status":      status,
+				"paid_at":     paidAt[i],
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reprogramar el saldo pendiente en un nuevo cronograma de cuotas
+func (l *Loan) RescheduleInstallments(db *gorm.DB, anchor time.Time, firstOffset int) error {
+	if err := l.SyncInstallments(db); err != nil {
+		return err
+	}
+
+	var installments []LoanInstallment
+	if err := db.Where("loan_id = ?", l.ID).Order("number asc").Find(&installments).Error; err != nil {
+		return err
+	}
+
+	lastNumber := 0
+	for _, installment := range installments {
+		if installment.Status == "paid" {
+			lastNumber = installment.Number
+			continue
+		}
+
+		// Cuotas sin abonos se eliminan
+		if installment.PaidAmount <= 0 {
+			if err := db.Delete(&installment).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Cuotas con abono parcial se cierran por lo ya pagado
+		ratio := installment.PaidAmount / installment.Amount
+		if err := db.Model(&LoanInstallment{}).Where("id = ?", installment.ID).
+			UpdateColumns(map[string]interface{}{
+				"principal_amount": RoundMoney(installment.PrincipalAmount * ratio),
+				"interest_amount":  RoundMoney(installment.InterestAmount * ratio),
+				"amount":           installment.PaidAmount,
+				"status":           "paid",
+			}).Error; err != nil {
+			return err
+		}
+		lastNumber = installment.Number
+	}
+
+	// El nuevo cronograma amortiza el capital pendiente desde hoy; la primera cuota
+	// cobra además el interés ya devengado (el que Snapshot tendrá a su vencimiento)
+	now := time.Now()
+	snapshot := l.Snapshot(db, now)
+	newInstallments := l.BuildInstallments(snapshot.PrincipalOutstanding, now, anchor, firstOffset, l.InstallmentCount, lastNumber+1)
+	if len(newInstallments) == 0 && snapshot.InterestAccrued > 0 {
+		// Solo queda interés: una cuota por ese monto
+		newInstallments = []LoanInstallment{{
+			LoanID:  l.ID,
+			UserID:  l.UserID,
+			Number:  lastNumber + 1,
+			DueDate: installmentDueDate(anchor, l.InstallmentFrequency, firstOffset),
+			Status:  "pending",
+		}}
+	}
+	if len(newInstallments) > 0 {
+		first := &newInstallments[0]
+		first.InterestAmount = l.Snapshot(db, first.DueDate).InterestAccrued
+		first.Amount = RoundMoney(first.PrincipalAmount + first.InterestAmount)
+
+		if err := db.Create(&newInstallments).Error; err != nil {
+			return err
+		}
+
+		// El préstamo vence con la última cuota
+		lastDueDate := newInstallments[len(newInstallments)-1].DueDate
+		if err := db.Model(l).UpdateColumn("due_date", lastDueDate).Error; err != nil {
+			return err
+		}
+		l.DueDate = &lastDueDate
+	}
+
+	return l.SyncInstallments(db)
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPayingScheduleClosesLoan(t *testing.T) {
+	tests := []struct {
+		name         string
+		interestType string
+		ratePeriod   string
+		dayCount     string
+		frequency    string
+		method       string
+	}{
+		{name: "french actual/365 monthly", interestType: InterestTypeSimple, ratePeriod: RatePeriodAnnual, dayCount: DayCountActual365, frequency: "monthly", method: AmortizationFrench},
+		{name: "equal principal actual/360 monthly", interestType: InterestTypeSimple, ratePeriod: RatePeriodAnnual, dayCount: DayCountActual360, frequency: "monthly", method: AmortizationEqualPrincipal},
+		{name: "french compound 30/360 monthly", interestType: InterestTypeCompound, ratePeriod: RatePeriodMonthly, dayCount: DayCount30360, frequency: "monthly", method: AmortizationFrench},
+		{name: "equal principal compound weekly", interestType: InterestTypeCompound, ratePeriod: RatePeriodAnnual, dayCount: DayCountActual365, frequency: "weekly", method: AmortizationEqualPrincipal},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:schedule%d?mode=memory&cache=shared", i)), &gorm.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.AutoMigrate(&User{}, &Loan{}, &LoanPayment{}, &LoanForgiveness{}, &LoanInstallment{}); err != nil {
+				t.Fatal(err)
+			}
+
+			user := User{Name: "Ana", Email: "ana@example.com", Password: "x"}
+			if err := db.Create(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			// Cuotas sobre meses de 28, 30 y 31 días
+			loanDate := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+			loan := Loan{
+				UserID:               user.ID,
+				AccountID:            1,
+				Amount:               1000,
+				Description:          "préstamo",
+				Type:                 "given",
+				Status:               "pending",
+				LoanDate:             loanDate,
+				InterestRate:         24,
+				InterestType:         tt.interestType,
+				RatePeriod:           tt.ratePeriod,
+				DayCount:             tt.dayCount,
+				InstallmentCount:     12,
+				InstallmentFrequency: tt.frequency,
+				AmortizationMethod:   tt.method,
+			}
+			if err := db.Create(&loan).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			installments := loan.BuildInstallments(loan.Amount, loan.LoanDate, loan.LoanDate, 1, loan.InstallmentCount, 1)
+			if err := db.Create(&installments).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			for n, installment := range installments {
+				transactionID := uint(n + 1)
+				payment := LoanPayment{
+					LoanID:        loan.ID,
+					UserID:        loan.UserID,
+					AccountID:     loan.AccountID,
+					Amount:        installment.Amount,
+					Date:          installment.DueDate,
+					TransactionID: &transactionID,
+				}
+				if err := db.Create(&payment).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := loan.UpdateStatus(db); err != nil {
+				t.Fatal(err)
+			}
+			if loan.Status != "paid" {
+				last := installments[len(installments)-1].DueDate
+				t.Fatalf("status = %q, want paid (payoff %.2f)", loan.Status, loan.Snapshot(db, last).PayoffAmount)
+			}
+
+			var open int64
+			db.Model(&LoanInstallment{}).Where("loan_id = ? AND status <> ?", loan.ID, "paid").Count(&open)
+			if open != 0 {
+				t.Errorf("%d installments not paid", open)
+			}
+		})
+	}
+}
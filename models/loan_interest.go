@@ -0,0 +1,231 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tipos de interés soportados
+const (
+	InterestTypeSimple   = "simple"
+	InterestTypeCompound = "compound"
+)
+
+// Periodo en el que se expresa InterestRate
+const (
+	RatePeriodMonthly = "monthly"
+	RatePeriodAnnual  = "annual"
+)
+
+// Convenciones de conteo de días
+const (
+	DayCountActual365 = "actual/365"
+	DayCountActual360 = "actual/360"
+	DayCount30360     = "30/360"
+)
+
+// Reparto de un pago confirmado entre interés y capital
+type LoanPaymentSplit struct {
+	PaymentID uint      `json:"payment_id"`
+	Date      time.Time `json:"date"`
+	Amount    float64   `json:"amount"`
+	Interest  float64   `json:"interest"`
+	Principal float64   `json:"principal"`
+}
+
+// Estado calculado del préstamo a una fecha dada
+type LoanSnapshot struct {
+	AsOf                 time.Time          `json:"as_of"`
+	PrincipalOutstanding float64            `json:"principal_outstanding"`
+	InterestAccrued      float64            `json:"interest_accrued"` // Interés devengado y no pagado
+	TotalInterest        float64            `json:"total_interest"`   // Interés devengado desde el inicio
+	InterestPaid         float64            `json:"interest_paid"`
+	PrincipalPaid        float64            `json:"principal_paid"`
+	PayoffAmount         float64            `json:"payoff_amount"`
+	Forgiven             float64            `json:"forgiven"`
+	Splits               []LoanPaymentSplit `json:"splits"`
+	ForgivenSplits       []LoanPaymentSplit `json:"forgiven_splits"` // Condonaciones aplicadas
+}
+
+// Verificar si capital e interés están saldados
+func (s LoanSnapshot) IsSettled() bool {
+	return s.PrincipalOutstanding <= 0.005 && s.InterestAccrued <= 0.005
+}
+
+// Fracción de año entre dos fechas según la convención de conteo de días
+func (l *Loan) yearFraction(from, to time.Time) float64 {
+	if !to.After(from) {
+		return 0
+	}
+
+	switch l.DayCount {
+	case DayCountActual360:
+		return to.Sub(from).Hours() / 24 / 360
+	case DayCount30360:
+		y1, m1, d1 := from.Date()
+		y2, m2, d2 := to.Date()
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		days := 360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1)
+		return float64(days) / 360
+	default:
+		return to.Sub(from).Hours() / 24 / 365
+	}
+}
+
+// Periodos de capitalización por año según RatePeriod
+func (l *Loan) periodsPerYear() float64 {
+	if l.RatePeriod == RatePeriodMonthly {
+		return 12
+	}
+	return 1
+}
+
+// Interés generado por una base entre dos fechas (InterestRate es un porcentaje)
+func (l *Loan) interestFor(base float64, from, to time.Time) float64 {
+	if l.InterestRate <= 0 || base <= 0 {
+		return 0
+	}
+
+	periodRate := l.InterestRate / 100
+	periods := l.yearFraction(from, to) * l.periodsPerYear()
+
+	if l.InterestType == InterestTypeCompound {
+		return base * (math.Pow(1+periodRate, periods) - 1)
+	}
+	return base * periodRate * periods
+}
+
+// Movimiento que reduce el saldo: pago confirmado o condonación
+type loanSettlementEvent struct {
+	paymentID uint
+	date      time.Time
+	amount    float64
+	forgiven  bool
+}
+
+// Calcular capital, interés y reparto de pagos confirmados hasta asOf
+func (l *Loan) Snapshot(db *gorm.DB, asOf time.Time) LoanSnapshot {
+	var payments []LoanPayment
+	db.Where("loan_id = ? AND transaction_id IS NOT NULL AND date <= ?", l.ID, asOf).
+		Find(&payments)
+
+	var forgivenesses []LoanForgiveness
+	db.Where("loan_id = ? AND date <= ?", l.ID, asOf).Find(&forgivenesses)
+
+	events := make([]loanSettlementEvent, 0, len(payments)+len(forgivenesses))
+	for _, payment := range payments {
+		events = append(events, loanSettlementEvent{paymentID: payment.ID, date: payment.Date, amount: payment.Amount})
+	}
+	for _, forgiveness := range forgivenesses {
+		events = append(events, loanSettlementEvent{date: forgiveness.Date, amount: forgiveness.Amount, forgiven: true})
+	}
+
+	// Los pagos van antes que las condonaciones del mismo día
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].date.Equal(events[j].date) {
+			if events[i].forgiven != events[j].forgiven {
+				return !events[i].forgiven
+			}
+			return events[i].paymentID < events[j].paymentID
+		}
+		return events[i].date.Before(events[j].date)
+	})
+
+	snapshot := LoanSnapshot{AsOf: asOf, Splits: []LoanPaymentSplit{}, ForgivenSplits: []LoanPaymentSplit{}}
+	principal := l.Amount
+	unpaidInterest := 0.0
+	last := l.LoanDate
+
+	accrue := func(until time.Time) {
+		if !until.After(last) {
+			return
+		}
+		base := principal
+		if l.InterestType == InterestTypeCompound {
+			base += unpaidInterest
+		}
+		// El interés se cobra en céntimos en cada movimiento (como en las cuotas)
+		accrued := RoundMoney(l.interestFor(base, last, until))
+		unpaidInterest += accrued
+		snapshot.TotalInterest += accrued
+		last = until
+	}
+
+	for _, event := range events {
+		accrue(event.date)
+
+		// Se cubre primero el interés pendiente y luego el capital
+		toInterest := math.Min(event.amount, unpaidInterest)
+		toPrincipal := math.Min(event.amount-toInterest, principal)
+		unpaidInterest -= toInterest
+		principal -= toPrincipal
+
+		if event.forgiven {
+			snapshot.Forgiven += toInterest + toPrincipal
+			snapshot.ForgivenSplits = append(snapshot.ForgivenSplits, LoanPaymentSplit{
+				Date:      event.date,
+				Amount:    event.amount,
+				Interest:  RoundMoney(toInterest),
+				Principal: RoundMoney(toPrincipal),
+			})
+			continue
+		}
+
+		snapshot.InterestPaid += toInterest
+		snapshot.PrincipalPaid += toPrincipal
+		snapshot.Splits = append(snapshot.Splits, LoanPaymentSplit{
+			PaymentID: event.paymentID,
+			Date:      event.date,
+			Amount:    event.amount,
+			Interest:  RoundMoney(toInterest),
+			Principal: RoundMoney(toPrincipal),
+		})
+	}
+
+	accrue(asOf)
+
+	snapshot.PrincipalOutstanding = RoundMoney(principal)
+	snapshot.InterestAccrued = RoundMoney(unpaidInterest)
+	snapshot.TotalInterest = RoundMoney(snapshot.TotalInterest)
+	snapshot.InterestPaid = RoundMoney(snapshot.InterestPaid)
+	snapshot.PrincipalPaid = RoundMoney(snapshot.PrincipalPaid)
+	snapshot.PayoffAmount = RoundMoney(principal + unpaidInterest)
+	snapshot.Forgiven = RoundMoney(snapshot.Forgiven)
+	return snapshot
+}
+
+// Guardar el reparto interés/capital en cada pago SIN pasar por hooks
+func (l *Loan) SyncPaymentSplits(db *gorm.DB, snapshot LoanSnapshot) error {
+	// Los pagos sin confirmar (o revertidos) no tienen reparto
+	if err := db.Model(&LoanPayment{}).Where("loan_id = ? AND transaction_id IS NULL", l.ID).
+		UpdateColumns(map[string]interface{}{
+			"interest_amount":  0,
+			"principal_amount": 0,
+		}).Error; err != nil {
+		return err
+	}
+
+	for _, split := range snapshot.Splits {
+		if err := db.Model(&LoanPayment{}).Where("id = ?", split.PaymentID).
+			UpdateColumns(map[string]interface{}{
+				"interest_amount":  split.Interest,
+				"principal_amount": split.Principal,
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redondear montos a 2 decimales
+func RoundMoney(x float64) float64 {
+	return math.Round(x*100) / 100
+}
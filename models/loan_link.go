@@ -0,0 +1,175 @@
+package models
+
+import (
+	"cuentas-claras/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Estados de una invitación para vincular un préstamo con otro usuario
+const (
+	LoanLinkPending  = "pending"
+	LoanLinkAccepted = "accepted"
+	LoanLinkDeclined = "declined"
+	LoanLinkRevoked  = "revoked"
+)
+
+// Invitación a otro usuario registrado para llevar el mismo préstamo
+type LoanLink struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	LoanID       uint       `json:"loan_id" gorm:"not null;index"` // Préstamo de quien invita
+	InviterID    uint       `json:"inviter_id" gorm:"not null;index"`
+	InviteeID    uint       `json:"invitee_id" gorm:"not null;index"`
+	MirrorLoanID *uint      `json:"mirror_loan_id,omitempty"` // Préstamo de quien acepta
+	Status       string     `json:"status" gorm:"not null;default:'pending'"`
+	Message      string     `json:"message" gorm:"size:500;column:message_encrypted"` // 🔒 ENCRIPTADO
+	RespondedAt  *time.Time `json:"responded_at,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	Loan    Loan `json:"-" gorm:"foreignKey:LoanID"`
+	Inviter User `json:"-" gorm:"foreignKey:InviterID"`
+	Invitee User `json:"-" gorm:"foreignKey:InviteeID"`
+}
+
+// Hook ANTES de guardar - encriptar
+func (ll *LoanLink) BeforeSave(tx *gorm.DB) error {
+	if ll.Message != "" {
+		ll.Message = utils.EncryptField(ll.Message)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (ll *LoanLink) AfterFind(tx *gorm.DB) error {
+	if ll.Message != "" {
+		ll.Message = utils.DecryptField(ll.Message)
+	}
+	return nil
+}
+
+// Tipo del préstamo espejo (lo que para uno es dado, para el otro es recibido)
+func MirrorLoanType(loanType string) string {
+	if loanType == "given" {
+		return "received"
+	}
+	return "given"
+}
+
+// Verificar si el préstamo está vinculado con el de otro usuario
+func (l *Loan) IsLinked() bool {
+	return l.LinkedLoanID != nil
+}
+
+// Verificar si el préstamo está en disputa
+func (l *Loan) IsDisputed() bool {
+	return l.DisputedAt != nil
+}
+
+// Marcar el préstamo y su espejo en disputa SIN pasar por hooks
+func DisputeLoan(db *gorm.DB, loan *Loan, reason string) error {
+	now := time.Now()
+	ids := []uint{loan.ID}
+	if loan.LinkedLoanID != nil {
+		ids = append(ids, *loan.LinkedLoanID)
+	}
+
+	if err := db.Model(&Loan{}).Where("id IN ?", ids).UpdateColumns(map[string]interface{}{
+		"disputed_at":              now,
+		"dispute_reason_encrypted": utils.EncryptField(reason),
+		"status":                   LoanStatusDisputed,
+	}).Error; err != nil {
+		return err
+	}
+
+	loan.DisputedAt = &now
+	loan.DisputeReason = reason
+	loan.Status = LoanStatusDisputed
+	return nil
+}
+
+// Marcar un pago y su copia en disputa (y con ellos ambos préstamos)
+func DisputeLoanPayment(db *gorm.DB, payment *LoanPayment, reason string) error {
+	now := time.Now()
+	ids := []uint{payment.ID}
+	if payment.LinkedPaymentID != nil {
+		ids = append(ids, *payment.LinkedPaymentID)
+	}
+
+	if err := db.Model(&LoanPayment{}).Where("id IN ?", ids).UpdateColumns(map[string]interface{}{
+		"disputed_at":              now,
+		"dispute_reason_encrypted": utils.EncryptField(reason),
+	}).Error; err != nil {
+		return err
+	}
+	payment.DisputedAt = &now
+	payment.DisputeReason = reason
+
+	var loan Loan
+	if err := db.First(&loan, payment.LoanID).Error; err != nil {
+		return err
+	}
+	return DisputeLoan(db, &loan, reason)
+}
+
+// Resolver la disputa en ambos lados y recalcular sus status
+func ResolveLoanDispute(db *gorm.DB, loan *Loan) error {
+	ids := []uint{loan.ID}
+	if loan.LinkedLoanID != nil {
+		ids = append(ids, *loan.LinkedLoanID)
+	}
+
+	cleared := map[string]interface{}{
+		"disputed_at":              nil,
+		"dispute_reason_encrypted": "",
+	}
+	if err := db.Model(&Loan{}).Where("id IN ?", ids).UpdateColumns(cleared).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&LoanPayment{}).Where("loan_id IN ?", ids).UpdateColumns(cleared).Error; err != nil {
+		return err
+	}
+
+	var loans []Loan
+	if err := db.Where("id IN ?", ids).Find(&loans).Error; err != nil {
+		return err
+	}
+	for i := range loans {
+		if err := loans[i].UpdateStatus(db); err != nil {
+			return err
+		}
+		if loans[i].ID == loan.ID {
+			*loan = loans[i]
+		}
+	}
+	return nil
+}
+
+// Crear la copia pendiente de un pago en el préstamo de la contraparte
+func MirrorLoanPayment(tx *gorm.DB, payment *LoanPayment, counterpart *Loan) error {
+	mirror := LoanPayment{
+		LoanID:          counterpart.ID,
+		UserID:          counterpart.UserID,
+		AccountID:       counterpart.AccountID,
+		Amount:          payment.Amount,
+		Date:            payment.Date,
+		Description:     payment.Description,
+		LinkedPaymentID: &payment.ID,
+		IsMirror:        true,
+		// TransactionID permanece nil: la contraparte lo confirma
+	}
+
+	if err := tx.Create(&mirror).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(payment).UpdateColumn("linked_payment_id", mirror.ID).Error; err != nil {
+		return err
+	}
+	payment.LinkedPaymentID = &mirror.ID
+	return nil
+}
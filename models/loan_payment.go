@@ -19,18 +19,32 @@ type LoanPayment struct {
 	Description string    `json:"description" gorm:"not null;column:description_encrypted"` // 🔒 ENCRIPTADO
 	Notes       string    `json:"notes" gorm:"size:500;column:notes_encrypted"`             // 🔒 ENCRIPTADO
 
+	// Reparto del pago (se recalcula con Loan.UpdateStatus)
+	InterestAmount  float64 `json:"interest_amount" gorm:"default:0"`
+	PrincipalAmount float64 `json:"principal_amount" gorm:"default:0"`
+
 	// Control de confirmación
 	TransactionID *uint `json:"transaction_id,omitempty"` // NULL = pendiente, ID = confirmado
 
+	// Copia del pago en el préstamo vinculado de la contraparte
+	LinkedPaymentID *uint      `json:"linked_payment_id,omitempty"`
+	IsMirror        bool       `json:"is_mirror" gorm:"default:false"` // Registrado por la contraparte
+	DisputedAt      *time.Time `json:"disputed_at,omitempty"`
+	DisputeReason   string     `json:"dispute_reason,omitempty" gorm:"size:500;column:dispute_reason_encrypted"` // 🔒 ENCRIPTADO
+
+	// Pago reportado por la contraparte desde un enlace compartido
+	ReportedViaShareID *uint `json:"reported_via_share_id,omitempty"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relaciones
-	User        User         `json:"-" gorm:"foreignKey:UserID"`
-	Loan        Loan         `json:"loan,omitempty" gorm:"foreignKey:LoanID"`
-	Account     Account      `json:"account,omitempty" gorm:"foreignKey:AccountID"`
-	Transaction *Transaction `json:"transaction,omitempty" gorm:"foreignKey:TransactionID"`
+	User        User                  `json:"-" gorm:"foreignKey:UserID"`
+	Loan        Loan                  `json:"loan,omitempty" gorm:"foreignKey:LoanID"`
+	Account     Account               `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	Transaction *Transaction          `json:"transaction,omitempty" gorm:"foreignKey:TransactionID"`
+	Reversals   []LoanPaymentReversal `json:"reversals,omitempty" gorm:"foreignKey:LoanPaymentID"`
 }
 
 // Hook ANTES de guardar - encriptar
@@ -41,6 +55,9 @@ func (lp *LoanPayment) BeforeSave(tx *gorm.DB) error {
 	if lp.Notes != "" {
 		lp.Notes = utils.EncryptField(lp.Notes)
 	}
+	if lp.DisputeReason != "" {
+		lp.DisputeReason = utils.EncryptField(lp.DisputeReason)
+	}
 	return nil
 }
 
@@ -52,6 +69,9 @@ func (lp *LoanPayment) AfterFind(tx *gorm.DB) error {
 	if lp.Notes != "" {
 		lp.Notes = utils.DecryptField(lp.Notes)
 	}
+	if lp.DisputeReason != "" {
+		lp.DisputeReason = utils.DecryptField(lp.DisputeReason)
+	}
 	return nil
 }
 
@@ -59,3 +79,8 @@ func (lp *LoanPayment) AfterFind(tx *gorm.DB) error {
 func (lp *LoanPayment) IsConfirmed() bool {
 	return lp.TransactionID != nil
 }
+
+// Verificar si está en disputa
+func (lp *LoanPayment) IsDisputed() bool {
+	return lp.DisputedAt != nil
+}
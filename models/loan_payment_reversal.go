@@ -0,0 +1,45 @@
+package models
+
+import (
+	"cuentas-claras/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Historial de reversiones de pagos confirmados
+type LoanPaymentReversal struct {
+	ID            uint `json:"id" gorm:"primaryKey"`
+	LoanPaymentID uint `json:"loan_payment_id" gorm:"not null;index"`
+	LoanID        uint `json:"loan_id" gorm:"not null"`
+	UserID        uint `json:"user_id" gorm:"not null"`
+	TransactionID uint `json:"transaction_id" gorm:"not null"` // Transacción anulada
+
+	// Información de la reversión
+	Amount     float64   `json:"amount" gorm:"not null"`
+	Reason     string    `json:"reason" gorm:"size:500;column:reason_encrypted"` // 🔒 ENCRIPTADO
+	ReversedAt time.Time `json:"reversed_at" gorm:"not null"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// Hook ANTES de guardar - encriptar
+func (r *LoanPaymentReversal) BeforeSave(tx *gorm.DB) error {
+	if r.Reason != "" {
+		r.Reason = utils.EncryptField(r.Reason)
+	}
+	return nil
+}
+
+// Hook DESPUÉS de encontrar - desencriptar
+func (r *LoanPaymentReversal) AfterFind(tx *gorm.DB) error {
+	if r.Reason != "" {
+		r.Reason = utils.DecryptField(r.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Enlace para que la contraparte (sin cuenta) vea el estado de cuenta de un préstamo
+type LoanShare struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	LoanID  uint   `json:"loan_id" gorm:"not null;index"`
+	UserID  uint   `json:"user_id" gorm:"not null"`
+	ShareID string `json:"-" gorm:"unique;not null;index"` // Identificador firmado dentro del token
+
+	// Control
+	ExpiresAt           time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt           *time.Time `json:"revoked_at,omitempty"`
+	AllowPaymentReports bool       `json:"allow_payment_reports" gorm:"default:true"`
+	LastAccessAt        *time.Time `json:"last_access_at,omitempty"`
+	AccessCount         int        `json:"access_count" gorm:"default:0"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	Loan Loan `json:"-" gorm:"foreignKey:LoanID"`
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// Registro de cada acceso a un enlace compartido (incluidos los rechazados)
+type LoanShareAccess struct {
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	LoanShareID   uint   `json:"loan_share_id" gorm:"not null;index"`
+	Action        string `json:"action" gorm:"not null"` // view_statement, report_payment
+	Allowed       bool   `json:"allowed"`
+	Reason        string `json:"reason,omitempty"` // expired, revoked, reports_disabled, invalid_request
+	LoanPaymentID *uint  `json:"loan_payment_id,omitempty"`
+	IPAddress     string `json:"ip_address"`
+	UserAgent     string `json:"user_agent"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Verificar si el enlace fue revocado
+func (ls *LoanShare) IsRevoked() bool {
+	return ls.RevokedAt != nil
+}
+
+// Verificar si el enlace ya venció
+func (ls *LoanShare) IsExpired() bool {
+	return time.Now().After(ls.ExpiresAt)
+}
+
+// Motivo por el que el enlace no puede usarse ("" si está vigente)
+func (ls *LoanShare) DenyReason() string {
+	if ls.IsRevoked() {
+		return "revoked"
+	}
+	if ls.IsExpired() {
+		return "expired"
+	}
+	return ""
+}
+
+// Registrar un acceso y actualizar el contador SIN pasar por hooks
+func (ls *LoanShare) RecordAccess(db *gorm.DB, access LoanShareAccess) error {
+	access.LoanShareID = ls.ID
+	if err := db.Create(&access).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return db.Model(ls).UpdateColumns(map[string]interface{}{
+		"last_access_at": now,
+		"access_count":   gorm.Expr("access_count + 1"),
+	}).Error
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Canales de notificación
+const (
+	ChannelPush    = "push"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInApp   = "in_app"
+)
+
+// Estados de un envío
+const (
+	DeliveryPending  = "pending"
+	DeliveryRetrying = "retrying"
+	DeliverySent     = "sent"
+	DeliveryFailed   = "failed"
+)
+
+// Envío de un recordatorio por un canal a un destino, con sus intentos
+type NotificationDelivery struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	UserID     uint   `json:"user_id" gorm:"not null;index"`
+	ReminderID uint   `json:"reminder_id" gorm:"not null;index"`
+	Channel    string `json:"channel" gorm:"not null"`
+
+	// Destino: el dispositivo (push) o una descripción sin secretos
+	DeviceSessionID *uint  `json:"device_session_id,omitempty"`
+	Target          string `json:"target"`
+
+	Status        string     `json:"status" gorm:"not null;default:'pending';index"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tipos de notificación con preferencias propias
+const (
+	NotifyRecurringDue = "recurring_due"
+	NotifyOverdue      = "overdue"
+	NotifyLoanDue      = "loan_due"
+	NotifyBudget       = "budget"
+	NotifySecurity     = "security"
+	NotifyWeeklyDigest = "weekly_digest"
+)
+
+var NotificationTypes = []string{NotifyRecurringDue, NotifyOverdue, NotifyLoanDue, NotifyBudget, NotifySecurity, NotifyWeeklyDigest}
+
+// Preferencias de un tipo de notificación. Sin fila se usan las por defecto
+// (DefaultNotificationPreference)
+type NotificationPreference struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_notification_preference"`
+	Type   string `json:"type" gorm:"not null;uniqueIndex:idx_notification_preference"`
+
+	Enabled bool `json:"enabled"`
+
+	// Canales; se combinan con los interruptores generales del perfil
+	Push    bool `json:"push"`
+	Email   bool `json:"email"`
+	Webhook bool `json:"webhook"`
+	InApp   bool `json:"in_app"`
+
+	// Días antes del vencimiento en que se avisa ("2,1,0"). Solo recurring_due y loan_due
+	LeadDays string `json:"-"`
+
+	// Escalamiento (solo overdue): cada cuántos días se repite el aviso (nil = 1 en
+	// recurrentes y 3 en préstamos; 0 = una sola vez), prioridad del primero y cada
+	// cuántos avisos sube un nivel (0 = nunca)
+	RepeatEveryDays *int   `json:"repeat_every_days"`
+	StartPriority   string `json:"start_priority"`
+	EscalateEvery   int    `json:"escalate_every"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Valores por defecto: todo activo, con los días de aviso de siempre
+func DefaultNotificationPreference(userID uint, notificationType string) NotificationPreference {
+	preference := NotificationPreference{
+		UserID:  userID,
+		Type:    notificationType,
+		Enabled: true,
+		Push:    true,
+		Email:   true,
+		Webhook: true,
+		InApp:   true,
+	}
+	switch notificationType {
+	case NotifyRecurringDue:
+		preference.LeadDays = "2,1,0"
+	case NotifyLoanDue:
+		preference.LeadDays = "3,0"
+	case NotifyOverdue:
+		preference.StartPriority = "high"
+	}
+	return preference
+}
+
+// Preferencia guardada o la por defecto
+func LoadNotificationPreference(db *gorm.DB, userID uint, notificationType string) NotificationPreference {
+	var preference NotificationPreference
+	db.Where("user_id = ? AND type = ?", userID, notificationType).Limit(1).Find(&preference)
+	if preference.ID == 0 {
+		return DefaultNotificationPreference(userID, notificationType)
+	}
+	return preference
+}
+
+// Tipo de notificación de un recordatorio según su plantilla ("" si no tiene preferencias:
+// personalizados, registros automáticos, pagos reportados)
+func NotificationTypeFor(template string) string {
+	switch template {
+	case TemplateRecurringUpcoming, TemplateRecurringDueTomorrow, TemplateRecurringDueToday:
+		return NotifyRecurringDue
+	case TemplateRecurringOverdue, TemplateLoanOverdue:
+		return NotifyOverdue
+	case TemplateLoanUpcoming, TemplateLoanDueToday:
+		return NotifyLoanDue
+	case TemplateSecurityNewSession:
+		return NotifySecurity
+	}
+	return ""
+}
+
+func (p *NotificationPreference) UsesLeadDays() bool {
+	return p.Type == NotifyRecurringDue || p.Type == NotifyLoanDue
+}
+
+func (p *NotificationPreference) UsesEscalation() bool {
+	return p.Type == NotifyOverdue
+}
+
+// Si el tipo se envía por el canal
+func (p *NotificationPreference) AllowsChannel(channel string) bool {
+	if !p.Enabled {
+		return false
+	}
+	switch channel {
+	case ChannelPush:
+		return p.Push
+	case ChannelEmail:
+		return p.Email
+	case ChannelWebhook:
+		return p.Webhook
+	case ChannelInApp:
+		return p.InApp
+	}
+	return false
+}
+
+// Días de aviso, de mayor a menor
+func (p *NotificationPreference) LeadDayList() []int {
+	days := []int{}
+	for _, part := range strings.Split(p.LeadDays, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			days = append(days, n)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return days
+}
+
+// Guardar los días de aviso sin repetidos
+func (p *NotificationPreference) SetLeadDays(days []int) {
+	seen := map[int]bool{}
+	parts := []string{}
+	sorted := append([]int(nil), days...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	for _, n := range sorted {
+		if !seen[n] {
+			seen[n] = true
+			parts = append(parts, strconv.Itoa(n))
+		}
+	}
+	p.LeadDays = strings.Join(parts, ",")
+}
+
+// Cada cuántos días repetir el aviso de vencido (fallback si no se configuró)
+func (p *NotificationPreference) RepeatDays(fallback int) int {
+	if p.RepeatEveryDays == nil {
+		return fallback
+	}
+	return *p.RepeatEveryDays
+}
+
+// Prioridad del aviso según cuántos se enviaron antes por el mismo vencimiento
+func (p *NotificationPreference) PriorityFor(previousAlerts int) string {
+	level := 0
+	for i, priority := range ReminderPriorities {
+		if priority == p.StartPriority {
+			level = i
+		}
+	}
+	if p.EscalateEvery > 0 {
+		level += previousAlerts / p.EscalateEvery
+	}
+	if level >= len(ReminderPriorities) {
+		level = len(ReminderPriorities) - 1
+	}
+	return ReminderPriorities[level]
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// Frecuencias antiguas, aceptadas en la API y convertidas a RRULE
+var LegacyFrequencies = []string{"daily", "weekly", "monthly", "yearly"}
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+// Convertir una frecuencia antigua o una RRULE (RFC 5545) a la forma guardada: "FREQ=...;..."
+// El inicio (DTSTART) no va en la regla: siempre es el StartDate del registro
+func NormalizeRecurrence(value string, start time.Time) (string, error) {
+	value = strings.TrimSpace(value)
+	if isLegacyFrequency(value) {
+		return LegacyRecurrence(value, start), nil
+	}
+
+	value = strings.TrimPrefix(strings.ToUpper(value), "RRULE:")
+	option, err := rrule.StrToROption(value)
+	if err != nil || !option.Dtstart.IsZero() {
+		return "", ErrInvalidRecurrence
+	}
+
+	// Nada más frecuente que diario para gastos
+	if option.Freq > rrule.DAILY || len(option.Byhour) > 0 || len(option.Byminute) > 0 || len(option.Bysecond) > 0 {
+		return "", ErrInvalidRecurrence
+	}
+
+	option.Dtstart = start
+	if _, err := rrule.NewRRule(*option); err != nil {
+		return "", ErrInvalidRecurrence
+	}
+	return option.RRuleString(), nil
+}
+
+// RRULE equivalente a una frecuencia antigua anclada en start.
+// Los días 29-31 se ajustan al último día del mes en vez de saltar al siguiente
+func LegacyRecurrence(frequency string, start time.Time) string {
+	switch frequency {
+	case "daily":
+		return "FREQ=DAILY"
+	case "weekly":
+		return "FREQ=WEEKLY"
+	case "monthly":
+		return "FREQ=MONTHLY;" + clampedMonthDay(start.Day())
+	case "yearly":
+		return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;%s", int(start.Month()), clampedMonthDay(start.Day()))
+	default:
+		return frequency
+	}
+}
+
+// Regla de recurrencia con DTSTART en start
+func RecurrenceRule(rule string, start time.Time) (*rrule.RRule, error) {
+	option, err := rrule.StrToROption(rule)
+	if err != nil {
+		return nil, err
+	}
+	option.Dtstart = start
+	return rrule.NewRRule(*option)
+}
+
+func isLegacyFrequency(value string) bool {
+	for _, frequency := range LegacyFrequencies {
+		if value == frequency {
+			return true
+		}
+	}
+	return false
+}
+
+// BYMONTHDAY=d, o "el día d o el último que exista" para d > 28
+func clampedMonthDay(day int) string {
+	if day <= 28 {
+		return "BYMONTHDAY=" + strconv.Itoa(day)
+	}
+	days := make([]string, 0, day-27)
+	for d := 28; d <= day; d++ {
+		days = append(days, strconv.Itoa(d))
+	}
+	return "BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Estados de una ocurrencia (un periodo de la plantilla)
+const (
+	OccurrenceDue       = "due"
+	OccurrencePaid      = "paid"
+	OccurrencePartial   = "partially_paid"
+	OccurrenceSkipped   = "skipped"
+	OccurrencePostponed = "postponed"
+)
+
+var OccurrenceStatuses = []string{OccurrenceDue, OccurrencePaid, OccurrencePartial, OccurrenceSkipped, OccurrencePostponed}
+
+// Estados que aún esperan un pago
+var OpenOccurrenceStatuses = []string{OccurrenceDue, OccurrencePartial, OccurrencePostponed}
+
+var ErrOccurrenceClosed = errors.New("occurrence already closed")
+
+type RecurringOccurrence struct {
+	ID                     uint       `json:"id" gorm:"primaryKey"`
+	RecurringTransactionID uint       `json:"recurring_transaction_id" gorm:"not null;uniqueIndex:idx_recurring_occurrence"`
+	UserID                 uint       `json:"user_id" gorm:"not null;index"`
+	ScheduledDate          time.Time  `json:"scheduled_date" gorm:"not null;uniqueIndex:idx_recurring_occurrence"` // Fecha según la regla
+	DueDate                time.Time  `json:"due_date" gorm:"not null"`                                            // Cambia al posponer
+	Status                 string     `json:"status" gorm:"not null;default:'due'"`
+	Amount                 float64    `json:"amount" gorm:"not null"` // Monto esperado del periodo
+	PaidAmount             float64    `json:"paid_amount" gorm:"default:0"`
+	Payments               int        `json:"payments" gorm:"default:0"`
+	PaidAt                 *time.Time `json:"paid_at,omitempty"`        // Fecha del último pago
+	TransactionID          *uint      `json:"transaction_id,omitempty"` // Último pago registrado
+	Note                   string     `json:"note,omitempty" gorm:"size:255"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+
+	// Relaciones
+	Transaction *Transaction `json:"transaction,omitempty" gorm:"foreignKey:TransactionID"`
+}
+
+// Verificar si status es un estado de ocurrencia válido
+func IsOccurrenceStatus(status string) bool {
+	for _, valid := range OccurrenceStatuses {
+		if status == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Verificar si la ocurrencia aún espera un pago
+func (o *RecurringOccurrence) IsOpen() bool {
+	for _, status := range OpenOccurrenceStatuses {
+		if o.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Monto que falta para completar el periodo
+func (o *RecurringOccurrence) Remaining() float64 {
+	remaining := RoundMoney(o.Amount - o.PaidAmount)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Días de atraso del pago respecto al vencimiento (la fecha pospuesta si se pospuso),
+// en la zona del usuario (0 si fue a tiempo)
+func (o *RecurringOccurrence) DaysLate(loc *time.Location) int {
+	if o.PaidAt == nil {
+		return 0
+	}
+	if days := DaysPastDue(o.DueDate, *o.PaidAt, loc); days > 0 {
+		return days
+	}
+	return 0
+}
+
+// Ocurrencia pendiente de la plantilla; si aún no existe, se crea para NextDueDate.
+// Devuelve ErrOccurrenceClosed si ese periodo ya se pagó u omitió
+func CurrentOccurrence(db *gorm.DB, rt *RecurringTransaction) (*RecurringOccurrence, error) {
+	var occurrence RecurringOccurrence
+	err := db.Where("recurring_transaction_id = ? AND status IN ?", rt.ID, OpenOccurrenceStatuses).
+		Order("scheduled_date ASC").First(&occurrence).Error
+	if err == nil {
+		return &occurrence, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var closed int64
+	db.Model(&RecurringOccurrence{}).
+		Where("recurring_transaction_id = ? AND scheduled_date = ?", rt.ID, rt.NextDueDate).
+		Count(&closed)
+	if closed > 0 {
+		return nil, ErrOccurrenceClosed
+	}
+
+	occurrence = RecurringOccurrence{
+		RecurringTransactionID: rt.ID,
+		UserID:                 rt.UserID,
+		ScheduledDate:          rt.NextDueDate,
+		DueDate:                rt.NextDueDate,
+		Status:                 OccurrenceDue,
+		Amount:                 rt.Amount,
+	}
+	if err := db.Create(&occurrence).Error; err != nil {
+		return nil, err
+	}
+	return &occurrence, nil
+}
+
+// Ocurrencia que pagó la transacción, según su clave de idempotencia
+// (OccurrenceKey); nil si la transacción no la registró una plantilla
+func OccurrenceForTransaction(db *gorm.DB, transaction *Transaction) (*RecurringOccurrence, error) {
+	if transaction.IdempotencyKey == nil {
+		return nil, nil
+	}
+	parts := strings.Split(*transaction.IdempotencyKey, ":")
+	if len(parts) < 3 || parts[0] != "recurring_transaction" {
+		return nil, nil
+	}
+	recurringID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+
+	var occurrences []RecurringOccurrence
+	if err := db.Where("recurring_transaction_id = ? AND user_id = ?", recurringID, transaction.UserID).
+		Find(&occurrences).Error; err != nil {
+		return nil, err
+	}
+	for i := range occurrences {
+		if occurrences[i].ScheduledDate.Format("2006-01-02") == parts[2] {
+			return &occurrences[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Descontar un pago revertido y volver a abrir el periodo. Payments no baja: cuenta
+// los pagos registrados y da la clave del siguiente (la revertida sigue ocupada)
+func (o *RecurringOccurrence) Reopen(db *gorm.DB, amount float64, transactionID uint) error {
+	o.PaidAmount = RoundMoney(math.Max(o.PaidAmount-amount, 0))
+	switch {
+	case o.PaidAmount > 0:
+		o.Status = OccurrencePartial
+	case !o.DueDate.Equal(o.ScheduledDate):
+		o.Status = OccurrencePostponed
+	default:
+		o.Status = OccurrenceDue
+	}
+	if o.PaidAmount == 0 {
+		o.PaidAt = nil
+	}
+	if o.TransactionID != nil && *o.TransactionID == transactionID {
+		o.TransactionID = nil
+	}
+	return db.Save(o).Error
+}
+
+// Resumen de puntualidad de un conjunto de ocurrencias
+type AdherenceSummary struct {
+	Total         int     `json:"total"`
+	OnTime        int     `json:"on_time"`
+	Late          int     `json:"late"`
+	PartiallyPaid int     `json:"partially_paid"`
+	Skipped       int     `json:"skipped"`
+	Postponed     int     `json:"postponed"`
+	Overdue       int     `json:"overdue"`
+	OnTimeRate    float64 `json:"on_time_rate"` // Porcentaje de pagos a tiempo sobre los pagados
+	AvgDaysLate   float64 `json:"avg_days_late"`
+}
+
+// Agregar una ocurrencia al resumen; las abiertas con fecha pasada cuentan como vencidas
+func (s *AdherenceSummary) Add(o *RecurringOccurrence, now time.Time, loc *time.Location) {
+	s.Total++
+	switch o.Status {
+	case OccurrencePaid:
+		if days := o.DaysLate(loc); days > 0 {
+			s.Late++
+			s.AvgDaysLate += float64(days)
+		} else {
+			s.OnTime++
+		}
+	case OccurrenceSkipped:
+		s.Skipped++
+	case OccurrencePartial:
+		s.PartiallyPaid++
+	case OccurrencePostponed:
+		s.Postponed++
+	}
+	if o.IsOpen() && DaysPastDue(o.DueDate, now, loc) > 0 {
+		s.Overdue++
+	}
+}
+
+// Calcular porcentajes una vez agregadas todas las ocurrencias
+func (s *AdherenceSummary) Finish() {
+	if s.Late > 0 {
+		s.AvgDaysLate = RoundMoney(s.AvgDaysLate / float64(s.Late))
+	}
+	if paid := s.OnTime + s.Late; paid > 0 {
+		s.OnTimeRate = RoundMoney(float64(s.OnTime) * 100 / float64(paid))
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Estados de una sugerencia de gasto recurrente
+const (
+	SuggestionPending   = "pending"
+	SuggestionAccepted  = "accepted"
+	SuggestionDismissed = "dismissed"
+)
+
+// Gasto recurrente detectado en el historial de transacciones. Key identifica el
+// patrón (descripción normalizada, frecuencia y monto redondeado) para no volver a
+// sugerir uno descartado
+type RecurringSuggestion struct {
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	UserID      uint    `json:"user_id" gorm:"not null;uniqueIndex:idx_recurring_suggestion_key"`
+	Key         string  `json:"-" gorm:"not null;uniqueIndex:idx_recurring_suggestion_key"`
+	Description string  `json:"description" gorm:"not null"`
+	AccountID   uint    `json:"account_id" gorm:"not null"`
+	CategoryID  *uint   `json:"category_id,omitempty"`
+	Amount      float64 `json:"amount" gorm:"not null"`
+	Frequency   string  `json:"frequency" gorm:"not null"` // weekly, monthly, yearly
+	Confidence  float64 `json:"confidence"`                // 0 a 1
+
+	// Evidencia
+	Occurrences   int       `json:"occurrences"`
+	LastDate      time.Time `json:"last_date"`
+	NextDueDate   time.Time `json:"next_due_date"`
+	TransactionID uint      `json:"transaction_id"` // Última transacción del patrón
+
+	Status                 string `json:"status" gorm:"not null;default:'pending'"`
+	RecurringTransactionID *uint  `json:"recurring_transaction_id,omitempty"` // Al aceptarla
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relaciones
+	Account  Account   `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	Category *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+}
+
+var accentReplacer = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// Descripción comparable entre transacciones: minúsculas, sin tildes, números ni
+// signos ("NETFLIX.COM 4829" y "Netflix.com 5531" -> "netflix com")
+func NormalizeDescription(description string) string {
+	description = accentReplacer.Replace(strings.ToLower(description))
+	words := strings.FieldsFunc(description, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/teambition/rrule-go"
+	"gorm.io/gorm"
+)
+
+// Plantilla de transacción recurrente: gastos, ingresos, transferencias entre
+// cuentas o cuotas de un préstamo, con la misma programación (RRULE)
+type RecurringTransaction struct {
+	ID         uint  `json:"id" gorm:"primaryKey"`
+	UserID     uint  `json:"user_id" gorm:"not null"`
+	AccountID  uint  `json:"account_id" gorm:"not null"` // En transferencias, la cuenta de la que sale
+	CategoryID *uint `json:"category_id,omitempty"`
+
+	// Qué se registra
+	Type        string `json:"type" gorm:"not null;default:'expense'"` // Tipo de transacción o "transfer"
+	ToAccountID *uint  `json:"to_account_id,omitempty"`                // Solo transferencias
+	LoanID      *uint  `json:"loan_id,omitempty"`                      // Cuotas: se registra un pago del préstamo
+
+	// Configuración de la transacción
+	Amount      float64 `json:"amount" gorm:"not null"`
+	Description string  `json:"description" gorm:"not null"`
+
+	// Configuración de recurrencia
+	Frequency   string     `json:"frequency" gorm:"not null"` // RRULE (RFC 5545), p. ej. FREQ=MONTHLY;BYMONTHDAY=15,-1
+	StartDate   time.Time  `json:"start_date" gorm:"not null"`
+	EndDate     *time.Time `json:"end_date,omitempty"`
+	NextDueDate time.Time  `json:"next_due_date" gorm:"not null"`
+
+	// Control
+	IsActive     bool   `json:"is_active" gorm:"default:true"`
+	AutoGenerate bool   `json:"auto_generate" gorm:"default:false"` // Registrar la transacción automáticamente al vencer
+	Notes        string `json:"notes" gorm:"size:500"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relaciones
+	User      User      `json:"-" gorm:"foreignKey:UserID"`
+	Account   Account   `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	ToAccount *Account  `json:"to_account,omitempty" gorm:"foreignKey:ToAccountID"`
+	Category  *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Loan      *Loan     `json:"loan,omitempty" gorm:"foreignKey:LoanID"`
+}
+
+const RecurringTypeTransfer = "transfer"
+
+// Tipos que puede registrar una plantilla (los de CreateTransaction más transferencias)
+var RecurringTransactionTypes = []string{
+	"income", "expense", "loan_given", "loan_received", "loan_payment_given", "loan_payment_received", RecurringTypeTransfer,
+}
+
+// Verificar si la plantilla es una transferencia entre cuentas
+func (rt *RecurringTransaction) IsTransfer() bool {
+	return rt.Type == RecurringTypeTransfer
+}
+
+// Tipo de la transacción en AccountID (la transferencia sale de ella)
+func (rt *RecurringTransaction) TransactionType() string {
+	if rt.IsTransfer() {
+		return "transfer_out"
+	}
+	return rt.Type
+}
+
+// Dirección en AccountID, igual que en CreateTransaction
+func (rt *RecurringTransaction) Direction() string {
+	direction, _ := DirectionForType(rt.TransactionType())
+	return direction
+}
+
+// Regla de recurrencia anclada en StartDate
+func (rt *RecurringTransaction) Rule() (*rrule.RRule, error) {
+	return RecurrenceRule(rt.Frequency, rt.StartDate)
+}
+
+// Primera ocurrencia en o después de from (ok=false si la regla se agotó o pasa de EndDate)
+func (rt *RecurringTransaction) OccurrenceFrom(from time.Time) (time.Time, bool) {
+	rule, err := rt.Rule()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	occurrence := rule.After(from, true)
+	if occurrence.IsZero() || (rt.EndDate != nil && occurrence.After(*rt.EndDate)) {
+		return time.Time{}, false
+	}
+	return occurrence, true
+}
+
+// Vencimiento siguiente a NextDueDate
+func (rt *RecurringTransaction) NextOccurrence() (time.Time, bool) {
+	return rt.OccurrenceAfter(rt.NextDueDate)
+}
+
+// Vencimiento siguiente a date (para avanzar desde la fecha programada aunque se haya pospuesto)
+func (rt *RecurringTransaction) OccurrenceAfter(date time.Time) (time.Time, bool) {
+	return rt.OccurrenceFrom(date.Add(time.Second))
+}
+
+// Próximos n vencimientos desde NextDueDate (incluido)
+func (rt *RecurringTransaction) UpcomingOccurrences(n int) []time.Time {
+	occurrences := []time.Time{}
+	if !rt.IsActive || rt.HasEnded() {
+		return occurrences
+	}
+
+	next, ok := rt.OccurrenceFrom(rt.NextDueDate)
+	for ok && len(occurrences) < n {
+		occurrences = append(occurrences, next)
+		next, ok = rt.OccurrenceFrom(next.Add(time.Second))
+	}
+	return occurrences
+}
+
+// Verificar si ya no quedan vencimientos (la próxima fecha pasa de EndDate)
+func (rt *RecurringTransaction) HasEnded() bool {
+	return rt.EndDate != nil && rt.NextDueDate.After(*rt.EndDate)
+}
+
+// Clave de idempotencia de una ocurrencia: una sola transacción por vencimiento
+func (rt *RecurringTransaction) OccurrenceKey(dueDate time.Time) string {
+	return fmt.Sprintf("recurring_transaction:%d:%s", rt.ID, dueDate.Format("2006-01-02"))
+}
+
+// Método para verificar si está vencido (el día de vencimiento ya pasó en la zona del usuario)
+func (rt *RecurringTransaction) IsOverdue(loc *time.Location) bool {
+	return rt.IsActive && DaysPastDue(rt.NextDueDate, time.Now(), loc) > 0
+}
+
+// Método para verificar si vence hoy en la zona del usuario
+func (rt *RecurringTransaction) IsDueToday(loc *time.Location) bool {
+	return rt.IsActive && DaysPastDue(rt.NextDueDate, time.Now(), loc) == 0
+}
+
+// Verificar si el vencimiento pendiente ya llegó (hoy o antes) en la zona del usuario
+func (rt *RecurringTransaction) IsDueBy(now time.Time, loc *time.Location) bool {
+	return DaysPastDue(rt.NextDueDate, now, loc) >= 0
+}
@@ -6,24 +6,63 @@ import (
 	"gorm.io/gorm"
 )
 
+// Prioridades de un recordatorio, de menor a mayor ("urgent" solo por escalamiento)
+var ReminderPriorities = []string{"low", "normal", "high", "urgent"}
+
+// Plantillas de los recordatorios automáticos (mensajes reminder.<plantilla>.* en i18n)
+const (
+	TemplateRecurringUpcoming    = "recurring_upcoming"
+	TemplateRecurringDueTomorrow = "recurring_due_tomorrow"
+	TemplateRecurringDueToday    = "recurring_due_today"
+	TemplateRecurringOverdue     = "recurring_overdue"
+	TemplateRecurringPosted      = "recurring_posted"
+	TemplateLoanUpcoming         = "loan_upcoming"
+	TemplateLoanDueToday         = "loan_due_today"
+	TemplateLoanOverdue          = "loan_overdue"
+	TemplateLoanPaymentReported  = "loan_payment_reported"
+	TemplateLoanInvitation       = "loan_invitation"
+	TemplateSecurityNewSession   = "security_new_session"
+)
+
+// Datos sin formato con que se genera el texto de un recordatorio automático; el
+// monto y la fecha se formatean al generarlo, según el idioma
+type ReminderParams struct {
+	Description string     `json:"description,omitempty"`
+	Person      string     `json:"person,omitempty"`
+	Given       bool       `json:"given,omitempty"` // Préstamo que di (para "te debe" / "debes")
+	Account     string     `json:"account,omitempty"`
+	Amount      float64    `json:"amount"`
+	Currency    string     `json:"currency"`
+	Date        *time.Time `json:"date,omitempty"`
+	Days        int        `json:"days"`
+	Transfer    bool       `json:"transfer,omitempty"`
+	Income      bool       `json:"income,omitempty"`
+}
+
 type Reminder struct {
 	ID          uint   `json:"id" gorm:"primaryKey"`
 	UserID      uint   `json:"user_id" gorm:"not null"`
 	Title       string `json:"title" gorm:"not null"`
 	Description string `json:"description" gorm:"size:255"`
-	Type        string `json:"type" gorm:"not null"` // "recurring_expense", "loan", "custom"
+	Type        string `json:"type" gorm:"not null"` // "recurring_transaction", "loan", "security", "custom"
+	Template    string `json:"template,omitempty"`   // Mensaje con que se generó el texto ("recurring_overdue"...); vacío en los personalizados
+	Params      string `json:"-" gorm:"type:text"`   // ReminderParams en JSON, para volver a generar el texto en otro idioma
 
 	// Referencia al objeto relacionado
 	ReferenceID   *uint  `json:"reference_id,omitempty"`
-	ReferenceType string `json:"reference_type,omitempty"` // "recurring_expense", "loan"
+	ReferenceType string `json:"reference_type,omitempty"` // "recurring_transaction", "loan", "loan_payment", "loan_link", "transaction", "device_session"
 
 	// Configuración de recordatorio
 	RemindAt time.Time `json:"remind_at" gorm:"not null"`
 	IsActive bool      `json:"is_active" gorm:"default:true"`
 	IsSent   bool      `json:"is_sent" gorm:"default:false"`
 
+	// Bandeja de notificaciones
+	SentAt *time.Time `json:"sent_at,omitempty"`
+	ReadAt *time.Time `json:"read_at,omitempty"`
+
 	// Información adicional
-	Priority string `json:"priority" gorm:"default:'normal'"` // "low", "normal", "high"
+	Priority string `json:"priority" gorm:"default:'normal'"` // "low", "normal", "high", "urgent"
 
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -33,6 +72,12 @@ type Reminder struct {
 	User User `json:"-" gorm:"foreignKey:UserID"`
 }
 
+// Hook ANTES de guardar - RemindAt en UTC (SQLite compara las fechas como texto)
+func (r *Reminder) BeforeSave(tx *gorm.DB) error {
+	r.RemindAt = r.RemindAt.UTC()
+	return nil
+}
+
 // Verificar si el recordatorio debe enviarse
 func (r *Reminder) ShouldBeSent() bool {
 	return r.IsActive && !r.IsSent && time.Now().After(r.RemindAt)
@@ -40,6 +85,8 @@ func (r *Reminder) ShouldBeSent() bool {
 
 // Marcar como enviado
 func (r *Reminder) MarkAsSent(db *gorm.DB) error {
+	now := time.Now()
 	r.IsSent = true
+	r.SentAt = &now
 	return db.Save(r).Error
 }
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Estados de una ejecución
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job periódico del scheduler. La próxima ejecución y el lease viven en la base de
+// datos: un reinicio no repite lo ya hecho y con varias instancias corre solo una
+type ScheduledJob struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"not null;uniqueIndex"`
+	Schedule string `json:"schedule" gorm:"not null"` // Cron de 5 campos en UTC
+	IsActive bool   `json:"is_active" gorm:"default:true"`
+
+	NextRunAt time.Time `json:"next_run_at" gorm:"not null;index"`
+
+	// Lease: la instancia que lo está ejecutando y hasta cuándo
+	LockedBy    string     `json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+
+	// Último resultado; Failures cuenta los fallos seguidos (para el backoff)
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastStatus string     `json:"last_status,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	Failures   int        `json:"failures" gorm:"default:0"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Historial: una fila por ejecución
+type JobRun struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	JobID      uint       `json:"job_id" gorm:"not null;index"`
+	JobName    string     `json:"job_name" gorm:"not null"`
+	InstanceID string     `json:"instance_id"`
+	Attempt    int        `json:"attempt"` // 1 la primera vez, 2+ si es un reintento
+	Status     string     `json:"status" gorm:"not null"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Zona horaria por defecto de los usuarios
+const DefaultTimezone = "America/Lima"
+
+var ErrInvalidClock = errors.New("invalid time of day")
+
+// Cargar una zona IANA ("America/Lima"); "Local" y "" no son válidas porque
+// dependen del servidor
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("invalid timezone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// Las fechas de vencimiento son días de calendario: se toma su año, mes y día
+// tal como se guardaron y se ubican en la zona del usuario a la hora indicada
+func LocalDateAt(date time.Time, loc *time.Location, hour int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, 0, 0, 0, loc)
+}
+
+// Inicio del día de hoy en la zona del usuario
+func LocalToday(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// Días completos entre el vencimiento y hoy en la zona del usuario (negativo si aún no vence)
+func DaysPastDue(date time.Time, now time.Time, loc *time.Location) int {
+	due := LocalDateAt(date, loc, 0)
+	today := LocalToday(now, loc)
+	// Comparar fechas en UTC evita los días de 23 o 25 horas por horario de verano
+	dueUTC := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+	todayUTC := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	return int(todayUTC.Sub(dueUTC).Hours() / 24)
+}
+
+// Hora del día "HH:MM" guardada como time.Time del 1 de enero del año 0 (UTC)
+func ParseClock(value string) (time.Time, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, ErrInvalidClock
+	}
+	return clock, nil
+}
+
+// Formato "HH:MM" de una hora del día (vacío si no hay)
+func FormatClock(clock *time.Time) string {
+	if clock == nil {
+		return ""
+	}
+	return clock.Format("15:04")
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"cuentas-claras/utils"
+	"math"
 	"time"
 
 	"gorm.io/gorm"
@@ -27,6 +28,9 @@ type Transaction struct {
 	ReferenceID   *uint  `json:"reference_id,omitempty"`
 	ReferenceType string `json:"reference_type,omitempty"`
 
+	// Evita registrar dos veces la misma ocurrencia de una transacción recurrente
+	IdempotencyKey *string `json:"-" gorm:"uniqueIndex"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -52,3 +56,33 @@ func (t *Transaction) AfterFind(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// Tipos de transacción según su dirección (deben coincidir con los triggers)
+var (
+	InTransactionTypes  = []string{"income", "loan_received", "loan_payment_received", "loan_settlement_in", "debt_forgiveness", "group_settlement_in", "transfer_in"}
+	OutTransactionTypes = []string{"expense", "loan_given", "loan_payment_given", "loan_settlement_out", "bad_debt", "group_settlement_out", "transfer_out"}
+)
+
+// Dirección que corresponde a un tipo de transacción
+func DirectionForType(transactionType string) (string, bool) {
+	for _, t := range InTransactionTypes {
+		if t == transactionType {
+			return "in", true
+		}
+	}
+	for _, t := range OutTransactionTypes {
+		if t == transactionType {
+			return "out", true
+		}
+	}
+	return "", false
+}
+
+// Monto con el signo que corresponde a la dirección
+func SignedAmount(direction string, amount float64) float64 {
+	amount = math.Abs(amount)
+	if direction == "out" {
+		return -amount
+	}
+	return amount
+}
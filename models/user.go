@@ -1,6 +1,7 @@
 package models
 
 import (
+	"cuentas-claras/i18n"
 	"cuentas-claras/utils"
 	"time"
 
@@ -18,7 +19,11 @@ type User struct {
 	NotificationsEnabled bool       `json:"notifications_enabled" gorm:"default:true"`
 	PushNotifications    bool       `json:"push_notifications" gorm:"default:true"`
 	InAppNotifications   bool       `json:"in_app_notifications" gorm:"default:true"`
+	EmailNotifications   bool       `json:"email_notifications" gorm:"default:false"`
+	WebhookURL           string     `json:"-" gorm:"column:webhook_url_encrypted"`    // 🔒 ENCRIPTADO, puede llevar un secreto
+	WebhookSecret        string     `json:"-" gorm:"column:webhook_secret_encrypted"` // 🔒 ENCRIPTADO, firma HMAC de los envíos
 	Timezone             string     `json:"timezone" gorm:"default:'America/Lima'"`
+	Locale               string     `json:"locale" gorm:"default:'es-PE'"` // Idioma de notificaciones y correos
 	QuietHoursStart      *time.Time `json:"quiet_hours_start,omitempty"`
 	QuietHoursEnd        *time.Time `json:"quiet_hours_end,omitempty"`
 
@@ -27,6 +32,63 @@ type User struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// Zona horaria del usuario (la por defecto si la guardada no es válida)
+func (u *User) Location() *time.Location {
+	if loc, err := LoadTimezone(u.Timezone); err == nil {
+		return loc
+	}
+	if loc, err := LoadTimezone(DefaultTimezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Idioma, formatos y zona del usuario para los textos que se le envían
+func (u *User) Localizer() *i18n.Localizer {
+	return i18n.New(u.Locale, u.Location())
+}
+
+// Zona horaria de un usuario por ID (la por defecto si no existe)
+func UserLocation(db *gorm.DB, userID uint) *time.Location {
+	var user User
+	db.Select("id", "timezone").First(&user, userID)
+	return user.Location()
+}
+
+// Si now cae en las horas de silencio, devuelve cuándo terminan.
+// Una ventana con inicio mayor al fin (22:00-07:00) cruza la medianoche
+func (u *User) QuietUntil(now time.Time) (time.Time, bool) {
+	if u.QuietHoursStart == nil || u.QuietHoursEnd == nil {
+		return time.Time{}, false
+	}
+
+	start := u.QuietHoursStart.Hour()*60 + u.QuietHoursStart.Minute()
+	end := u.QuietHoursEnd.Hour()*60 + u.QuietHoursEnd.Minute()
+	if start == end {
+		return time.Time{}, false
+	}
+
+	local := now.In(u.Location())
+	minute := local.Hour()*60 + local.Minute()
+	endToday := time.Date(local.Year(), local.Month(), local.Day(), u.QuietHoursEnd.Hour(), u.QuietHoursEnd.Minute(), 0, 0, local.Location())
+
+	if start < end {
+		if minute >= start && minute < end {
+			return endToday, true
+		}
+		return time.Time{}, false
+	}
+
+	// Cruza la medianoche: antes del fin termina hoy, después del inicio termina mañana
+	if minute < end {
+		return endToday, true
+	}
+	if minute >= start {
+		return endToday.AddDate(0, 0, 1), true
+	}
+	return time.Time{}, false
+}
+
 // Hook ANTES de guardar - encriptar
 func (u *User) BeforeSave(tx *gorm.DB) error {
 	if u.Name != "" {
@@ -35,6 +97,12 @@ func (u *User) BeforeSave(tx *gorm.DB) error {
 	if u.PhoneNumber != "" {
 		u.PhoneNumber = utils.EncryptField(u.PhoneNumber)
 	}
+	if u.WebhookURL != "" {
+		u.WebhookURL = utils.EncryptField(u.WebhookURL)
+	}
+	if u.WebhookSecret != "" {
+		u.WebhookSecret = utils.EncryptField(u.WebhookSecret)
+	}
 	return nil
 }
 
@@ -46,5 +114,11 @@ func (u *User) AfterFind(tx *gorm.DB) error {
 	if u.PhoneNumber != "" {
 		u.PhoneNumber = utils.DecryptField(u.PhoneNumber)
 	}
+	if u.WebhookURL != "" {
+		u.WebhookURL = utils.DecryptField(u.WebhookURL)
+	}
+	if u.WebhookSecret != "" {
+		u.WebhookSecret = utils.DecryptField(u.WebhookSecret)
+	}
 	return nil
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Tipos de evento en tiempo real (WebSocket / SSE)
+const (
+	EventForceLogout          = "force_logout"
+	EventNotification         = "notification"
+	EventTransactionCreated   = "transaction_created"
+	EventLoanPaymentConfirmed = "loan_payment_confirmed"
+	EventPing                 = "ping" // Heartbeat, no se guarda
+)
+
+// Evento enviado a los dispositivos conectados de un usuario. Se guarda unos días
+// para que un cliente que se reconecta pueda retomar desde el último ID recibido
+type UserEvent struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"not null;index"`
+	Type   string `json:"type" gorm:"not null"`
+	Data   string `json:"data"` // JSON
+
+	// Alcance: solo a una sesión (p. ej. la que se cierra) o a todas menos la que lo originó
+	DeviceSessionID *uint `json:"device_session_id,omitempty"`
+	OriginSessionID *uint `json:"origin_session_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// Si la sesión indicada debe recibir el evento
+func (e *UserEvent) VisibleTo(sessionID uint) bool {
+	if e.DeviceSessionID != nil && *e.DeviceSessionID != sessionID {
+		return false
+	}
+	if e.OriginSessionID != nil && *e.OriginSessionID == sessionID {
+		return false
+	}
+	return true
+}
@@ -22,6 +22,16 @@ func SetupRoutes(app *fiber.App) {
 	auth.Put("/profile", middleware.RequireAuth, handlers.UpdateProfile)
 	auth.Post("/logout", middleware.RequireAuth, handlers.Logout)
 
+	// Eventos en tiempo real: WebSocket y SSE como alternativa (token en ?token= o header)
+	api.Get("/ws", handlers.RequireWebSocketUpgrade, middleware.TokenFromQuery, middleware.RequireAuth, handlers.EventsWebSocket)
+	api.Get("/events", middleware.TokenFromQuery, middleware.RequireAuth, handlers.StreamEvents)
+
+	// Enlaces compartidos de préstamos (públicos, autenticados por token firmado)
+	public := api.Group("/public")
+	public.Get("/loan-shares/:token", handlers.GetSharedLoanStatement)
+	public.Post("/loan-shares/:token/payments", handlers.ReportSharedLoanPayment)
+	public.Get("/calendar/:token", handlers.GetPublicCalendarFeed)
+
 	// Account routes (protegidas)
 	accounts := api.Group("/accounts", middleware.RequireAuth)
 	accounts.Post("/", handlers.CreateAccount)
@@ -30,6 +40,33 @@ func SetupRoutes(app *fiber.App) {
 	accounts.Put("/:id", handlers.UpdateAccount)
 	accounts.Delete("/:id", handlers.DeleteAccount)
 
+	// Feed de calendario (protegido): activar, regenerar el token o desactivar
+	calendarFeed := api.Group("/calendar-feed", middleware.RequireAuth)
+	calendarFeed.Get("/", handlers.GetCalendarFeed)
+	calendarFeed.Post("/", handlers.RegenerateCalendarFeed)
+	calendarFeed.Delete("/", handlers.DeleteCalendarFeed)
+
+	// Forecast (protegido): saldo proyectado con los próximos vencimientos
+	api.Get("/forecast", middleware.RequireAuth, handlers.GetForecast)
+
+	// Reminder routes (protegidas): bandeja de notificaciones y recordatorios personalizados
+	reminders := api.Group("/reminders", middleware.RequireAuth)
+	reminders.Get("/", handlers.GetReminders)
+	reminders.Post("/", handlers.CreateReminder)
+	reminders.Get("/unread-count", handlers.GetUnreadRemindersCount)
+	reminders.Post("/read-all", handlers.MarkAllRemindersRead)
+	reminders.Get("/:id", handlers.GetReminder)
+	reminders.Post("/:id/read", handlers.MarkReminderRead)
+	reminders.Post("/:id/snooze", handlers.SnoozeReminder)
+	reminders.Post("/:id/deactivate", handlers.DeactivateReminder)
+	reminders.Get("/:id/deliveries", handlers.GetReminderDeliveries)
+
+	// Preferencias por tipo de notificación (protegidas): canales, días de aviso y escalamiento
+	notificationPreferences := api.Group("/notification-preferences", middleware.RequireAuth)
+	notificationPreferences.Get("/", handlers.GetNotificationPreferences)
+	notificationPreferences.Put("/:type", handlers.UpdateNotificationPreference)
+	notificationPreferences.Delete("/:type", handlers.ResetNotificationPreference)
+
 	// Category routes (protegidas)
 	categories := api.Group("/categories", middleware.RequireAuth)
 	categories.Post("/", handlers.CreateCategory)
@@ -54,18 +91,93 @@ func SetupRoutes(app *fiber.App) {
 	loans.Put("/:id", handlers.UpdateLoan)
 	loans.Delete("/:id", handlers.DeleteLoan)
 	loans.Post("/:id/payments", handlers.CreateLoanPayment)
+	loans.Post("/:id/reschedule", handlers.RescheduleLoan)
+	loans.Post("/:id/forgive", handlers.ForgiveLoan)
+	loans.Post("/:id/write-off", handlers.WriteOffLoan)
+	loans.Get("/:id/statement", handlers.GetLoanStatement)
+	loans.Post("/:id/invite", handlers.InviteLoanCounterparty)
+	loans.Post("/:id/dispute", handlers.DisputeLoan)
+	loans.Post("/:id/resolve-dispute", handlers.ResolveLoanDispute)
+	loans.Post("/:id/shares", handlers.CreateLoanShare)
+	loans.Get("/:id/shares", handlers.GetLoanShares)
+	loans.Delete("/:id/shares/:shareId", handlers.RevokeLoanShare)
+	loans.Get("/:id/shares/:shareId/accesses", handlers.GetLoanShareAccesses)
+
+	// Loan invitation routes (protegidas)
+	loanInvitations := api.Group("/loan-invitations", middleware.RequireAuth)
+	loanInvitations.Get("/", handlers.GetLoanInvitations)
+	loanInvitations.Post("/:id/accept", handlers.AcceptLoanInvitation)
+	loanInvitations.Post("/:id/decline", handlers.DeclineLoanInvitation)
+	loanInvitations.Delete("/:id", handlers.RevokeLoanInvitation)
+
+	// Contact routes (protegidas)
+	contacts := api.Group("/contacts", middleware.RequireAuth)
+	contacts.Post("/", handlers.CreateContact)
+	contacts.Get("/", handlers.GetContacts)
+	contacts.Get("/:id", handlers.GetContact)
+	contacts.Put("/:id", handlers.UpdateContact)
+	contacts.Delete("/:id", handlers.DeleteContact)
+	contacts.Post("/:id/merge", handlers.MergeContacts)
+	contacts.Get("/:id/ledger", handlers.GetContactLedger)
+
+	// Group routes (protegidas)
+	groups := api.Group("/groups", middleware.RequireAuth)
+	groups.Post("/", handlers.CreateGroup)
+	groups.Get("/", handlers.GetGroups)
+	groups.Get("/:id", handlers.GetGroup)
+	groups.Put("/:id", handlers.UpdateGroup)
+	groups.Delete("/:id", handlers.DeleteGroup)
+	groups.Post("/:id/members", handlers.AddGroupMember)
+	groups.Delete("/:id/members/:memberId", handlers.RemoveGroupMember)
+	groups.Post("/:id/expenses", handlers.CreateGroupExpense)
+	groups.Delete("/:id/expenses/:expenseId", handlers.DeleteGroupExpense)
+	groups.Get("/:id/balances", handlers.GetGroupBalances)
+	groups.Post("/:id/settlements", handlers.CreateGroupSettlement)
+	groups.Delete("/:id/settlements/:settlementId", handlers.DeleteGroupSettlement)
 
 	// LoanPayment routes (protegidas)
 	loanPayments := api.Group("/loan-payments", middleware.RequireAuth)
 	loanPayments.Put("/:id/confirm", handlers.ConfirmLoanPayment)
+	loanPayments.Put("/:id", handlers.UpdateLoanPayment)
+	loanPayments.Delete("/:id", handlers.DeleteLoanPayment)
+	loanPayments.Post("/:id/reverse", handlers.ReverseLoanPayment)
+	loanPayments.Post("/:id/dispute", handlers.DisputeLoanPayment)
 
 	// Recurring Expense routes (protegidas) ✨ NUEVO
 	recurringExpenses := api.Group("/recurring-expenses", middleware.RequireAuth)
 	recurringExpenses.Post("/", handlers.CreateRecurringExpense)
 	recurringExpenses.Get("/", handlers.GetRecurringExpenses)
+	recurringExpenses.Post("/preview", handlers.PreviewRecurrence)
+	recurringExpenses.Get("/adherence", handlers.GetRecurringExpensesAdherence)
 	recurringExpenses.Get("/:id", handlers.GetRecurringExpense)
 	recurringExpenses.Put("/:id", handlers.UpdateRecurringExpense)
 	recurringExpenses.Delete("/:id", handlers.DeleteRecurringExpense)
 	recurringExpenses.Post("/:id/execute", handlers.ExecuteRecurringExpense) // ✨ EXECUTE
+	recurringExpenses.Get("/:id/preview", handlers.PreviewRecurringExpense)
+	recurringExpenses.Post("/:id/skip", handlers.SkipRecurringExpense)
+	recurringExpenses.Post("/:id/postpone", handlers.PostponeRecurringExpense)
+	recurringExpenses.Get("/:id/occurrences", handlers.GetRecurringExpenseOccurrences)
+
+	// Recurring Transaction routes (protegidas): ingresos, gastos, transferencias y cuotas
+	recurringTransactions := api.Group("/recurring-transactions", middleware.RequireAuth)
+	recurringTransactions.Post("/", handlers.CreateRecurringTransaction)
+	recurringTransactions.Get("/", handlers.GetRecurringTransactions)
+	recurringTransactions.Post("/preview", handlers.PreviewRecurrence)
+	recurringTransactions.Get("/adherence", handlers.GetRecurringTransactionsAdherence)
+	recurringTransactions.Get("/:id", handlers.GetRecurringTransaction)
+	recurringTransactions.Put("/:id", handlers.UpdateRecurringTransaction)
+	recurringTransactions.Delete("/:id", handlers.DeleteRecurringTransaction)
+	recurringTransactions.Post("/:id/execute", handlers.ExecuteRecurringTransaction)
+	recurringTransactions.Get("/:id/preview", handlers.PreviewRecurringTransaction)
+	recurringTransactions.Post("/:id/skip", handlers.SkipRecurringTransaction)
+	recurringTransactions.Post("/:id/postpone", handlers.PostponeRecurringTransaction)
+	recurringTransactions.Get("/:id/occurrences", handlers.GetRecurringTransactionOccurrences)
+
+	// Sugerencias de gastos recurrentes detectadas en el historial
+	recurringSuggestions := api.Group("/recurring-suggestions", middleware.RequireAuth)
+	recurringSuggestions.Get("/", handlers.GetRecurringSuggestions)
+	recurringSuggestions.Post("/refresh", handlers.RefreshRecurringSuggestions)
+	recurringSuggestions.Post("/:id/accept", handlers.AcceptRecurringSuggestion)
+	recurringSuggestions.Post("/:id/dismiss", handlers.DismissRecurringSuggestion)
 
 }
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Evento de día completo del feed
+type calendarEvent struct {
+	UID         string
+	Date        time.Time // Día de calendario del vencimiento
+	Summary     string
+	Description string
+	Stamp       time.Time
+	AlarmDays   []int // Días antes, a reminderHour (como los recordatorios)
+}
+
+// Construir el feed iCalendar (RFC 5545) con el próximo vencimiento de cada plantilla
+// activa y los vencimientos pendientes de los préstamos (cuotas o fecha final)
+func BuildCalendarFeed(userID uint) string {
+	events := []calendarEvent{}
+
+	var user models.User
+	config.DB.First(&user, userID)
+	text := user.Localizer()
+
+	// Alarmas en los mismos días que los recordatorios (ninguna si el usuario los desactivó)
+	recurringAlarmDays := leadDays(userID, models.NotifyRecurringDue)
+	loanAlarmDays := leadDays(userID, models.NotifyLoanDue)
+
+	var templates []models.RecurringTransaction
+	config.DB.Preload("Account").Where("user_id = ? AND is_active = true", userID).Find(&templates)
+	for _, recurring := range templates {
+		if recurring.HasEnded() {
+			continue
+		}
+		data := map[string]interface{}{
+			"Subject": recurring.Description,
+			"Amount":  text.Money(recurring.Amount, recurring.Account.Currency),
+			"Account": recurring.Account.Name,
+		}
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("recurring-transaction-%d-%s@cuentas-claras", recurring.ID, recurring.NextDueDate.Format("20060102")),
+			Date:        recurring.NextDueDate,
+			Summary:     text.T("calendar.summary", data),
+			Description: text.T("calendar.description", data),
+			Stamp:       recurring.UpdatedAt,
+			AlarmDays:   recurringAlarmDays,
+		})
+	}
+
+	var loans []models.Loan
+	config.DB.Preload("Account").Where("user_id = ? AND status NOT IN ?", userID, models.ClosedLoanStatuses).Find(&loans)
+	for i := range loans {
+		loan := &loans[i]
+		subject := loanReminderSubject(text, loan)
+
+		if loan.HasInstallmentPlan() {
+			var installments []models.LoanInstallment
+			config.DB.Where("loan_id = ? AND status <> ?", loan.ID, "paid").Order("number asc").Find(&installments)
+			for _, installment := range installments {
+				data := map[string]interface{}{
+					"Subject": text.T("loan.installment", map[string]interface{}{"Subject": subject, "Number": installment.Number}),
+					"Amount":  text.Money(installment.Remaining(), loan.Account.Currency),
+					"Account": loan.Account.Name,
+				}
+				events = append(events, calendarEvent{
+					UID:         fmt.Sprintf("loan-installment-%d@cuentas-claras", installment.ID),
+					Date:        installment.DueDate,
+					Summary:     text.T("calendar.summary", data),
+					Description: text.T("calendar.description", data),
+					Stamp:       installment.UpdatedAt,
+					AlarmDays:   loanAlarmDays,
+				})
+			}
+			continue
+		}
+
+		if loan.DueDate == nil {
+			continue
+		}
+		data := map[string]interface{}{
+			"Subject": subject,
+			"Amount":  text.Money(loan.GetBalance(config.DB), loan.Account.Currency),
+			"Account": loan.Account.Name,
+		}
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("loan-%d@cuentas-claras", loan.ID),
+			Date:        *loan.DueDate,
+			Summary:     text.T("calendar.summary", data),
+			Description: text.T("calendar.description", data),
+			Stamp:       loan.UpdatedAt,
+			AlarmDays:   loanAlarmDays,
+		})
+	}
+
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//CuentasClaras//Vencimientos//ES")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:CuentasClaras")
+	writeICSLine(&b, "X-WR-TIMEZONE:"+user.Location().String())
+
+	for _, event := range events {
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, "UID:"+event.UID)
+		writeICSLine(&b, "DTSTAMP:"+event.Stamp.UTC().Format("20060102T150405Z"))
+		// Días completos: el vencimiento es una fecha, no una hora
+		writeICSLine(&b, "DTSTART;VALUE=DATE:"+event.Date.Format("20060102"))
+		writeICSLine(&b, "DTEND;VALUE=DATE:"+event.Date.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(event.Summary))
+		writeICSLine(&b, "DESCRIPTION:"+escapeICSText(event.Description))
+		writeICSLine(&b, "TRANSP:TRANSPARENT")
+		for _, days := range event.AlarmDays {
+			writeICSLine(&b, "BEGIN:VALARM")
+			writeICSLine(&b, "ACTION:DISPLAY")
+			writeICSLine(&b, "DESCRIPTION:"+escapeICSText(event.Summary))
+			writeICSLine(&b, "TRIGGER;RELATED=START:"+icsTrigger(days, reminderHour))
+			writeICSLine(&b, "END:VALARM")
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// Días de aviso de un tipo de vencimiento según las preferencias del usuario
+func leadDays(userID uint, notificationType string) []int {
+	preference := models.LoadNotificationPreference(config.DB, userID, notificationType)
+	if !preference.Enabled {
+		return nil
+	}
+	return preference.LeadDayList()
+}
+
+// Duración desde el inicio del día de vencimiento hasta daysBefore días antes a la hora indicada
+// (2 días antes a las 9:00 = -P1DT15H)
+func icsTrigger(daysBefore int, hour int) string {
+	hours := hour - daysBefore*24
+	sign := ""
+	if hours < 0 {
+		sign = "-"
+		hours = -hours
+	}
+
+	duration := sign + "P"
+	if hours >= 24 {
+		duration += fmt.Sprintf("%dD", hours/24)
+	}
+	if hours%24 != 0 || hours == 0 {
+		duration += fmt.Sprintf("T%dH", hours%24)
+	}
+	return duration
+}
+
+// Escapar texto según RFC 5545 (barra, punto y coma, coma y saltos de línea)
+func escapeICSText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// Escribir una línea con CRLF, plegada a 75 octetos sin cortar caracteres UTF-8
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // Las continuaciones empiezan con un espacio
+	}
+	b.WriteString(line + "\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
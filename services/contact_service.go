@@ -0,0 +1,100 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+
+	"gorm.io/gorm"
+)
+
+type ContactService struct{}
+
+// Campos a cambiar de un contacto (vacío = sin cambios; ya validados por quien llama)
+type UpdateContactInput struct {
+	Name  string
+	Phone string
+	Notes string
+}
+
+// Actualizar un contacto y el nombre guardado en sus préstamos en una sola transacción
+func (cs *ContactService) UpdateContact(userID uint, contactID uint, input UpdateContactInput) (*models.Contact, error) {
+	var contact models.Contact
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := cs.findContact(tx, userID, contactID, &contact); err != nil {
+			return err
+		}
+
+		if input.Name != "" {
+			contact.Name = input.Name
+		}
+		if input.Phone != "" {
+			contact.Phone = input.Phone
+		}
+		if input.Notes != "" {
+			contact.Notes = input.Notes
+		}
+
+		if err := tx.Save(&contact).Error; err != nil {
+			return err
+		}
+
+		// Mantener PersonName de sus préstamos sincronizado
+		if err := tx.First(&contact, contact.ID).Error; err != nil {
+			return err
+		}
+		var loanIDs []uint
+		if err := tx.Model(&models.Loan{}).Where("contact_id = ? AND user_id = ?", contact.ID, userID).
+			Pluck("id", &loanIDs).Error; err != nil {
+			return err
+		}
+		return models.LinkLoansToContact(tx, &contact, loanIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// Fusionar otros contactos del usuario en uno: sus préstamos pasan a este y se
+// eliminan. Devuelve el contacto y los IDs fusionados
+func (cs *ContactService) MergeContacts(userID uint, contactID uint, contactIDs []uint) (*models.Contact, []uint, error) {
+	var target models.Contact
+	var sourceIDs []uint
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := cs.findContact(tx, userID, contactID, &target); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Contact{}).Where("id IN ? AND id <> ? AND user_id = ?", contactIDs, target.ID, userID).
+			Pluck("id", &sourceIDs).Error; err != nil {
+			return err
+		}
+		if len(sourceIDs) == 0 {
+			return ErrNoContactsToMerge
+		}
+
+		var loanIDs []uint
+		if err := tx.Model(&models.Loan{}).Where("contact_id IN ? AND user_id = ?", sourceIDs, userID).
+			Pluck("id", &loanIDs).Error; err != nil {
+			return err
+		}
+		if err := models.LinkLoansToContact(tx, &target, loanIDs); err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", sourceIDs).Delete(&models.Contact{}).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &target, sourceIDs, nil
+}
+
+// Cargar un contacto del usuario
+func (cs *ContactService) findContact(tx *gorm.DB, userID uint, contactID uint, contact *models.Contact) error {
+	if err := tx.Where("id = ? AND user_id = ?", contactID, userID).First(contact).Error; err != nil {
+		return ErrContactNotFound
+	}
+	return nil
+}
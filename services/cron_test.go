@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "every minute", expr: "* * * * *"},
+		{name: "list range and step", expr: "0,30 8-18/2 1,15 1-6 1-5"},
+		{name: "step from value", expr: "5/15 * * * *"},
+		{name: "sunday as 7", expr: "0 0 * * 7"},
+		{name: "alias", expr: "@daily"},
+		{name: "surrounding spaces", expr: "  0 3 * * *  "},
+		{name: "too few fields", expr: "* * * *", wantErr: true},
+		{name: "too many fields", expr: "* * * * * *", wantErr: true},
+		{name: "empty", expr: "", wantErr: true},
+		{name: "unknown alias", expr: "@yearly", wantErr: true},
+		{name: "minute out of range", expr: "60 * * * *", wantErr: true},
+		{name: "hour out of range", expr: "0 24 * * *", wantErr: true},
+		{name: "day of month zero", expr: "0 0 0 * *", wantErr: true},
+		{name: "month out of range", expr: "0 0 1 13 *", wantErr: true},
+		{name: "day of week out of range", expr: "0 0 * * 8", wantErr: true},
+		{name: "reversed range", expr: "0 18-8 * * *", wantErr: true},
+		{name: "zero step", expr: "*/0 * * * *", wantErr: true},
+		{name: "not a number", expr: "a * * * *", wantErr: true},
+		{name: "empty list item", expr: "1,,2 * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCron) {
+					t.Fatalf("ParseCron(%q) error = %v, want ErrInvalidCron", tt.expr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCron(%q) unexpected error: %v", tt.expr, err)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			t.Fatalf("bad test time %q: %v", value, err)
+		}
+		return parsed
+	}
+
+	// 2026-10-19 es lunes
+	tests := []struct {
+		name  string
+		expr  string
+		after string
+		want  string
+	}{
+		{name: "every minute skips seconds", expr: "* * * * *", after: "2026-10-19 10:15:30", want: "2026-10-19 10:16:00"},
+		{name: "strictly after a match", expr: "* * * * *", after: "2026-10-19 10:15:00", want: "2026-10-19 10:16:00"},
+		{name: "hourly same hour", expr: "10 * * * *", after: "2026-10-19 10:05:00", want: "2026-10-19 10:10:00"},
+		{name: "hourly next hour", expr: "0 * * * *", after: "2026-10-19 10:00:00", want: "2026-10-19 11:00:00"},
+		{name: "daily rolls to next day", expr: "30 3 * * *", after: "2026-10-19 04:00:00", want: "2026-10-20 03:30:00"},
+		{name: "end of year", expr: "0 0 * * *", after: "2026-12-31 23:59:00", want: "2027-01-01 00:00:00"},
+		{name: "minute step", expr: "*/15 * * * *", after: "2026-10-19 10:16:00", want: "2026-10-19 10:30:00"},
+		{name: "step from value", expr: "5/20 * * * *", after: "2026-10-19 10:46:00", want: "2026-10-19 11:05:00"},
+		{name: "hour range with step", expr: "0 8-18/4 * * *", after: "2026-10-19 12:00:00", want: "2026-10-19 16:00:00"},
+		{name: "weekdays from saturday", expr: "0 9 * * 1-5", after: "2026-10-24 10:00:00", want: "2026-10-26 09:00:00"},
+		{name: "sunday as 7", expr: "0 0 * * 7", after: "2026-10-19 00:00:00", want: "2026-10-25 00:00:00"},
+		{name: "weekly alias", expr: "@weekly", after: "2026-10-19 00:00:00", want: "2026-10-25 00:00:00"},
+		{name: "monthly alias", expr: "@monthly", after: "2026-10-19 00:00:00", want: "2026-11-01 00:00:00"},
+		{name: "skips short months", expr: "0 0 31 * *", after: "2026-11-01 00:00:00", want: "2026-12-31 00:00:00"},
+		{name: "leap day", expr: "0 0 29 2 *", after: "2026-03-01 00:00:00", want: "2028-02-29 00:00:00"},
+		{name: "day of month or day of week", expr: "0 0 1 * 1", after: "2026-10-20 00:00:00", want: "2026-10-26 00:00:00"},
+		{name: "day of week or day of month", expr: "0 0 1 * 1", after: "2026-10-27 00:00:00", want: "2026-11-01 00:00:00"},
+		{name: "month list", expr: "0 12 15 1,7 *", after: "2026-10-19 00:00:00", want: "2027-01-15 12:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) unexpected error: %v", tt.expr, err)
+			}
+			got := schedule.Next(utc(tt.after))
+			if want := utc(tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s) for %q = %s, want %s", tt.after, tt.expr, got.Format(time.RFC3339), want.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestCronScheduleNextUsesUTC(t *testing.T) {
+	schedule, err := ParseCron("0 3 * * *")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lima := time.FixedZone("PET", -5*60*60)
+	got := schedule.Next(time.Date(2026, 10, 19, 23, 0, 0, 0, lima)) // 04:00 UTC del 20
+	want := time.Date(2026, 10, 21, 3, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got.Format(time.RFC3339), want.Format(time.RFC3339))
+	}
+}
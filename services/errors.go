@@ -0,0 +1,58 @@
+package services
+
+// Error de negocio con el código HTTP y el mensaje que verá el cliente
+type ServiceError struct {
+	Status  int
+	Message string
+}
+
+func (e *ServiceError) Error() string {
+	return e.Message
+}
+
+// Errores que pueden devolver las operaciones (comparables con errors.Is)
+var (
+	ErrAccountNotFound              = &ServiceError{Status: 404, Message: "Account not found"}
+	ErrContactNotFound              = &ServiceError{Status: 404, Message: "Contact not found"}
+	ErrNoContactsToMerge            = &ServiceError{Status: 404, Message: "No contacts to merge"}
+	ErrPaymentNotFound              = &ServiceError{Status: 404, Message: "Payment not found"}
+	ErrPaymentAlreadyConfirmed      = &ServiceError{Status: 400, Message: "Payment already confirmed"}
+	ErrPaymentDisputed              = &ServiceError{Status: 400, Message: "Payment is disputed"}
+	ErrLoanNotFound                 = &ServiceError{Status: 404, Message: "Loan not found"}
+	ErrLoanClosed                   = &ServiceError{Status: 400, Message: "Loan is already closed"}
+	ErrRecurringTransactionNotFound = &ServiceError{Status: 404, Message: "Recurring transaction not found"}
+	ErrRecurringTransactionInactive = &ServiceError{Status: 400, Message: "Recurring transaction is not active"}
+	ErrRecurringTransactionEnded    = &ServiceError{Status: 400, Message: "Recurring transaction has ended"}
+	ErrRecurrenceExhausted          = &ServiceError{Status: 400, Message: "Recurrence has no more occurrences"}
+	ErrOccurrenceAlreadyPosted      = &ServiceError{Status: 409, Message: "Occurrence already posted"}
+	ErrInvalidTransfer              = &ServiceError{Status: 400, Message: "Transfer needs two different accounts with the same currency"}
+	ErrInvalidPostponeDate          = &ServiceError{Status: 400, Message: "Postpone date must be after the due date and before the next occurrence"}
+	ErrCategoryNotFound             = &ServiceError{Status: 404, Message: "Category not found"}
+	ErrLoanHasConfirmedPayments     = &ServiceError{Status: 400, Message: "Cannot delete loan with confirmed payments"}
+	ErrLoanNoBalance                = &ServiceError{Status: 400, Message: "Loan has no outstanding balance"}
+	ErrForgivenessExceedsBalance    = &ServiceError{Status: 400, Message: "Forgiven amount exceeds loan balance"}
+	ErrForgivenessBeforeLastPayment = &ServiceError{Status: 400, Message: "Forgiveness date is before the latest payment"}
+	ErrLoanDisputed                 = &ServiceError{Status: 400, Message: "Loan is disputed"}
+	ErrPaymentExceedsBalance        = &ServiceError{Status: 400, Message: "Payment amount exceeds loan balance"}
+	ErrPaymentBeforeLoanDate        = &ServiceError{Status: 400, Message: "Payment date is before the loan date"}
+	ErrPaymentConfirmedEdit         = &ServiceError{Status: 400, Message: "Confirmed payments must be reversed before editing"}
+	ErrPaymentConfirmedDelete       = &ServiceError{Status: 400, Message: "Confirmed payments must be reversed before deleting"}
+	ErrPaymentNotConfirmed          = &ServiceError{Status: 400, Message: "Payment is not confirmed"}
+	ErrPaymentNotLinked             = &ServiceError{Status: 400, Message: "Only linked payments can be disputed"}
+	ErrCannotInviteSelf             = &ServiceError{Status: 400, Message: "Cannot invite yourself"}
+	ErrInvitationNotFound           = &ServiceError{Status: 404, Message: "Invitation not found"}
+	ErrInvitationNotPending         = &ServiceError{Status: 400, Message: "Invitation is no longer pending"}
+	ErrInvitedLoanNotFound          = &ServiceError{Status: 404, Message: "Loan no longer exists"}
+	ErrLoanAlreadyLinked            = &ServiceError{Status: 400, Message: "Loan is already linked"}
+	ErrLoanTypeMismatch             = &ServiceError{Status: 400, Message: "Loan type does not mirror the invitation"}
+	ErrLoanNotLinked                = &ServiceError{Status: 400, Message: "Only linked loans can be disputed"}
+	ErrLoanNotDisputed              = &ServiceError{Status: 400, Message: "Loan is not disputed"}
+	ErrGroupNotFound                = &ServiceError{Status: 404, Message: "Group not found"}
+	ErrGroupMemberNotFound          = &ServiceError{Status: 404, Message: "Member not found"}
+	ErrGroupExpenseNotFound         = &ServiceError{Status: 404, Message: "Expense not found"}
+	ErrSettlementNotFound           = &ServiceError{Status: 404, Message: "Settlement not found"}
+	ErrSettlementCurrencyMismatch   = &ServiceError{Status: 400, Message: "Account currency does not match the group currency"}
+	ErrSettlementNotMine            = &ServiceError{Status: 400, Message: "Only settlements involving you can be recorded in an account"}
+	ErrSuggestionNotFound           = &ServiceError{Status: 404, Message: "Recurring suggestion not found"}
+	ErrSuggestionNotPending         = &ServiceError{Status: 409, Message: "Recurring suggestion already accepted or dismissed"}
+)
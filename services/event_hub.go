@@ -0,0 +1,158 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Mensaje tal como lo recibe el cliente (WebSocket o SSE)
+type EventMessage struct {
+	ID        uint            `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+
+	sessionOnly bool // Dirigido solo a esta sesión (force_logout la cierra)
+}
+
+// Si el mensaje cierra la conexión de la sesión que lo recibe
+func (m EventMessage) EndsSession() bool {
+	return m.Type == models.EventForceLogout && m.sessionOnly
+}
+
+// Conexión de un dispositivo a los eventos de su usuario
+type EventSubscription struct {
+	UserID    uint
+	SessionID uint
+	C         chan EventMessage
+	Done      chan struct{} // Se cierra si el cliente no consume a tiempo (debe reconectarse)
+
+	once sync.Once
+}
+
+func (s *EventSubscription) close() {
+	s.once.Do(func() { close(s.Done) })
+}
+
+// Distribución de eventos a las conexiones abiertas de cada usuario
+type EventHub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[*EventSubscription]struct{}
+}
+
+// Eventos: cuánto se guardan para retomar (los borra el job de limpieza) y cuántos
+// puede acumular una conexión lenta
+const (
+	eventRetention    = 7 * 24 * time.Hour
+	eventBufferPerSub = 64
+	eventReplayLimit  = 500
+)
+
+var Events = &EventHub{subscribers: map[uint]map[*EventSubscription]struct{}{}}
+
+// Enviar un evento a todas las sesiones del usuario
+func (h *EventHub) Publish(userID uint, eventType string, data interface{}) {
+	h.publish(&models.UserEvent{UserID: userID, Type: eventType}, data)
+}
+
+// Enviar un evento solo a una sesión
+func (h *EventHub) PublishToSession(userID uint, sessionID uint, eventType string, data interface{}) {
+	h.publish(&models.UserEvent{UserID: userID, Type: eventType, DeviceSessionID: &sessionID}, data)
+}
+
+// Enviar un evento a las demás sesiones del usuario (no a la que hizo el cambio)
+func (h *EventHub) PublishFrom(userID uint, originSessionID uint, eventType string, data interface{}) {
+	h.publish(&models.UserEvent{UserID: userID, Type: eventType, OriginSessionID: &originSessionID}, data)
+}
+
+func (h *EventHub) publish(event *models.UserEvent, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Could not encode %s event: %v", event.Type, err)
+		return
+	}
+	event.Data = string(payload)
+
+	if err := config.DB.Create(event).Error; err != nil {
+		log.Printf("Could not store %s event for user %d: %v", event.Type, event.UserID, err)
+		return
+	}
+
+	message := eventMessage(event)
+
+	h.mu.RLock()
+	for sub := range h.subscribers[event.UserID] {
+		if !event.VisibleTo(sub.SessionID) {
+			continue
+		}
+		select {
+		case sub.C <- message:
+		default:
+			sub.close() // Conexión atascada: que se reconecte y retome desde su último ID
+		}
+	}
+	h.mu.RUnlock()
+}
+
+// Abrir una suscripción para una sesión
+func (h *EventHub) Subscribe(userID uint, sessionID uint) *EventSubscription {
+	sub := &EventSubscription{
+		UserID:    userID,
+		SessionID: sessionID,
+		C:         make(chan EventMessage, eventBufferPerSub),
+		Done:      make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[*EventSubscription]struct{}{}
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+func (h *EventHub) Unsubscribe(sub *EventSubscription) {
+	h.mu.Lock()
+	delete(h.subscribers[sub.UserID], sub)
+	if len(h.subscribers[sub.UserID]) == 0 {
+		delete(h.subscribers, sub.UserID)
+	}
+	h.mu.Unlock()
+	sub.close()
+}
+
+// Eventos guardados después de lastEventID que la sesión debe recibir (para retomar)
+func (h *EventHub) Since(userID uint, sessionID uint, lastEventID uint) []EventMessage {
+	var events []models.UserEvent
+	config.DB.Where("user_id = ? AND id > ?", userID, lastEventID).
+		Order("id asc").Limit(eventReplayLimit).Find(&events)
+
+	messages := make([]EventMessage, 0, len(events))
+	for i := range events {
+		if events[i].VisibleTo(sessionID) {
+			messages = append(messages, eventMessage(&events[i]))
+		}
+	}
+	return messages
+}
+
+// Heartbeat (no tiene ID: no se retoma)
+func PingMessage() EventMessage {
+	return EventMessage{Type: models.EventPing, Timestamp: time.Now()}
+}
+
+func eventMessage(event *models.UserEvent) EventMessage {
+	return EventMessage{
+		ID:          event.ID,
+		Type:        event.Type,
+		Data:        json.RawMessage(event.Data),
+		Timestamp:   event.CreatedAt,
+		sessionOnly: event.DeviceSessionID != nil,
+	}
+}
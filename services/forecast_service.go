@@ -0,0 +1,336 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"time"
+)
+
+type ForecastService struct{}
+
+// Tope de vencimientos por plantilla en una proyección
+const maxForecastOccurrences = 400
+
+// Movimiento esperado en una fecha (monto con signo: positivo entra, negativo sale)
+type ForecastEvent struct {
+	Date        string  `json:"date"` // YYYY-MM-DD
+	AccountID   uint    `json:"account_id"`
+	Amount      float64 `json:"amount"`
+	Description string  `json:"description"`
+	Source      string  `json:"source"` // recurring_transaction, loan, loan_installment
+	SourceID    uint    `json:"source_id"`
+	Overdue     bool    `json:"overdue,omitempty"` // Vencido sin pagar: se proyecta hoy
+}
+
+// Saldo proyectado al cierre de un día
+type ForecastDay struct {
+	Date           string          `json:"date"`
+	Inflow         float64         `json:"inflow"`
+	Outflow        float64         `json:"outflow"`
+	Balance        float64         `json:"balance"`
+	BelowZero      bool            `json:"below_zero"`
+	BelowThreshold bool            `json:"below_threshold"`
+	Events         []ForecastEvent `json:"events"`
+}
+
+// Día en que la proyección cruza hacia abajo el cero o el mínimo de la cuenta
+type ForecastAlert struct {
+	Date    string  `json:"date"`
+	Balance float64 `json:"balance"`
+	Reason  string  `json:"reason"` // below_zero, below_threshold
+}
+
+type AccountForecast struct {
+	AccountID      uint            `json:"account_id"`
+	Name           string          `json:"name"`
+	Currency       string          `json:"currency"`
+	CurrentBalance float64         `json:"current_balance"`
+	Threshold      float64         `json:"threshold"`
+	LowestBalance  float64         `json:"lowest_balance"`
+	LowestDate     string          `json:"lowest_date"`
+	Alerts         []ForecastAlert `json:"alerts"`
+	Days           []ForecastDay   `json:"days"`
+}
+
+type Forecast struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Accounts []AccountForecast `json:"accounts"`
+}
+
+// Opciones del pronóstico
+type ForecastInput struct {
+	Days      int      // Días desde hoy (incluido)
+	AccountID *uint    // Solo esta cuenta
+	Threshold *float64 // Reemplaza el mínimo guardado en cada cuenta
+}
+
+// Proyectar día a día el saldo de las cuentas con los vencimientos de las
+// plantillas recurrentes y de los préstamos. Los días son de calendario en la
+// zona del usuario; lo vencido y no pagado se proyecta hoy
+func (fs *ForecastService) Forecast(userID uint, input ForecastInput) (*Forecast, error) {
+	query := config.DB.Where("user_id = ? AND is_active = true", userID)
+	if input.AccountID != nil {
+		query = query.Where("id = ?", *input.AccountID)
+	}
+
+	var accounts []models.Account
+	if err := query.Order("id asc").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	if input.AccountID != nil && len(accounts) == 0 {
+		return nil, ErrAccountNotFound
+	}
+
+	loc := models.UserLocation(config.DB, userID)
+	today := models.LocalToday(time.Now(), loc)
+	from := today.Format("2006-01-02")
+	to := today.AddDate(0, 0, input.Days-1).Format("2006-01-02")
+
+	events := fs.recurringEvents(userID, from, to)
+	events = append(events, fs.loanEvents(userID, from, to)...)
+
+	// Agrupar por cuenta y día
+	byAccount := map[uint]map[string][]ForecastEvent{}
+	for _, event := range events {
+		if byAccount[event.AccountID] == nil {
+			byAccount[event.AccountID] = map[string][]ForecastEvent{}
+		}
+		byAccount[event.AccountID][event.Date] = append(byAccount[event.AccountID][event.Date], event)
+	}
+
+	forecast := &Forecast{From: from, To: to, Accounts: []AccountForecast{}}
+	for _, account := range accounts {
+		threshold := account.LowBalanceThreshold
+		if input.Threshold != nil {
+			threshold = *input.Threshold
+		}
+
+		balance := models.RoundMoney(account.GetBalance(config.DB))
+		result := AccountForecast{
+			AccountID:      account.ID,
+			Name:           account.Name,
+			Currency:       account.Currency,
+			CurrentBalance: balance,
+			Threshold:      threshold,
+			LowestBalance:  balance,
+			LowestDate:     from,
+			Alerts:         []ForecastAlert{},
+			Days:           make([]ForecastDay, 0, input.Days),
+		}
+
+		wasBelowZero, wasBelowThreshold := balance < 0, balance < threshold
+		for i := 0; i < input.Days; i++ {
+			date := today.AddDate(0, 0, i).Format("2006-01-02")
+			day := ForecastDay{Date: date, Events: byAccount[account.ID][date]}
+			if day.Events == nil {
+				day.Events = []ForecastEvent{}
+			}
+
+			for _, event := range day.Events {
+				if event.Amount >= 0 {
+					day.Inflow = models.RoundMoney(day.Inflow + event.Amount)
+				} else {
+					day.Outflow = models.RoundMoney(day.Outflow - event.Amount)
+				}
+				balance = models.RoundMoney(balance + event.Amount)
+			}
+
+			day.Balance = balance
+			day.BelowZero = balance < 0
+			day.BelowThreshold = balance < threshold
+
+			// Avisar solo el día en que cruza el límite, no cada día que sigue debajo
+			if day.BelowZero && !wasBelowZero {
+				result.Alerts = append(result.Alerts, ForecastAlert{Date: date, Balance: balance, Reason: "below_zero"})
+			} else if day.BelowThreshold && !wasBelowThreshold && !day.BelowZero {
+				result.Alerts = append(result.Alerts, ForecastAlert{Date: date, Balance: balance, Reason: "below_threshold"})
+			}
+			wasBelowZero, wasBelowThreshold = day.BelowZero, day.BelowThreshold
+
+			if balance < result.LowestBalance {
+				result.LowestBalance = balance
+				result.LowestDate = date
+			}
+			result.Days = append(result.Days, day)
+		}
+
+		forecast.Accounts = append(forecast.Accounts, result)
+	}
+
+	return forecast, nil
+}
+
+// Vencimientos de las plantillas activas entre from y to (fechas YYYY-MM-DD).
+// El periodo pendiente usa lo que falta de un abono y su fecha pospuesta
+func (fs *ForecastService) recurringEvents(userID uint, from string, to string) []ForecastEvent {
+	var templates []models.RecurringTransaction
+	config.DB.Where("user_id = ? AND is_active = true", userID).Find(&templates)
+	end, _ := time.Parse("2006-01-02", to)
+
+	// Saldo de los préstamos con cuotas automáticas (las cuotas no pasan de él)
+	loanBalances := map[uint]float64{}
+
+	events := []ForecastEvent{}
+	for i := range templates {
+		recurring := &templates[i]
+		if recurring.HasEnded() {
+			continue
+		}
+
+		if recurring.LoanID != nil {
+			if _, ok := loanBalances[*recurring.LoanID]; !ok {
+				var loan models.Loan
+				if config.DB.First(&loan, *recurring.LoanID).Error != nil || loan.IsClosed() {
+					continue
+				}
+				loanBalances[*recurring.LoanID] = loan.GetBalance(config.DB)
+			}
+		}
+
+		scheduled, dueDate, amount := recurring.NextDueDate, recurring.NextDueDate, recurring.Amount
+		var occurrence models.RecurringOccurrence
+		err := config.DB.Where("recurring_transaction_id = ? AND status IN ?", recurring.ID, models.OpenOccurrenceStatuses).
+			Order("scheduled_date asc").First(&occurrence).Error
+		if err == nil {
+			scheduled, dueDate, amount = occurrence.ScheduledDate, occurrence.DueDate, occurrence.Remaining()
+		}
+
+		rule, err := recurring.Rule()
+		if err != nil {
+			continue
+		}
+
+		// Vencimientos siguientes hasta el fin del periodo (un día de margen por la zona)
+		upcoming := rule.Between(scheduled.Add(time.Second), end.AddDate(0, 0, 1), true)
+
+		// El tope evita proyectar cientos de vencimientos si NextDueDate quedó muy atrás
+		for i := 0; i <= len(upcoming) && i < maxForecastOccurrences; i++ {
+			if i > 0 {
+				next := upcoming[i-1]
+				if recurring.EndDate != nil && next.After(*recurring.EndDate) {
+					break
+				}
+				dueDate, amount = next, recurring.Amount
+			}
+
+			date := dueDate.Format("2006-01-02")
+			if date > to {
+				break
+			}
+
+			overdue := date < from
+			if overdue {
+				date = from
+			}
+
+			if recurring.LoanID != nil {
+				balance := loanBalances[*recurring.LoanID]
+				if amount > balance {
+					amount = balance
+				}
+				loanBalances[*recurring.LoanID] = models.RoundMoney(balance - amount)
+			}
+			if amount > 0 {
+				events = append(events, fs.templateEvents(recurring, date, amount, overdue)...)
+			}
+		}
+	}
+
+	return events
+}
+
+// Movimientos de una ocurrencia: las transferencias salen de una cuenta y entran a otra
+func (fs *ForecastService) templateEvents(recurring *models.RecurringTransaction, date string, amount float64, overdue bool) []ForecastEvent {
+	event := ForecastEvent{
+		Date:        date,
+		AccountID:   recurring.AccountID,
+		Amount:      models.SignedAmount(recurring.Direction(), amount),
+		Description: recurring.Description,
+		Source:      "recurring_transaction",
+		SourceID:    recurring.ID,
+		Overdue:     overdue,
+	}
+	if !recurring.IsTransfer() || recurring.ToAccountID == nil {
+		return []ForecastEvent{event}
+	}
+
+	event.Amount = models.SignedAmount("out", amount)
+	incoming := event
+	incoming.AccountID = *recurring.ToAccountID
+	incoming.Amount = models.SignedAmount("in", amount)
+	return []ForecastEvent{event, incoming}
+}
+
+// Vencimientos de los préstamos abiertos sin cuotas automáticas: cada cuota pendiente
+// del plan o, si no tiene plan, el saldo en su fecha de vencimiento.
+// Lo prestado entra a la cuenta del préstamo y lo recibido sale de ella
+func (fs *ForecastService) loanEvents(userID uint, from string, to string) []ForecastEvent {
+	var loans []models.Loan
+	config.DB.Where("user_id = ? AND status NOT IN ?", userID, models.ClosedLoanStatuses).
+		Where("id NOT IN (SELECT loan_id FROM recurring_transactions WHERE loan_id IS NOT NULL AND is_active = true AND deleted_at IS NULL)").
+		Find(&loans)
+
+	var user models.User
+	config.DB.First(&user, userID)
+	text := user.Localizer()
+
+	events := []ForecastEvent{}
+	for i := range loans {
+		loan := &loans[i]
+		direction := "out"
+		if loan.Type == "given" {
+			direction = "in"
+		}
+		description := loanReminderSubject(text, loan)
+
+		if loan.HasInstallmentPlan() {
+			var installments []models.LoanInstallment
+			config.DB.Where("loan_id = ? AND status <> ?", loan.ID, "paid").Order("number asc").Find(&installments)
+			for _, installment := range installments {
+				date := installment.DueDate.Format("2006-01-02")
+				if date > to || installment.Remaining() <= 0 {
+					continue
+				}
+				overdue := date < from
+				if overdue {
+					date = from
+				}
+				events = append(events, ForecastEvent{
+					Date:        date,
+					AccountID:   loan.AccountID,
+					Amount:      models.SignedAmount(direction, installment.Remaining()),
+					Description: text.T("loan.installment", map[string]interface{}{"Subject": description, "Number": installment.Number}),
+					Source:      "loan_installment",
+					SourceID:    installment.ID,
+					Overdue:     overdue,
+				})
+			}
+			continue
+		}
+
+		if loan.DueDate == nil {
+			continue
+		}
+		date := loan.DueDate.Format("2006-01-02")
+		balance := models.RoundMoney(loan.GetBalance(config.DB))
+		if date > to || balance <= 0 {
+			continue
+		}
+		overdue := date < from
+		if overdue {
+			date = from
+		}
+		events = append(events, ForecastEvent{
+			Date:        date,
+			AccountID:   loan.AccountID,
+			Amount:      models.SignedAmount(direction, balance),
+			Description: description,
+			Source:      "loan",
+			SourceID:    loan.ID,
+			Overdue:     overdue,
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type GroupService struct{}
+
+// Integrante nuevo: un contacto del usuario o solo un nombre
+type GroupMemberInput struct {
+	Name      string
+	ContactID *uint
+}
+
+// Datos de un grupo nuevo (ya validados por quien llama)
+type CreateGroupInput struct {
+	Name        string
+	Description string
+	Currency    string // Por defecto, PEN
+	Members     []GroupMemberInput
+}
+
+// Crear un grupo con el usuario como integrante y los demás integrantes en una sola
+// transacción
+func (gs *GroupService) CreateGroup(userID uint, input CreateGroupInput) (*models.Group, error) {
+	group := models.Group{
+		UserID:      userID,
+		Name:        input.Name,
+		Description: input.Description,
+		Currency:    input.Currency,
+		IsActive:    true,
+	}
+	if group.Currency == "" {
+		group.Currency = "PEN"
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+
+		// El creador siempre es integrante del grupo
+		me := models.GroupMember{GroupID: group.ID, Name: user.Name, IsMe: true}
+		if err := tx.Create(&me).Error; err != nil {
+			return err
+		}
+
+		for _, member := range input.Members {
+			if _, err := gs.addMember(tx, userID, group.ID, member); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Recargar con los campos desencriptados
+	config.DB.Preload("Members").First(&group, group.ID)
+	return &group, nil
+}
+
+// Agregar un integrante a un grupo del usuario
+func (gs *GroupService) AddMember(userID uint, groupID uint, input GroupMemberInput) (*models.GroupMember, error) {
+	var member *models.GroupMember
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var group models.Group
+		if err := gs.findGroup(tx, userID, groupID, &group); err != nil {
+			return err
+		}
+
+		var err error
+		member, err = gs.addMember(tx, userID, group.ID, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Recargar con el nombre desencriptado
+	config.DB.First(member, member.ID)
+	return member, nil
+}
+
+// Eliminar un grupo con sus gastos, liquidaciones e integrantes en una sola
+// transacción. Las transacciones de liquidaciones ya registradas se mantienen en
+// mis cuentas
+func (gs *GroupService) DeleteGroup(userID uint, groupID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var group models.Group
+		if err := gs.findGroup(tx, userID, groupID, &group); err != nil {
+			return err
+		}
+
+		var expenseIDs []uint
+		if err := tx.Model(&models.GroupExpense{}).Where("group_id = ?", group.ID).Pluck("id", &expenseIDs).Error; err != nil {
+			return err
+		}
+		if len(expenseIDs) > 0 {
+			if err := tx.Where("group_expense_id IN ?", expenseIDs).Delete(&models.GroupExpenseShare{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.GroupExpense{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.GroupSettlement{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.GroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+}
+
+// Eliminar un gasto del grupo y su reparto en una sola transacción
+func (gs *GroupService) DeleteExpense(userID uint, groupID uint, expenseID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var group models.Group
+		if err := gs.findGroup(tx, userID, groupID, &group); err != nil {
+			return err
+		}
+
+		var expense models.GroupExpense
+		if err := tx.Where("id = ? AND group_id = ?", expenseID, group.ID).First(&expense).Error; err != nil {
+			return ErrGroupExpenseNotFound
+		}
+
+		if err := tx.Where("group_expense_id = ?", expense.ID).Delete(&models.GroupExpenseShare{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&expense).Error
+	})
+}
+
+// Datos de una liquidación entre integrantes (ya validados por quien llama)
+type CreateGroupSettlementInput struct {
+	FromMemberID uint
+	ToMemberID   uint
+	Amount       float64
+	Date         *time.Time // Por defecto, hoy
+	AccountID    *uint      // Registrar en mi cuenta si participo
+	Notes        string
+}
+
+// Registrar un pago entre integrantes y, si participo y se indica una cuenta, su
+// transacción en una sola transacción de BD
+func (gs *GroupService) CreateSettlement(userID uint, groupID uint, input CreateGroupSettlementInput) (*models.GroupSettlement, error) {
+	var settlement models.GroupSettlement
+
+	date := time.Now()
+	if input.Date != nil {
+		date = *input.Date
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var group models.Group
+		if err := gs.findGroup(tx, userID, groupID, &group); err != nil {
+			return err
+		}
+
+		var from, to models.GroupMember
+		if err := tx.Where("id = ? AND group_id = ?", input.FromMemberID, group.ID).First(&from).Error; err != nil {
+			return ErrGroupMemberNotFound
+		}
+		if err := tx.Where("id = ? AND group_id = ?", input.ToMemberID, group.ID).First(&to).Error; err != nil {
+			return ErrGroupMemberNotFound
+		}
+
+		settlement = models.GroupSettlement{
+			GroupID:      group.ID,
+			FromMemberID: from.ID,
+			ToMemberID:   to.ID,
+			Amount:       models.RoundMoney(input.Amount),
+			Date:         date,
+		}
+		if err := tx.Create(&settlement).Error; err != nil {
+			return err
+		}
+		if input.AccountID == nil {
+			return nil
+		}
+
+		if !from.IsMe && !to.IsMe {
+			return ErrSettlementNotMine
+		}
+
+		var account models.Account
+		if err := tx.Where("id = ? AND user_id = ?", *input.AccountID, userID).First(&account).Error; err != nil {
+			return ErrAccountNotFound
+		}
+
+		// La transacción se registra por el monto del grupo, sin conversión
+		if account.Currency != group.Currency {
+			return ErrSettlementCurrencyMismatch
+		}
+
+		// Si pago yo, sale dinero de mi cuenta; si me pagan, entra
+		transactionType := "group_settlement_in"
+		description := "Liquidación " + group.Name + ": " + from.Name
+		if from.IsMe {
+			transactionType = "group_settlement_out"
+			description = "Liquidación " + group.Name + ": " + to.Name
+		}
+		direction, _ := models.DirectionForType(transactionType)
+
+		transaction := models.Transaction{
+			UserID:        userID,
+			AccountID:     account.ID,
+			Amount:        models.SignedAmount(direction, settlement.Amount),
+			Direction:     direction,
+			Description:   description,
+			Date:          date,
+			Type:          transactionType,
+			ReferenceID:   &settlement.ID,
+			ReferenceType: "group_settlement",
+			Notes:         input.Notes,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+		settlement.TransactionID = &transaction.ID
+		return tx.Model(&settlement).UpdateColumn("transaction_id", transaction.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+// Eliminar una liquidación y anular la transacción registrada en mi cuenta
+func (gs *GroupService) DeleteSettlement(userID uint, groupID uint, settlementID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var group models.Group
+		if err := gs.findGroup(tx, userID, groupID, &group); err != nil {
+			return err
+		}
+
+		var settlement models.GroupSettlement
+		if err := tx.Where("id = ? AND group_id = ?", settlementID, group.ID).First(&settlement).Error; err != nil {
+			return ErrSettlementNotFound
+		}
+
+		if settlement.TransactionID != nil {
+			if err := tx.Where("id = ? AND user_id = ?", *settlement.TransactionID, userID).Delete(&models.Transaction{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&settlement).Error
+	})
+}
+
+// Cargar un grupo del usuario
+func (gs *GroupService) findGroup(tx *gorm.DB, userID uint, groupID uint, group *models.Group) error {
+	err := tx.Where("id = ? AND user_id = ?", groupID, userID).First(group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrGroupNotFound
+	}
+	return err
+}
+
+// Crear el integrante (con el nombre del contacto si se indica uno)
+func (gs *GroupService) addMember(tx *gorm.DB, userID uint, groupID uint, input GroupMemberInput) (*models.GroupMember, error) {
+	member := models.GroupMember{GroupID: groupID, Name: input.Name}
+
+	if input.ContactID != nil {
+		var contact models.Contact
+		if err := tx.Where("id = ? AND user_id = ?", *input.ContactID, userID).First(&contact).Error; err != nil {
+			return nil, ErrContactNotFound
+		}
+		member.ContactID = &contact.ID
+		member.Name = contact.Name
+	}
+
+	if err := tx.Create(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
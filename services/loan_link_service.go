@@ -0,0 +1,334 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Datos para aceptar una invitación (ya validados por quien llama)
+type AcceptLoanInvitationInput struct {
+	AccountID      uint
+	ExistingLoanID *uint // Vincular un préstamo que ya registré
+}
+
+// Invitar al usuario con ese email a llevar el préstamo en espejo. Si el email no
+// está registrado no se hace nada (sin error, para no revelarlo); una invitación
+// nueva reemplaza a la pendiente
+func (ls *LoanService) InviteLoanCounterparty(userID uint, loanID uint, email string, message string) error {
+	var loan models.Loan
+	var link models.LoanLink
+	var inviter models.User
+	found := false
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+		if loan.IsLinked() {
+			return ErrLoanAlreadyLinked
+		}
+
+		if err := tx.First(&inviter, userID).Error; err != nil {
+			return err
+		}
+		if strings.EqualFold(inviter.Email, email) {
+			return ErrCannotInviteSelf
+		}
+
+		var invitee models.User
+		err := tx.Where("email = ?", email).First(&invitee).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		// Solo una invitación pendiente por préstamo
+		now := time.Now()
+		if err := tx.Model(&models.LoanLink{}).
+			Where("loan_id = ? AND status = ?", loan.ID, models.LoanLinkPending).
+			Updates(map[string]interface{}{"status": models.LoanLinkRevoked, "responded_at": now}).Error; err != nil {
+			return err
+		}
+
+		link = models.LoanLink{
+			LoanID:    loan.ID,
+			InviterID: userID,
+			InviteeID: invitee.ID,
+			Status:    models.LoanLinkPending,
+			Message:   message,
+		}
+		return tx.Create(&link).Error
+	})
+	if err != nil || !found {
+		return err
+	}
+
+	reminderService := &ReminderService{}
+	return reminderService.NotifyLoanInvitation(&link, &loan, inviter.Name)
+}
+
+// Aceptar una invitación: crear el préstamo espejo (o vincular uno existente) y
+// vincular ambos lados en una sola transacción
+func (ls *LoanService) AcceptLoanInvitation(userID uint, linkID uint, input AcceptLoanInvitationInput) (*models.Loan, error) {
+	var mirror models.Loan
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var link models.LoanLink
+		if err := tx.Preload("Inviter").Where("id = ? AND invitee_id = ?", linkID, userID).First(&link).Error; err != nil {
+			return ErrInvitationNotFound
+		}
+
+		if link.Status != models.LoanLinkPending {
+			return ErrInvitationNotPending
+		}
+
+		var source models.Loan
+		if err := tx.First(&source, link.LoanID).Error; err != nil {
+			return ErrInvitedLoanNotFound
+		}
+		if source.IsLinked() {
+			return ErrLoanAlreadyLinked
+		}
+
+		var account models.Account
+		if err := tx.Where("id = ? AND user_id = ?", input.AccountID, userID).First(&account).Error; err != nil {
+			return ErrAccountNotFound
+		}
+
+		// Préstamo que ya había registrado por mi cuenta
+		var existing *models.Loan
+		if input.ExistingLoanID != nil {
+			var loan models.Loan
+			if err := ls.findLoan(tx, userID, *input.ExistingLoanID, &loan); err != nil {
+				return err
+			}
+			if loan.IsLinked() {
+				return ErrLoanAlreadyLinked
+			}
+			if loan.Type != models.MirrorLoanType(source.Type) {
+				return ErrLoanTypeMismatch
+			}
+			existing = &loan
+			mirror = loan
+		} else {
+			created, err := createMirrorLoan(tx, &source, link.Inviter.Name, userID, account.ID)
+			if err != nil {
+				return err
+			}
+			mirror = *created
+		}
+
+		// Vincular ambos préstamos SIN pasar por hooks
+		if err := tx.Model(&source).UpdateColumns(map[string]interface{}{
+			"linked_loan_id": mirror.ID,
+			"linked_user_id": userID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&mirror).UpdateColumns(map[string]interface{}{
+			"linked_loan_id": source.ID,
+			"linked_user_id": source.UserID,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Solo la primera aceptación cambia la invitación
+		result := tx.Model(&models.LoanLink{}).Where("id = ? AND status = ?", link.ID, models.LoanLinkPending).
+			UpdateColumns(map[string]interface{}{
+				"status":         models.LoanLinkAccepted,
+				"mirror_loan_id": mirror.ID,
+				"responded_at":   time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationNotPending
+		}
+
+		// Los pagos que ya registró la contraparte llegan como pendientes
+		if existing == nil {
+			var payments []models.LoanPayment
+			if err := tx.Where("loan_id = ? AND linked_payment_id IS NULL", source.ID).Find(&payments).Error; err != nil {
+				return err
+			}
+			for i := range payments {
+				if err := models.MirrorLoanPayment(tx, &payments[i], &mirror); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Si ya lo tenía registrado con otro monto, ambos lados quedan en disputa
+		if existing != nil && math.Abs(existing.Amount-source.Amount) > 0.005 {
+			source.LinkedLoanID = &mirror.ID
+			if err := models.DisputeLoan(tx, &source, "Loan amounts do not match"); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.First(&mirror, mirror.ID).Error; err != nil {
+			return err
+		}
+		return mirror.UpdateStatus(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Crear recordatorios de vencimiento
+	reminderService := &ReminderService{}
+	reminderService.CreateRemindersForLoan(&mirror)
+
+	return &mirror, nil
+}
+
+// Marcar un préstamo vinculado en disputa (en ambos lados)
+func (ls *LoanService) DisputeLoan(userID uint, loanID uint, reason string) (*models.Loan, error) {
+	var loan models.Loan
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+		if !loan.IsLinked() {
+			return ErrLoanNotLinked
+		}
+		return models.DisputeLoan(tx, &loan, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// Resolver la disputa de un préstamo (en ambos lados)
+func (ls *LoanService) ResolveLoanDispute(userID uint, loanID uint) (*models.Loan, error) {
+	var loan models.Loan
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+		if !loan.IsDisputed() {
+			return ErrLoanNotDisputed
+		}
+		return models.ResolveLoanDispute(tx, &loan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// Marcar un pago vinculado en disputa (y su préstamo)
+func (ls *LoanService) DisputeLoanPayment(userID uint, paymentID uint, reason string) (*models.LoanPayment, error) {
+	var payment models.LoanPayment
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ? AND user_id = ?", paymentID, userID).First(&payment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if payment.LinkedPaymentID == nil {
+			return ErrPaymentNotLinked
+		}
+		return models.DisputeLoanPayment(tx, &payment, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Crear el préstamo espejo (tipo opuesto) con su transacción inicial
+func createMirrorLoan(tx *gorm.DB, source *models.Loan, inviterName string, userID uint, accountID uint) (*models.Loan, error) {
+	contact, err := models.FindOrCreateContact(tx, userID, inviterName)
+	if err != nil {
+		return nil, err
+	}
+
+	mirror := models.Loan{
+		UserID:               userID,
+		AccountID:            accountID,
+		ContactID:            &contact.ID,
+		Amount:               source.Amount,
+		Description:          source.Description,
+		PersonName:           contact.Name,
+		Type:                 models.MirrorLoanType(source.Type),
+		Status:               "pending",
+		LoanDate:             source.LoanDate,
+		DueDate:              source.DueDate,
+		InterestRate:         source.InterestRate,
+		InterestType:         source.InterestType,
+		RatePeriod:           source.RatePeriod,
+		DayCount:             source.DayCount,
+		InstallmentCount:     source.InstallmentCount,
+		InstallmentFrequency: source.InstallmentFrequency,
+		AmortizationMethod:   source.AmortizationMethod,
+	}
+
+	if err := tx.Create(&mirror).Error; err != nil {
+		return nil, err
+	}
+
+	// Recibir un préstamo hace entrar dinero; darlo lo hace salir
+	transactionType := "loan_received"
+	if mirror.Type == "given" {
+		transactionType = "loan_given"
+	}
+	direction, _ := models.DirectionForType(transactionType)
+
+	if err := tx.First(&mirror, mirror.ID).Error; err != nil {
+		return nil, err
+	}
+
+	transaction := models.Transaction{
+		UserID:        userID,
+		AccountID:     accountID,
+		Amount:        models.SignedAmount(direction, mirror.Amount),
+		Direction:     direction,
+		Description:   "Préstamo: " + mirror.Description,
+		Date:          mirror.LoanDate,
+		Type:          transactionType,
+		ReferenceID:   &mirror.ID,
+		ReferenceType: "loan",
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return nil, err
+	}
+
+	// Copiar el cronograma de cuotas de la contraparte
+	var installments []models.LoanInstallment
+	if err := tx.Where("loan_id = ?", source.ID).Order("number asc").Find(&installments).Error; err != nil {
+		return nil, err
+	}
+	for i := range installments {
+		installments[i].ID = 0
+		installments[i].LoanID = mirror.ID
+		installments[i].UserID = userID
+		installments[i].PaidAmount = 0
+		installments[i].Status = "pending"
+		installments[i].PaidAt = nil
+	}
+	if len(installments) > 0 {
+		if err := tx.Create(&installments).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &mirror, nil
+}
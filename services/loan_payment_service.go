@@ -0,0 +1,367 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Datos de un pago nuevo (ya validados por quien llama)
+type CreateLoanPaymentInput struct {
+	AccountID   uint
+	Amount      float64
+	Date        time.Time
+	Description string
+	Notes       string
+}
+
+// Cambios de un pago pendiente; los campos vacíos (o nil) no se modifican
+type UpdateLoanPaymentInput struct {
+	AccountID   *uint
+	Amount      *float64
+	Date        *time.Time
+	Description string
+	Notes       string
+}
+
+// Registrar un pago pendiente de confirmación
+func (ls *LoanService) CreateLoanPayment(userID uint, loanID uint, input CreateLoanPaymentInput) (*models.LoanPayment, error) {
+	var payment models.LoanPayment
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var loan models.Loan
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+
+		// Verificar que la cuenta pertenece al usuario
+		var account models.Account
+		if err := tx.Where("id = ? AND user_id = ?", input.AccountID, userID).First(&account).Error; err != nil {
+			return ErrAccountNotFound
+		}
+
+		// Verificar que el monto no exceda el balance pendiente
+		if input.Amount > loan.GetBalance(tx) {
+			return ErrPaymentExceedsBalance
+		}
+
+		// TransactionID permanece nil (no confirmado)
+		payment = models.LoanPayment{
+			LoanID:      loan.ID,
+			UserID:      userID,
+			AccountID:   input.AccountID,
+			Amount:      input.Amount,
+			Date:        input.Date,
+			Description: input.Description,
+			Notes:       input.Notes,
+		}
+		return ls.insertPendingPayment(tx, &loan, &payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Cargar relaciones para la respuesta
+	config.DB.Preload("Account").Preload("Loan").First(&payment, payment.ID)
+	return &payment, nil
+}
+
+// Pago que reporta la contraparte desde un enlace compartido (ya validado por quien llama)
+type ReportLoanPaymentInput struct {
+	ShareID uint
+	Amount  float64
+	Date    time.Time
+	Note    string
+}
+
+// Registrar como pendiente un pago reportado por la contraparte, en la cuenta del
+// préstamo; el dueño puede cambiarla antes de confirmar
+func (ls *LoanService) ReportLoanPayment(loanID uint, input ReportLoanPaymentInput) (*models.LoanPayment, error) {
+	var loan models.Loan
+	var payment models.LoanPayment
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&loan, loanID).Error; err != nil {
+			return ErrLoanNotFound
+		}
+
+		if loan.IsClosed() {
+			return ErrLoanClosed
+		}
+		if input.Date.Before(loan.LoanDate.Truncate(24 * time.Hour)) {
+			return ErrPaymentBeforeLoanDate
+		}
+		if input.Amount > loan.GetBalance(tx) {
+			return ErrPaymentExceedsBalance
+		}
+
+		payment = models.LoanPayment{
+			LoanID:             loan.ID,
+			UserID:             loan.UserID,
+			AccountID:          loan.AccountID,
+			Amount:             input.Amount,
+			Date:               input.Date,
+			Description:        "Pago reportado por " + loan.PersonName,
+			Notes:              input.Note,
+			ReportedViaShareID: &input.ShareID,
+		}
+		return ls.insertPendingPayment(tx, &loan, &payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Avisar al dueño (fuera de la transacción)
+	config.DB.First(&payment, payment.ID)
+	reminderService := &ReminderService{}
+	reminderService.NotifyLoanPaymentReported(&loan, &payment)
+
+	return &payment, nil
+}
+
+// Guardar un pago pendiente; en un préstamo vinculado la contraparte lo recibe
+// también como pendiente
+func (ls *LoanService) insertPendingPayment(tx *gorm.DB, loan *models.Loan, payment *models.LoanPayment) error {
+	if err := tx.Create(payment).Error; err != nil {
+		return err
+	}
+	if !loan.IsLinked() {
+		return nil
+	}
+
+	var counterpart models.Loan
+	if err := tx.First(&counterpart, *loan.LinkedLoanID).Error; err != nil {
+		return err
+	}
+	if err := tx.First(payment, payment.ID).Error; err != nil {
+		return err
+	}
+	return models.MirrorLoanPayment(tx, payment, &counterpart)
+}
+
+// Editar un pago pendiente (aún sin confirmar). La copia de la contraparte sigue el
+// cambio; si ya la confirmó, ambos quedan en disputa
+func (ls *LoanService) UpdateLoanPayment(userID uint, paymentID uint, input UpdateLoanPaymentInput) (*models.LoanPayment, error) {
+	var payment models.LoanPayment
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findPayment(tx, userID, paymentID, &payment); err != nil {
+			return err
+		}
+
+		if payment.IsConfirmed() {
+			return ErrPaymentConfirmedEdit
+		}
+
+		// Verificar cuenta si se está cambiando
+		if input.AccountID != nil {
+			var account models.Account
+			if err := tx.Where("id = ? AND user_id = ?", *input.AccountID, userID).First(&account).Error; err != nil {
+				return ErrAccountNotFound
+			}
+			payment.AccountID = *input.AccountID
+		}
+
+		// Verificar que el nuevo monto no exceda el balance pendiente
+		if input.Amount != nil {
+			if *input.Amount > payment.Loan.GetBalance(tx) {
+				return ErrPaymentExceedsBalance
+			}
+			payment.Amount = *input.Amount
+		}
+
+		if input.Date != nil {
+			payment.Date = *input.Date
+		}
+		if input.Description != "" {
+			payment.Description = input.Description
+		}
+		if input.Notes != "" {
+			payment.Notes = input.Notes
+		}
+
+		linked, err := LinkedLoanPayment(tx, &payment)
+		if err != nil {
+			return err
+		}
+
+		// Omitir relaciones para no re-guardar el préstamo
+		if err := tx.Omit(clause.Associations).Save(&payment).Error; err != nil {
+			return err
+		}
+		if linked == nil {
+			return nil
+		}
+
+		if linked.IsConfirmed() {
+			return models.DisputeLoanPayment(tx, &payment, "Payment edited after counterparty confirmed it")
+		}
+		return tx.Model(linked).UpdateColumns(map[string]interface{}{
+			"amount": payment.Amount,
+			"date":   payment.Date,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Cargar relaciones para la respuesta
+	config.DB.Preload("Account").Preload("Loan").First(&payment, payment.ID)
+	return &payment, nil
+}
+
+// Eliminar un pago pendiente. Rechazar uno que registró la contraparte es un
+// desacuerdo: queda en disputa en vez de eliminarse (devuelve rejected = true)
+func (ls *LoanService) DeleteLoanPayment(userID uint, paymentID uint) (payment *models.LoanPayment, rejected bool, err error) {
+	payment = &models.LoanPayment{}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findPayment(tx, userID, paymentID, payment); err != nil {
+			return err
+		}
+
+		if payment.IsConfirmed() {
+			return ErrPaymentConfirmedDelete
+		}
+
+		linked, err := LinkedLoanPayment(tx, payment)
+		if err != nil {
+			return err
+		}
+
+		if linked != nil && payment.IsMirror {
+			rejected = true
+			return models.DisputeLoanPayment(tx, payment, "Payment rejected by counterparty")
+		}
+
+		// Soft delete
+		if err := tx.Delete(payment).Error; err != nil {
+			return err
+		}
+		if linked == nil {
+			return nil
+		}
+
+		// Retirar mi pago: la copia pendiente se elimina, la confirmada queda en disputa
+		if !linked.IsConfirmed() {
+			return tx.Delete(linked).Error
+		}
+		return models.DisputeLoanPayment(tx, linked, "Payment withdrawn by counterparty")
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return payment, rejected, nil
+}
+
+// Revertir un pago confirmado: anula su transacción y lo devuelve a pendiente
+func (ls *LoanService) ReverseLoanPayment(userID uint, paymentID uint, reason string) (*models.LoanPayment, error) {
+	var payment models.LoanPayment
+	var recurring *models.RecurringTransaction
+	recurringService := &RecurringTransactionService{}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findPayment(tx, userID, paymentID, &payment); err != nil {
+			return err
+		}
+
+		if !payment.IsConfirmed() {
+			return ErrPaymentNotConfirmed
+		}
+
+		reversal := models.LoanPaymentReversal{
+			LoanPaymentID: payment.ID,
+			LoanID:        payment.LoanID,
+			UserID:        userID,
+			TransactionID: *payment.TransactionID,
+			Amount:        payment.Amount,
+			Reason:        reason,
+			ReversedAt:    time.Now(),
+		}
+
+		var transaction models.Transaction
+		if err := tx.Where("id = ? AND user_id = ?", *payment.TransactionID, userID).First(&transaction).Error; err != nil {
+			return err
+		}
+
+		// Anular la transacción generada al confirmar
+		if err := tx.Delete(&transaction).Error; err != nil {
+			return err
+		}
+
+		// Si la registró una plantilla de cuotas, su periodo vuelve a quedar pendiente
+		var err error
+		recurring, err = recurringService.reopenOccurrence(tx, &transaction, payment.Amount)
+		if err != nil {
+			return err
+		}
+
+		// Volver el pago a pendiente SIN pasar por hooks
+		if err := tx.Model(&payment).UpdateColumn("transaction_id", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&reversal).Error; err != nil {
+			return err
+		}
+
+		if err := payment.Loan.UpdateStatus(tx); err != nil {
+			return err
+		}
+
+		// Si la contraparte lo tiene confirmado, ambos lados quedan en disputa
+		linked, err := LinkedLoanPayment(tx, &payment)
+		if err != nil {
+			return err
+		}
+		if linked != nil && linked.IsConfirmed() {
+			return models.DisputeLoanPayment(tx, &payment, "Payment reversed by counterparty")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Refrescar recordatorios (el préstamo puede volver a estar pendiente)
+	reminderService := &ReminderService{}
+	reminderService.CreateRemindersForLoan(&payment.Loan)
+	if recurring != nil {
+		recurringService.refreshReminders(recurring)
+	}
+
+	// Cargar relaciones para la respuesta
+	var finalPayment models.LoanPayment
+	config.DB.Preload("Loan.Account").Preload("Account").Preload("Reversals").First(&finalPayment, payment.ID)
+	return &finalPayment, nil
+}
+
+// Copia del pago en el préstamo vinculado (nil si no tiene)
+func LinkedLoanPayment(db *gorm.DB, payment *models.LoanPayment) (*models.LoanPayment, error) {
+	if payment.LinkedPaymentID == nil {
+		return nil, nil
+	}
+
+	var linked models.LoanPayment
+	err := db.First(&linked, *payment.LinkedPaymentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &linked, nil
+}
+
+// Cargar un pago del usuario con su préstamo
+func (ls *LoanService) findPayment(tx *gorm.DB, userID uint, paymentID uint, payment *models.LoanPayment) error {
+	err := tx.Preload("Loan").Where("id = ? AND user_id = ?", paymentID, userID).First(payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPaymentNotFound
+	}
+	return err
+}
@@ -0,0 +1,602 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LoanService struct{}
+
+// Datos para registrar un préstamo (ya validados por quien llama)
+type CreateLoanInput struct {
+	AccountID    uint
+	Amount       float64
+	Description  string
+	PersonName   string
+	ContactID    *uint
+	Type         string // given, received
+	LoanDate     time.Time
+	DueDate      *time.Time
+	InterestRate float64
+	InterestType string
+	RatePeriod   string
+	DayCount     string
+	Notes        string
+
+	// Plan de cuotas opcional
+	InstallmentCount     int
+	InstallmentFrequency string
+	AmortizationMethod   string
+	FirstInstallmentDate *time.Time
+}
+
+// Crear el préstamo, su transacción inicial y su cronograma en una sola transacción
+func (ls *LoanService) CreateLoan(userID uint, input CreateLoanInput) (*models.Loan, error) {
+	loan := models.Loan{
+		UserID:       userID,
+		AccountID:    input.AccountID,
+		Amount:       input.Amount,
+		Description:  input.Description,
+		Type:         input.Type,
+		LoanDate:     input.LoanDate,
+		DueDate:      input.DueDate,
+		InterestRate: input.InterestRate,
+		InterestType: input.InterestType,
+		RatePeriod:   input.RatePeriod,
+		DayCount:     input.DayCount,
+		Notes:        input.Notes,
+		Status:       "pending",
+	}
+
+	// Asignar valores por defecto si no se proporcionan
+	if loan.InterestType == "" {
+		loan.InterestType = models.InterestTypeSimple
+	}
+	if loan.RatePeriod == "" {
+		loan.RatePeriod = models.RatePeriodAnnual
+	}
+	if loan.DayCount == "" {
+		loan.DayCount = models.DayCountActual365
+	}
+	if input.InstallmentCount > 0 {
+		loan.InstallmentCount = input.InstallmentCount
+		loan.InstallmentFrequency = input.InstallmentFrequency
+		loan.AmortizationMethod = input.AmortizationMethod
+		if loan.InstallmentFrequency == "" {
+			loan.InstallmentFrequency = "monthly"
+		}
+		if loan.AmortizationMethod == "" {
+			loan.AmortizationMethod = models.AmortizationFrench
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Verificar que la cuenta pertenece al usuario
+		var account models.Account
+		if err := tx.Where("id = ? AND user_id = ?", input.AccountID, userID).First(&account).Error; err != nil {
+			return ErrAccountNotFound
+		}
+
+		// Resolver la contraparte: el contacto indicado o uno por nombre
+		contact, err := resolveContact(tx, userID, input.ContactID, input.PersonName)
+		if err != nil {
+			return err
+		}
+		loan.ContactID = &contact.ID
+		loan.PersonName = contact.Name
+
+		if err := tx.Create(&loan).Error; err != nil {
+			return err
+		}
+
+		// Transacción automática del préstamo inicial: dar saca dinero, recibir lo ingresa
+		transactionType := "loan_received"
+		if input.Type == "given" {
+			transactionType = "loan_given"
+		}
+		direction, _ := models.DirectionForType(transactionType)
+
+		transaction := models.Transaction{
+			UserID:        userID,
+			AccountID:     input.AccountID,
+			Amount:        models.SignedAmount(direction, input.Amount),
+			Direction:     direction,
+			Description:   "Préstamo: " + input.Description,
+			Date:          input.LoanDate,
+			Type:          transactionType,
+			ReferenceID:   &loan.ID,
+			ReferenceType: "loan",
+			Notes:         input.Notes,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+
+		// Generar cronograma de cuotas si se pidió un plan
+		if !loan.HasInstallmentPlan() {
+			return nil
+		}
+
+		anchor, firstOffset := InstallmentAnchor(loan.LoanDate, input.FirstInstallmentDate)
+		installments := loan.BuildInstallments(loan.Amount, loan.LoanDate, anchor, firstOffset, loan.InstallmentCount, 1)
+		if err := tx.Create(&installments).Error; err != nil {
+			return err
+		}
+
+		// Sin fecha de vencimiento, el préstamo vence con la última cuota
+		if loan.DueDate == nil {
+			lastDueDate := installments[len(installments)-1].DueDate
+			if err := tx.Model(&loan).UpdateColumn("due_date", lastDueDate).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Crear recordatorios de vencimiento (fuera de la transacción)
+	reminderService := &ReminderService{}
+	reminderService.CreateRemindersForLoan(&loan)
+
+	// Recargar con los campos desencriptados y relaciones
+	config.DB.Preload("Account").Preload("Installments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number asc")
+	}).First(&loan, loan.ID)
+
+	return &loan, nil
+}
+
+// Cambios de un préstamo; los campos vacíos (o nil) no se modifican
+type UpdateLoanInput struct {
+	Description  string
+	PersonName   string
+	ContactID    *uint
+	DueDate      *time.Time
+	InterestRate *float64
+	InterestType string
+	RatePeriod   string
+	DayCount     string
+	Notes        string
+}
+
+// Actualizar los datos de un préstamo y recalcular su status
+func (ls *LoanService) UpdateLoan(userID uint, loanID uint, input UpdateLoanInput) (*models.Loan, error) {
+	var loan models.Loan
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+
+		if input.Description != "" {
+			loan.Description = input.Description
+		}
+		if input.ContactID != nil || input.PersonName != "" {
+			contact, err := resolveContact(tx, userID, input.ContactID, input.PersonName)
+			if err != nil {
+				return err
+			}
+			loan.ContactID = &contact.ID
+			loan.PersonName = contact.Name
+		}
+		if input.DueDate != nil {
+			loan.DueDate = input.DueDate
+		}
+		if input.InterestRate != nil {
+			loan.InterestRate = *input.InterestRate
+		}
+		if input.InterestType != "" {
+			loan.InterestType = input.InterestType
+		}
+		if input.RatePeriod != "" {
+			loan.RatePeriod = input.RatePeriod
+		}
+		if input.DayCount != "" {
+			loan.DayCount = input.DayCount
+		}
+		if input.Notes != "" {
+			loan.Notes = input.Notes
+		}
+
+		if err := tx.Save(&loan).Error; err != nil {
+			return err
+		}
+
+		// Los cambios de interés pueden cambiar el status
+		return loan.UpdateStatus(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Recargar con los campos desencriptados
+	config.DB.First(&loan, loan.ID)
+
+	// Refrescar recordatorios (la fecha de vencimiento pudo cambiar)
+	reminderService := &ReminderService{}
+	reminderService.CreateRemindersForLoan(&loan)
+
+	return &loan, nil
+}
+
+// Eliminar un préstamo sin pagos confirmados; si estaba vinculado, la contraparte
+// conserva el suyo, desvinculado y en disputa
+func (ls *LoanService) DeleteLoan(userID uint, loanID uint) error {
+	var loan models.Loan
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+
+		// Verificar que no tenga pagos confirmados
+		var confirmedPayments int64
+		if err := tx.Model(&models.LoanPayment{}).Where("loan_id = ? AND transaction_id IS NOT NULL", loan.ID).
+			Count(&confirmedPayments).Error; err != nil {
+			return err
+		}
+		if confirmedPayments > 0 {
+			return ErrLoanHasConfirmedPayments
+		}
+
+		// Soft delete
+		if err := tx.Delete(&loan).Error; err != nil {
+			return err
+		}
+		if !loan.IsLinked() {
+			return nil
+		}
+
+		var counterpart models.Loan
+		if err := tx.Where("id = ?", *loan.LinkedLoanID).Limit(1).Find(&counterpart).Error; err != nil {
+			return err
+		}
+		if counterpart.ID == 0 {
+			return nil
+		}
+		if err := tx.Model(&counterpart).UpdateColumns(map[string]interface{}{
+			"linked_loan_id": nil,
+			"linked_user_id": nil,
+		}).Error; err != nil {
+			return err
+		}
+		counterpart.LinkedLoanID = nil
+		return models.DisputeLoan(tx, &counterpart, "Loan deleted by counterparty")
+	})
+	if err != nil {
+		return err
+	}
+
+	// Cancelar recordatorios pendientes
+	reminderService := &ReminderService{}
+	reminderService.CancelRemindersForLoan(loan.ID)
+	return nil
+}
+
+// Nuevo plan de cuotas para el saldo pendiente
+type RescheduleLoanInput struct {
+	InstallmentCount     int
+	InstallmentFrequency string
+	AmortizationMethod   string
+	FirstInstallmentDate *time.Time
+}
+
+// Reprogramar el saldo pendiente en un nuevo plan de cuotas. Devuelve el cronograma completo
+func (ls *LoanService) RescheduleLoan(userID uint, loanID uint, input RescheduleLoanInput) ([]models.LoanInstallment, error) {
+	var loan models.Loan
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+
+		if err := loan.UpdateStatus(tx); err != nil {
+			return err
+		}
+		if loan.IsClosed() {
+			return ErrLoanClosed
+		}
+
+		// Actualizar configuración del plan
+		loan.InstallmentCount = input.InstallmentCount
+		if input.InstallmentFrequency != "" {
+			loan.InstallmentFrequency = input.InstallmentFrequency
+		}
+		if input.AmortizationMethod != "" {
+			loan.AmortizationMethod = input.AmortizationMethod
+		}
+		if loan.InstallmentFrequency == "" {
+			loan.InstallmentFrequency = "monthly"
+		}
+		if loan.AmortizationMethod == "" {
+			loan.AmortizationMethod = models.AmortizationFrench
+		}
+
+		// Solo actualizar el plan SIN pasar por hooks
+		if err := tx.Model(&loan).UpdateColumns(map[string]interface{}{
+			"installment_count":     loan.InstallmentCount,
+			"installment_frequency": loan.InstallmentFrequency,
+			"amortization_method":   loan.AmortizationMethod,
+		}).Error; err != nil {
+			return err
+		}
+
+		anchor, firstOffset := InstallmentAnchor(time.Now(), input.FirstInstallmentDate)
+		return loan.RescheduleInstallments(tx, anchor, firstOffset)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// La fecha de vencimiento cambió con el nuevo cronograma
+	reminderService := &ReminderService{}
+	reminderService.CreateRemindersForLoan(&loan)
+
+	var installments []models.LoanInstallment
+	config.DB.Where("loan_id = ?", loan.ID).Order("number asc").Find(&installments)
+	return installments, nil
+}
+
+// Datos opcionales de una condonación
+type ForgiveLoanInput struct {
+	Date       *time.Time // Por defecto, hoy
+	Reason     string
+	CategoryID *uint // Categoría de la pérdida o ganancia
+}
+
+// Perdonar parte del saldo de un préstamo
+func (ls *LoanService) ForgiveLoan(userID uint, loanID uint, amount float64, input ForgiveLoanInput) (*models.Loan, *models.LoanForgiveness, error) {
+	return ls.forgiveBalance(userID, loanID, models.ForgivenessPartial, amount, input)
+}
+
+// Dar por perdido todo el saldo pendiente de un préstamo
+func (ls *LoanService) WriteOffLoan(userID uint, loanID uint, input ForgiveLoanInput) (*models.Loan, *models.LoanForgiveness, error) {
+	return ls.forgiveBalance(userID, loanID, models.ForgivenessWriteOff, 0, input)
+}
+
+// Registrar la condonación y sus dos transacciones en una sola transacción de BD.
+// Al dar por perdido se condona todo el saldo a la fecha (amount se ignora)
+func (ls *LoanService) forgiveBalance(userID uint, loanID uint, kind string, amount float64, input ForgiveLoanInput) (*models.Loan, *models.LoanForgiveness, error) {
+	var loan models.Loan
+	var forgiveness models.LoanForgiveness
+
+	date := time.Now()
+	if input.Date != nil {
+		date = *input.Date
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ls.findLoan(tx, userID, loanID, &loan); err != nil {
+			return err
+		}
+
+		// Un préstamo en disputa no tiene saldo acordado
+		if loan.IsDisputed() {
+			return ErrLoanDisputed
+		}
+
+		// Con fecha anterior al último pago, ese pago quedaría aplicado sobre un saldo
+		// ya condonado
+		var lastPayment models.LoanPayment
+		err := tx.Where("loan_id = ? AND transaction_id IS NOT NULL", loan.ID).Order("date DESC").First(&lastPayment).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && date.Before(lastPayment.Date) {
+			return ErrForgivenessBeforeLastPayment
+		}
+
+		// No se puede perdonar más que el saldo pendiente a esa fecha ni que el actual
+		balance := math.Min(loan.Snapshot(tx, date).PayoffAmount, loan.Snapshot(tx, time.Now()).PayoffAmount)
+		if kind == models.ForgivenessWriteOff {
+			if balance <= 0 {
+				return ErrLoanNoBalance
+			}
+			amount = balance
+		} else if amount > balance {
+			return ErrForgivenessExceedsBalance
+		}
+
+		if loan.IsClosed() {
+			return ErrLoanClosed
+		}
+
+		// Verificar que la categoría pertenece al usuario (si se proporciona)
+		if input.CategoryID != nil {
+			var category models.Category
+			if err := tx.Where("id = ? AND user_id = ?", *input.CategoryID, userID).First(&category).Error; err != nil {
+				return ErrCategoryNotFound
+			}
+		}
+
+		settlementType, resultType := models.ForgivenessTransactionTypes(loan.Type)
+		settlementDirection, _ := models.DirectionForType(settlementType)
+		resultDirection, _ := models.DirectionForType(resultType)
+
+		description := "Condonación préstamo: " + loan.Description
+		if kind == models.ForgivenessWriteOff {
+			description = "Préstamo incobrable: " + loan.Description
+		}
+
+		// Cierra el saldo como lo haría un pago
+		settlement := models.Transaction{
+			UserID:        userID,
+			AccountID:     loan.AccountID,
+			Amount:        models.SignedAmount(settlementDirection, amount),
+			Direction:     settlementDirection,
+			Description:   description,
+			Date:          date,
+			Type:          settlementType,
+			ReferenceID:   &loan.ID,
+			ReferenceType: "loan",
+		}
+		if err := tx.Create(&settlement).Error; err != nil {
+			return err
+		}
+
+		// Reconoce la pérdida (bad_debt) o la ganancia (debt_forgiveness)
+		result := models.Transaction{
+			UserID:        userID,
+			AccountID:     loan.AccountID,
+			Amount:        models.SignedAmount(resultDirection, amount),
+			Direction:     resultDirection,
+			Description:   description,
+			Date:          date,
+			Type:          resultType,
+			CategoryID:    input.CategoryID,
+			ReferenceID:   &loan.ID,
+			ReferenceType: "loan",
+		}
+		if err := tx.Create(&result).Error; err != nil {
+			return err
+		}
+
+		forgiveness = models.LoanForgiveness{
+			LoanID:                  loan.ID,
+			UserID:                  userID,
+			Kind:                    kind,
+			Amount:                  amount,
+			Date:                    date,
+			Reason:                  input.Reason,
+			SettlementTransactionID: settlement.ID,
+			TransactionID:           result.ID,
+		}
+		if err := tx.Create(&forgiveness).Error; err != nil {
+			return err
+		}
+
+		return loan.UpdateStatus(tx)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Refrescar recordatorios (se cancelan si el préstamo quedó cerrado)
+	reminderService := &ReminderService{}
+	reminderService.CreateRemindersForLoan(&loan)
+
+	config.DB.First(&forgiveness, forgiveness.ID)
+	return &loan, &forgiveness, nil
+}
+
+// Confirmar un pago pendiente: crear su transacción y vincularla en una sola transacción
+func (ls *LoanService) ConfirmLoanPayment(userID uint, paymentID uint) (*models.LoanPayment, *models.Transaction, error) {
+	var payment models.LoanPayment
+	var transaction models.Transaction
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Loan").Where("id = ? AND user_id = ?", paymentID, userID).First(&payment).Error; err != nil {
+			return ErrPaymentNotFound
+		}
+
+		if payment.IsConfirmed() {
+			return ErrPaymentAlreadyConfirmed
+		}
+		if payment.IsDisputed() {
+			return ErrPaymentDisputed
+		}
+
+		created, err := ls.postLoanPayment(tx, &payment, &payment.Loan, nil)
+		if err != nil {
+			return err
+		}
+		transaction = *created
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Refrescar recordatorios (se cancelan si el préstamo quedó pagado)
+	reminderService := &ReminderService{}
+	reminderService.CreateRemindersForLoan(&payment.Loan)
+
+	// Recargar con las relaciones correctas
+	config.DB.Preload("Loan.Account").Preload("Account").First(&payment, payment.ID)
+	config.DB.Preload("Account").First(&transaction, transaction.ID)
+
+	return &payment, &transaction, nil
+}
+
+// Tipo de transacción de un pago: préstamo dado, me pagan (entra); recibido, pago yo (sale)
+func LoanPaymentTransactionType(loan *models.Loan) string {
+	if loan.Type == "given" {
+		return "loan_payment_received"
+	}
+	return "loan_payment_given"
+}
+
+// Crear la transacción de un pago (ya cargado, con campos desencriptados) y marcarlo confirmado
+func (ls *LoanService) postLoanPayment(tx *gorm.DB, payment *models.LoanPayment, loan *models.Loan, idempotencyKey *string) (*models.Transaction, error) {
+	transactionType := LoanPaymentTransactionType(loan)
+	direction, _ := models.DirectionForType(transactionType)
+
+	transaction := models.Transaction{
+		UserID:         payment.UserID,
+		AccountID:      payment.AccountID,
+		Amount:         models.SignedAmount(direction, payment.Amount),
+		Direction:      direction,
+		Description:    "Pago préstamo: " + payment.Description,
+		Date:           payment.Date,
+		Type:           transactionType,
+		ReferenceID:    &loan.ID,
+		ReferenceType:  "loan",
+		Notes:          payment.Notes,
+		IdempotencyKey: idempotencyKey,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return nil, err
+	}
+
+	// Marcar el pago como confirmado SIN pasar por hooks
+	if err := tx.Model(payment).UpdateColumn("transaction_id", transaction.ID).Error; err != nil {
+		return nil, err
+	}
+	payment.TransactionID = &transaction.ID
+
+	if err := loan.UpdateStatus(tx); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// Cargar un préstamo del usuario
+func (ls *LoanService) findLoan(tx *gorm.DB, userID uint, loanID uint, loan *models.Loan) error {
+	err := tx.Where("id = ? AND user_id = ?", loanID, userID).First(loan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrLoanNotFound
+	}
+	return err
+}
+
+// Contacto del préstamo: el indicado (debe ser del usuario) o uno por nombre
+func resolveContact(tx *gorm.DB, userID uint, contactID *uint, personName string) (*models.Contact, error) {
+	if contactID == nil {
+		return models.FindOrCreateContact(tx, userID, personName)
+	}
+
+	var contact models.Contact
+	err := tx.Where("id = ? AND user_id = ?", *contactID, userID).First(&contact).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// Base del cronograma: la primera cuota indicada o un periodo después de from
+func InstallmentAnchor(from time.Time, firstInstallment *time.Time) (time.Time, int) {
+	if firstInstallment != nil {
+		return *firstInstallment, 0
+	}
+	return from, 1
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"sort"
+	"time"
+)
+
+// Opciones del estado de cuenta
+type LoanStatementOptions struct {
+	HidePrivate      bool   // Ocultar nombres de mis cuentas y notas al compartir
+	PaymentReportURL string // Si se indica, se muestra el formulario "ya pagué"
+	PaymentReported  bool   // Mostrar aviso de pago reportado
+}
+
+// Movimiento del estado de cuenta con el saldo de capital resultante
+type LoanStatementEntry struct {
+	Date        time.Time `json:"date"`
+	Kind        string    `json:"kind"` // disbursement, payment, forgiveness
+	Description string    `json:"description"`
+	AccountName string    `json:"account_name,omitempty"`
+	Amount      float64   `json:"amount"`
+	Interest    float64   `json:"interest"`
+	Principal   float64   `json:"principal"`
+	Balance     float64   `json:"balance"`
+}
+
+// Estado de cuenta de un préstamo listo para presentar
+type LoanStatement struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	LoanID      uint      `json:"loan_id"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	OwnerName   string    `json:"owner_name"`
+	PersonName  string    `json:"person_name"`
+	Description string    `json:"description"`
+	Currency    string    `json:"currency"`
+	AccountName string    `json:"account_name,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+
+	// Condiciones
+	Amount               float64    `json:"amount"`
+	LoanDate             time.Time  `json:"loan_date"`
+	DueDate              *time.Time `json:"due_date,omitempty"`
+	InterestRate         float64    `json:"interest_rate"`
+	InterestType         string     `json:"interest_type"`
+	RatePeriod           string     `json:"rate_period"`
+	DayCount             string     `json:"day_count"`
+	InstallmentCount     int        `json:"installment_count"`
+	InstallmentFrequency string     `json:"installment_frequency,omitempty"`
+
+	Entries      []LoanStatementEntry     `json:"entries"`
+	Installments []models.LoanInstallment `json:"installments,omitempty"`
+	Snapshot     models.LoanSnapshot      `json:"snapshot"`
+
+	PaymentReportURL string `json:"-"`
+	PaymentReported  bool   `json:"-"`
+}
+
+// Verificar si el préstamo cobra interés
+func (s *LoanStatement) HasInterest() bool {
+	return s.InterestRate > 0
+}
+
+// Armar el estado de cuenta con los pagos confirmados y el saldo a hoy
+func (ls *LoanService) BuildStatement(loan *models.Loan, opts LoanStatementOptions) (*LoanStatement, error) {
+	var owner models.User
+	if err := config.DB.First(&owner, loan.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	var account models.Account
+	config.DB.Where("id = ?", loan.AccountID).Limit(1).Find(&account)
+
+	var payments []models.LoanPayment
+	if err := config.DB.Preload("Account").
+		Where("loan_id = ? AND transaction_id IS NOT NULL", loan.ID).
+		Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	paymentsByID := make(map[uint]models.LoanPayment, len(payments))
+	for _, payment := range payments {
+		paymentsByID[payment.ID] = payment
+	}
+
+	snapshot := loan.Snapshot(config.DB, time.Now())
+
+	statement := &LoanStatement{
+		GeneratedAt:          time.Now(),
+		LoanID:               loan.ID,
+		Type:                 loan.Type,
+		Status:               loan.Status,
+		OwnerName:            owner.Name,
+		PersonName:           loan.PersonName,
+		Description:          loan.Description,
+		Currency:             account.Currency,
+		Amount:               loan.Amount,
+		LoanDate:             loan.LoanDate,
+		DueDate:              loan.DueDate,
+		InterestRate:         loan.InterestRate,
+		InterestType:         loan.InterestType,
+		RatePeriod:           loan.RatePeriod,
+		DayCount:             loan.DayCount,
+		InstallmentCount:     loan.InstallmentCount,
+		InstallmentFrequency: loan.InstallmentFrequency,
+		Snapshot:             snapshot,
+		PaymentReportURL:     opts.PaymentReportURL,
+		PaymentReported:      opts.PaymentReported,
+	}
+	if statement.Currency == "" {
+		statement.Currency = "PEN"
+	}
+	if !opts.HidePrivate {
+		statement.AccountName = account.Name
+		statement.Notes = loan.Notes
+	}
+
+	config.DB.Where("loan_id = ?", loan.ID).Order("number asc").Find(&statement.Installments)
+
+	// Movimientos que reducen el saldo, en el orden en que se aplicaron
+	entries := []LoanStatementEntry{}
+	for _, split := range snapshot.Splits {
+		entry := LoanStatementEntry{
+			Date:      split.Date,
+			Kind:      "payment",
+			Amount:    split.Amount,
+			Interest:  split.Interest,
+			Principal: split.Principal,
+		}
+		if payment, ok := paymentsByID[split.PaymentID]; ok {
+			entry.Description = payment.Description
+			if !opts.HidePrivate {
+				entry.AccountName = payment.Account.Name
+			}
+		}
+		entries = append(entries, entry)
+	}
+	for _, split := range snapshot.ForgivenSplits {
+		entries = append(entries, LoanStatementEntry{
+			Date:      split.Date,
+			Kind:      "forgiveness",
+			Amount:    split.Amount,
+			Interest:  split.Interest,
+			Principal: split.Principal,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+
+	// El desembolso abre el estado de cuenta
+	statement.Entries = append(statement.Entries, LoanStatementEntry{
+		Date:        loan.LoanDate,
+		Kind:        "disbursement",
+		Description: loan.Description,
+		AccountName: statement.AccountName,
+		Amount:      loan.Amount,
+		Balance:     loan.Amount,
+	})
+
+	balance := loan.Amount
+	for _, entry := range entries {
+		balance -= entry.Principal
+		entry.Balance = models.RoundMoney(balance)
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	return statement, nil
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Textos visibles del estado de cuenta
+var statementLabels = map[string]string{
+	"given":           "Préstamo otorgado",
+	"received":        "Préstamo recibido",
+	"disbursement":    "Desembolso",
+	"payment":         "Pago",
+	"forgiveness":     "Condonación",
+	"simple":          "simple",
+	"compound":        "compuesto",
+	"monthly":         "mensual",
+	"annual":          "anual",
+	"weekly":          "semanal",
+	"biweekly":        "quincenal",
+	"pending":         "Pendiente",
+	"partial_paid":    "Pago parcial",
+	"paid":            "Pagado",
+	"settled":         "Saldado",
+	"written_off":     "Incobrable",
+	"disputed":        "En disputa",
+	"overdue":         "Vencida",
+	"equal_principal": "capital constante",
+	"french":          "cuota fija",
+}
+
+func statementLabel(key string) string {
+	if label, ok := statementLabels[key]; ok {
+		return label
+	}
+	return key
+}
+
+func statementDate(t time.Time) string {
+	return t.Format("02/01/2006")
+}
+
+func statementMoney(currency string, amount float64) string {
+	return fmt.Sprintf("%s %.2f", currency, amount)
+}
+
+// Quién presta según el tipo del préstamo
+func (s *LoanStatement) Lender() string {
+	if s.Type == "given" {
+		return s.OwnerName
+	}
+	return s.PersonName
+}
+
+// Quién debe según el tipo del préstamo
+func (s *LoanStatement) Borrower() string {
+	if s.Type == "given" {
+		return s.PersonName
+	}
+	return s.OwnerName
+}
+
+// Descripción legible del interés pactado
+func (s *LoanStatement) InterestTerms() string {
+	if !s.HasInterest() {
+		return "Sin interés"
+	}
+	return fmt.Sprintf("%.2f%% %s, interés %s (%s)", s.InterestRate, statementLabel(s.RatePeriod), statementLabel(s.InterestType), s.DayCount)
+}
+
+var loanStatementTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"label": statementLabel,
+	"date":  statementDate,
+	"money": func(s *LoanStatement, amount float64) string { return statementMoney(s.Currency, amount) },
+}).Parse(`<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<title>Estado de cuenta - {{.Description}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 820px; margin: 24px auto; padding: 0 16px; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+.muted { color: #777; font-size: 13px; }
+table { width: 100%; border-collapse: collapse; margin-top: 16px; font-size: 14px; }
+th, td { padding: 6px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+td.num, th.num { text-align: right; }
+.terms td:first-child { color: #555; width: 40%; }
+.total { font-size: 18px; font-weight: bold; margin-top: 20px; }
+.notice { background: #e8f5e9; padding: 10px 12px; margin-top: 16px; }
+form { margin-top: 24px; padding-top: 12px; border-top: 1px solid #ddd; }
+form label { display: inline-block; margin: 6px 12px 6px 0; }
+</style>
+</head>
+<body>
+{{$s := .}}
+<h1>Estado de cuenta</h1>
+<div class="muted">{{label .Type}} · Generado el {{date .GeneratedAt}}</div>
+
+<table class="terms">
+<tr><td>Concepto</td><td>{{.Description}}</td></tr>
+<tr><td>Presta</td><td>{{.Lender}}</td></tr>
+<tr><td>Debe</td><td>{{.Borrower}}</td></tr>
+<tr><td>Monto</td><td>{{money $s .Amount}}</td></tr>
+<tr><td>Fecha del préstamo</td><td>{{date .LoanDate}}</td></tr>
+{{if .DueDate}}<tr><td>Vencimiento</td><td>{{date .DueDate}}</td></tr>{{end}}
+<tr><td>Interés</td><td>{{.InterestTerms}}</td></tr>
+{{if .InstallmentCount}}<tr><td>Cuotas</td><td>{{.InstallmentCount}} ({{label .InstallmentFrequency}})</td></tr>{{end}}
+<tr><td>Estado</td><td>{{label .Status}}</td></tr>
+{{if .AccountName}}<tr><td>Cuenta</td><td>{{.AccountName}}</td></tr>{{end}}
+{{if .Notes}}<tr><td>Notas</td><td>{{.Notes}}</td></tr>{{end}}
+</table>
+
+<table>
+<thead><tr><th>Fecha</th><th>Movimiento</th>{{if .AccountName}}<th>Cuenta</th>{{end}}<th class="num">Monto</th>{{if .HasInterest}}<th class="num">Interés</th><th class="num">Capital</th>{{end}}<th class="num">Saldo capital</th></tr></thead>
+<tbody>
+{{range .Entries}}<tr>
+<td>{{date .Date}}</td>
+<td>{{label .Kind}}{{if .Description}} · {{.Description}}{{end}}</td>
+{{if $s.AccountName}}<td>{{.AccountName}}</td>{{end}}
+<td class="num">{{money $s .Amount}}</td>
+{{if $s.HasInterest}}<td class="num">{{money $s .Interest}}</td><td class="num">{{money $s .Principal}}</td>{{end}}
+<td class="num">{{money $s .Balance}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+
+{{if .Installments}}
+<table>
+<thead><tr><th>Cuota</th><th>Vence</th><th class="num">Monto</th><th class="num">Pagado</th><th>Estado</th></tr></thead>
+<tbody>
+{{range .Installments}}<tr><td>{{.Number}}</td><td>{{date .DueDate}}</td><td class="num">{{money $s .Amount}}</td><td class="num">{{money $s .PaidAmount}}</td><td>{{label .Status}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+<table class="terms">
+<tr><td>Capital pendiente</td><td class="num">{{money $s .Snapshot.PrincipalOutstanding}}</td></tr>
+{{if .HasInterest}}<tr><td>Interés devengado</td><td class="num">{{money $s .Snapshot.InterestAccrued}}</td></tr>{{end}}
+{{if .Snapshot.Forgiven}}<tr><td>Condonado</td><td class="num">{{money $s .Snapshot.Forgiven}}</td></tr>{{end}}
+</table>
+<div class="total">Saldo pendiente al {{date .Snapshot.AsOf}}: {{money $s .Snapshot.PayoffAmount}}</div>
+{{if .PaymentReported}}<div class="notice">Pago reportado. Quedará registrado cuando {{.Lender}} lo confirme.</div>{{end}}
+{{if and .PaymentReportURL .Snapshot.PayoffAmount}}
+<form method="post" action="{{.PaymentReportURL}}">
+<strong>¿Ya pagaste?</strong><br>
+<label>Monto <input type="number" name="amount" step="0.01" min="0.01" max="{{.Snapshot.PayoffAmount}}" required></label>
+<label>Fecha <input type="date" name="date" required></label>
+<label>Nota <input type="text" name="note" maxlength="255"></label>
+<button type="submit">Reportar pago</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// Escribir el estado de cuenta como HTML
+func RenderLoanStatementHTML(w io.Writer, statement *LoanStatement) error {
+	return loanStatementTemplate.Execute(w, statement)
+}
+
+// Escribir el estado de cuenta como PDF (generado localmente)
+func RenderLoanStatementPDF(w io.Writer, statement *LoanStatement) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Estado de cuenta", true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+
+	// Las fuentes base usan cp1252: traducir tildes y ñ
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	money := func(amount float64) string { return statementMoney(statement.Currency, amount) }
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr("Estado de cuenta"), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(120, 120, 120)
+	pdf.CellFormat(0, 5, tr(statementLabel(statement.Type)+" · Generado el "+statementDate(statement.GeneratedAt)), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(3)
+
+	// Condiciones del préstamo
+	terms := [][2]string{
+		{"Concepto", statement.Description},
+		{"Presta", statement.Lender()},
+		{"Debe", statement.Borrower()},
+		{"Monto", money(statement.Amount)},
+		{"Fecha del préstamo", statementDate(statement.LoanDate)},
+	}
+	if statement.DueDate != nil {
+		terms = append(terms, [2]string{"Vencimiento", statementDate(*statement.DueDate)})
+	}
+	terms = append(terms, [2]string{"Interés", statement.InterestTerms()})
+	if statement.InstallmentCount > 0 {
+		terms = append(terms, [2]string{"Cuotas", fmt.Sprintf("%d (%s)", statement.InstallmentCount, statementLabel(statement.InstallmentFrequency))})
+	}
+	terms = append(terms, [2]string{"Estado", statementLabel(statement.Status)})
+	if statement.AccountName != "" {
+		terms = append(terms, [2]string{"Cuenta", statement.AccountName})
+	}
+	if statement.Notes != "" {
+		terms = append(terms, [2]string{"Notas", statement.Notes})
+	}
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, term := range terms {
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(50, 6, tr(term[0]), "", 0, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.MultiCell(0, 6, tr(term[1]), "", "L", false)
+	}
+	pdf.Ln(4)
+
+	// Movimientos
+	type column struct {
+		title string
+		width float64
+		align string
+	}
+	columns := []column{{"Fecha", 22, "L"}, {"Movimiento", 0, "L"}}
+	if statement.AccountName != "" {
+		columns = append(columns, column{"Cuenta", 30, "L"})
+	}
+	columns = append(columns, column{"Monto", 26, "R"})
+	if statement.HasInterest() {
+		columns = append(columns, column{"Interés", 24, "R"}, column{"Capital", 24, "R"})
+	}
+	columns = append(columns, column{"Saldo capital", 28, "R"})
+
+	// La columna de movimiento ocupa el ancho restante
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	fixed := 0.0
+	for _, col := range columns {
+		fixed += col.width
+	}
+	columns[1].width = pageWidth - left - right - fixed
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(240, 240, 240)
+	for _, col := range columns {
+		pdf.CellFormat(col.width, 7, tr(col.title), "B", 0, col.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, entry := range statement.Entries {
+		movement := statementLabel(entry.Kind)
+		if entry.Description != "" {
+			movement += " · " + entry.Description
+		}
+
+		values := []string{statementDate(entry.Date), movement}
+		if statement.AccountName != "" {
+			values = append(values, entry.AccountName)
+		}
+		values = append(values, money(entry.Amount))
+		if statement.HasInterest() {
+			values = append(values, money(entry.Interest), money(entry.Principal))
+		}
+		values = append(values, money(entry.Balance))
+
+		for i, col := range columns {
+			pdf.CellFormat(col.width, 6, tr(truncateForWidth(pdf, tr, values[i], col.width-2)), "B", 0, col.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	// Cronograma de cuotas
+	if len(statement.Installments) > 0 {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 9)
+		widths := []float64{16, 30, 34, 34, 0}
+		widths[4] = pageWidth - left - right - 114
+		for i, title := range []string{"Cuota", "Vence", "Monto", "Pagado", "Estado"} {
+			align := "L"
+			if i == 2 || i == 3 {
+				align = "R"
+			}
+			pdf.CellFormat(widths[i], 7, tr(title), "B", 0, align, true, 0, "")
+		}
+		pdf.Ln(-1)
+
+		pdf.SetFont("Helvetica", "", 9)
+		for _, installment := range statement.Installments {
+			pdf.CellFormat(widths[0], 6, fmt.Sprintf("%d", installment.Number), "B", 0, "L", false, 0, "")
+			pdf.CellFormat(widths[1], 6, statementDate(installment.DueDate), "B", 0, "L", false, 0, "")
+			pdf.CellFormat(widths[2], 6, money(installment.Amount), "B", 0, "R", false, 0, "")
+			pdf.CellFormat(widths[3], 6, money(installment.PaidAmount), "B", 0, "R", false, 0, "")
+			pdf.CellFormat(widths[4], 6, tr(statementLabel(installment.Status)), "B", 0, "L", false, 0, "")
+			pdf.Ln(-1)
+		}
+	}
+
+	// Totales
+	pdf.Ln(6)
+	snapshot := statement.Snapshot
+	totals := [][2]string{{"Capital pendiente", money(snapshot.PrincipalOutstanding)}}
+	if statement.HasInterest() {
+		totals = append(totals, [2]string{"Interés devengado", money(snapshot.InterestAccrued)})
+	}
+	if snapshot.Forgiven > 0 {
+		totals = append(totals, [2]string{"Condonado", money(snapshot.Forgiven)})
+	}
+	pdf.SetFont("Helvetica", "", 10)
+	for _, total := range totals {
+		pdf.CellFormat(60, 6, tr(total[0]), "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, total[1], "", 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(60, 8, tr("Saldo pendiente al "+statementDate(snapshot.AsOf)), "", 0, "L", false, 0, "")
+	pdf.CellFormat(40, 8, money(snapshot.PayoffAmount), "", 1, "R", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+// Recortar un texto para que entre en una celda (se mide ya traducido)
+func truncateForWidth(pdf *gofpdf.Fpdf, tr func(string) string, text string, width float64) string {
+	if pdf.GetStringWidth(tr(text)) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(tr(string(runes)+"...")) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"log"
+	"sync"
+	"time"
+)
+
+type NotificationService struct{}
+
+// Reintentos de un envío fallido: máximo de intentos y espera antes de cada reintento
+const notificationMaxAttempts = 5
+
+var notificationBackoff = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour}
+
+var (
+	notifiersOnce sync.Once
+	notifiers     []Notifier
+)
+
+// Canales activos (se leen del entorno la primera vez)
+func activeNotifiers() []Notifier {
+	notifiersOnce.Do(func() {
+		notifiers = loadNotifiers()
+		channels := make([]string, 0, len(notifiers))
+		for _, notifier := range notifiers {
+			channels = append(channels, notifier.Channel())
+		}
+		log.Printf("Notification channels: %v", channels)
+	})
+	return notifiers
+}
+
+func notifierFor(channel string) Notifier {
+	for _, notifier := range activeNotifiers() {
+		if notifier.Channel() == channel {
+			return notifier
+		}
+	}
+	return nil
+}
+
+// Registrar un envío del recordatorio por cada canal y destino que el usuario tenga
+// activos. Con notifications_enabled en false solo queda la bandeja; las preferencias
+// del tipo de recordatorio eligen además los canales.
+// Solo in-app se envía en el momento: push, correo y webhook dependen de servicios
+// externos y los envía el job (SendPending), así ningún request espera por ellos
+func (ns *NotificationService) Notify(reminder *models.Reminder, user *models.User) {
+	notification := notificationFromReminder(reminder, user)
+	preference := reminderPreference(reminder)
+
+	for _, notifier := range activeNotifiers() {
+		if !channelAllowed(user, &preference, notifier.Channel()) {
+			continue
+		}
+
+		now := time.Now()
+		for _, target := range notifier.Targets(user) {
+			delivery := models.NotificationDelivery{
+				UserID:          user.ID,
+				ReminderID:      reminder.ID,
+				Channel:         notifier.Channel(),
+				DeviceSessionID: target.DeviceSessionID,
+				Target:          target.Address,
+				Status:          models.DeliveryPending,
+				NextAttemptAt:   &now,
+			}
+			if err := config.DB.Create(&delivery).Error; err != nil {
+				log.Printf("Could not record %s delivery for reminder %d: %v", delivery.Channel, reminder.ID, err)
+				continue
+			}
+			if notifier.Channel() == models.ChannelInApp {
+				ns.attempt(notifier, &delivery, target, notification)
+			}
+		}
+	}
+}
+
+// Enviar los envíos encolados y reintentar los fallidos cuya espera ya terminó (job)
+// Los fallos de un envío quedan en su fila; solo un error de la consulta falla el job
+func (ns *NotificationService) SendPending() error {
+	var deliveries []models.NotificationDelivery
+	if err := config.DB.Where("status IN ? AND next_attempt_at <= ?", []string{models.DeliveryPending, models.DeliveryRetrying}, time.Now()).
+		Order("next_attempt_at asc").Find(&deliveries).Error; err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		notifier := notifierFor(delivery.Channel)
+		if notifier == nil {
+			ns.fail(delivery, "channel no longer configured")
+			continue
+		}
+
+		var reminder models.Reminder
+		if err := config.DB.First(&reminder, delivery.ReminderID).Error; err != nil || !reminder.IsActive {
+			ns.fail(delivery, "reminder no longer active")
+			continue
+		}
+
+		var user models.User
+		if err := config.DB.First(&user, delivery.UserID).Error; err != nil {
+			ns.fail(delivery, "user not found")
+			continue
+		}
+
+		// Respetar las horas de silencio también en los envíos encolados
+		if until, quiet := user.QuietUntil(time.Now()); quiet {
+			next := until.UTC()
+			delivery.NextAttemptAt = &next
+			config.DB.Model(delivery).Update("next_attempt_at", next)
+			continue
+		}
+
+		// El destino puede haber cambiado (sesión cerrada, preferencia desactivada...)
+		preference := reminderPreference(&reminder)
+		target, ok := matchTarget(notifier.Targets(&user), delivery)
+		if !ok || !channelAllowed(&user, &preference, delivery.Channel) {
+			ns.fail(delivery, "target no longer available")
+			continue
+		}
+
+		ns.attempt(notifier, delivery, target, notificationFromReminder(&reminder, &user))
+	}
+	return nil
+}
+
+// Un intento de envío: queda enviado, programado para reintentar o fallido
+func (ns *NotificationService) attempt(notifier Notifier, delivery *models.NotificationDelivery, target NotificationTarget, notification Notification) {
+	err := notifier.Send(target, notification)
+	delivery.Attempts++
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.DeliverySent
+		delivery.SentAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+		config.DB.Save(delivery)
+		return
+	}
+
+	delivery.LastError = truncateError(err.Error())
+	if isPermanent(err) || delivery.Attempts >= notificationMaxAttempts {
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		log.Printf("Notification %s to user %d failed: %v", delivery.Channel, delivery.UserID, err)
+	} else {
+		next := time.Now().Add(notificationBackoff[delivery.Attempts-1])
+		delivery.Status = models.DeliveryRetrying
+		delivery.NextAttemptAt = &next
+		log.Printf("Notification %s to user %d will retry at %s: %v", delivery.Channel, delivery.UserID, next.Format(time.RFC3339), err)
+	}
+	config.DB.Save(delivery)
+}
+
+func (ns *NotificationService) fail(delivery *models.NotificationDelivery, reason string) {
+	delivery.Status = models.DeliveryFailed
+	delivery.NextAttemptAt = nil
+	delivery.LastError = reason
+	config.DB.Save(delivery)
+}
+
+// Si el canal está permitido por el interruptor general y por las preferencias del tipo
+func channelAllowed(user *models.User, preference *models.NotificationPreference, channel string) bool {
+	if !user.NotificationsEnabled && channel != models.ChannelInApp {
+		return false
+	}
+	return preference.AllowsChannel(channel)
+}
+
+// Buscar entre los destinos actuales el del envío (por dispositivo o dirección)
+func matchTarget(targets []NotificationTarget, delivery *models.NotificationDelivery) (NotificationTarget, bool) {
+	for _, target := range targets {
+		if delivery.DeviceSessionID != nil {
+			if target.DeviceSessionID != nil && *target.DeviceSessionID == *delivery.DeviceSessionID {
+				return target, true
+			}
+			continue
+		}
+		if target.Address == delivery.Target {
+			return target, true
+		}
+	}
+	return NotificationTarget{}, false
+}
+
+// El título y la descripción ya están en el idioma del usuario; el correo se arma con
+// su plantilla
+func notificationFromReminder(reminder *models.Reminder, user *models.User) Notification {
+	text := user.Localizer()
+	email := map[string]interface{}{
+		"Name":  user.Name,
+		"Title": reminder.Title,
+		"Body":  reminder.Description,
+		"Date":  text.Time(reminder.RemindAt),
+	}
+
+	return Notification{
+		ReminderID:    reminder.ID,
+		UserID:        reminder.UserID,
+		Title:         reminder.Title,
+		Body:          reminder.Description,
+		Type:          reminder.Type,
+		Priority:      reminder.Priority,
+		ReferenceType: reminder.ReferenceType,
+		ReferenceID:   reminder.ReferenceID,
+		Locale:        text.Locale(),
+		EmailSubject:  text.T("email.subject", email),
+		EmailBody:     text.T("email.body", email),
+	}
+}
+
+func truncateError(message string) string {
+	if len(message) > 500 {
+		return message[:500]
+	}
+	return message
+}
@@ -0,0 +1,637 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Contenido de una notificación, igual para todos los canales
+type Notification struct {
+	ReminderID    uint   `json:"reminder_id"`
+	UserID        uint   `json:"user_id"`
+	Title         string `json:"title"`
+	Body          string `json:"body"`
+	Type          string `json:"type"`
+	Priority      string `json:"priority"`
+	ReferenceType string `json:"reference_type,omitempty"`
+	ReferenceID   *uint  `json:"reference_id,omitempty"`
+	Locale        string `json:"locale"`
+
+	// Asunto y cuerpo del correo, ya en el idioma del usuario
+	EmailSubject string `json:"-"`
+	EmailBody    string `json:"-"`
+}
+
+// Destino dentro de un canal. Address se guarda en el historial; Secret (token o URL)
+// y SigningKey (firma del webhook) solo se usan para enviar
+type NotificationTarget struct {
+	DeviceSessionID *uint
+	Address         string
+	Secret          string
+	SigningKey      string
+}
+
+// Canal de notificación. Targets decide, según las preferencias del usuario, a dónde
+// enviar; Send devuelve un error permanente (ver permanentError) si no tiene sentido
+// reintentar
+type Notifier interface {
+	Channel() string
+	Targets(user *models.User) []NotificationTarget
+	Send(target NotificationTarget, notification Notification) error
+}
+
+// Error que no se arregla reintentando (token inválido, dirección rechazada...)
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Cliente HTTP para FCM (un destino lento no bloquea el job)
+var notificationHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Canales configurados según el entorno. Webhook e in-app siempre están; push y
+// email solo si tienen configuración
+func loadNotifiers() []Notifier {
+	notifiers := []Notifier{&InAppNotifier{}}
+
+	if fcm := newFCMNotifier(); fcm != nil {
+		notifiers = append(notifiers, fcm)
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		notifiers = append(notifiers, &EmailNotifier{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		})
+	}
+	notifiers = append(notifiers, &WebhookNotifier{})
+
+	return notifiers
+}
+
+// ─── In-app ─────────────────────────────────────────────────────────────────
+
+// La bandeja de la app: el recordatorio enviado ya es la notificación; además se
+// avisa en tiempo real a los dispositivos conectados
+type InAppNotifier struct{}
+
+func (n *InAppNotifier) Channel() string { return models.ChannelInApp }
+
+func (n *InAppNotifier) Targets(user *models.User) []NotificationTarget {
+	if !user.InAppNotifications {
+		return nil
+	}
+	return []NotificationTarget{{Address: "inbox"}}
+}
+
+func (n *InAppNotifier) Send(target NotificationTarget, notification Notification) error {
+	Events.Publish(notification.UserID, models.EventNotification, notification)
+	return nil
+}
+
+// ─── FCM HTTP v1 ────────────────────────────────────────────────────────────
+
+// Push por Firebase Cloud Messaging (API HTTP v1). Endpoint es configurable para
+// usar un servidor falso en desarrollo
+type FCMNotifier struct {
+	Endpoint  string // https://fcm.googleapis.com
+	ProjectID string
+
+	// Autenticación: un access token fijo o una cuenta de servicio
+	StaticToken    string
+	ServiceAccount *fcmServiceAccount
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type fcmServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// Variables: FCM_PROJECT_ID y FCM_ACCESS_TOKEN o FCM_CREDENTIALS_FILE; FCM_ENDPOINT opcional
+func newFCMNotifier() *FCMNotifier {
+	projectID := os.Getenv("FCM_PROJECT_ID")
+	if projectID == "" {
+		return nil
+	}
+
+	notifier := &FCMNotifier{
+		Endpoint:    strings.TrimSuffix(os.Getenv("FCM_ENDPOINT"), "/"),
+		ProjectID:   projectID,
+		StaticToken: os.Getenv("FCM_ACCESS_TOKEN"),
+	}
+	if notifier.Endpoint == "" {
+		notifier.Endpoint = "https://fcm.googleapis.com"
+	}
+
+	if file := os.Getenv("FCM_CREDENTIALS_FILE"); file != "" && notifier.StaticToken == "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil
+		}
+		var account fcmServiceAccount
+		if err := json.Unmarshal(data, &account); err != nil || account.ClientEmail == "" || account.PrivateKey == "" {
+			return nil
+		}
+		if account.TokenURI == "" {
+			account.TokenURI = "https://oauth2.googleapis.com/token"
+		}
+		notifier.ServiceAccount = &account
+	}
+
+	if notifier.StaticToken == "" && notifier.ServiceAccount == nil {
+		return nil
+	}
+	return notifier
+}
+
+func (n *FCMNotifier) Channel() string { return models.ChannelPush }
+
+// Un destino por dispositivo con sesión activa y token FCM válido
+func (n *FCMNotifier) Targets(user *models.User) []NotificationTarget {
+	if !user.PushNotifications {
+		return nil
+	}
+
+	var sessions []models.DeviceSession
+	config.DB.Where("user_id = ? AND is_active = true AND is_fcm_active = true AND fcm_token_encrypted <> ''", user.ID).
+		Find(&sessions)
+
+	targets := make([]NotificationTarget, 0, len(sessions))
+	for i := range sessions {
+		session := &sessions[i]
+		if session.FCMToken == "" {
+			continue
+		}
+		targets = append(targets, NotificationTarget{
+			DeviceSessionID: &session.ID,
+			Address:         fmt.Sprintf("%s (%s)", session.DeviceName, session.DeviceType),
+			Secret:          session.FCMToken,
+		})
+	}
+	return targets
+}
+
+// Canal de Android para los avisos urgentes; la app lo registra con sonido y vibración
+const fcmUrgentChannel = "urgent_reminders"
+
+// Opciones de Android y APNs según la prioridad: high se entrega de inmediato; urgent
+// además suena, va al canal urgente y en iOS atraviesa el modo concentración
+func fcmPlatformOptions(priority string) (jsonObject, jsonObject) {
+	switch priority {
+	case "urgent":
+		return jsonObject{
+			"priority": "high",
+			"notification": jsonObject{
+				"channel_id":              fcmUrgentChannel,
+				"notification_priority":   "PRIORITY_MAX",
+				"default_sound":           true,
+				"default_vibrate_timings": true,
+			},
+		}, jsonObject{
+			"headers": jsonObject{"apns-priority": "10"},
+			"payload": jsonObject{"aps": jsonObject{"sound": "default", "interruption-level": "time-sensitive"}},
+		}
+	case "high":
+		return jsonObject{"priority": "high"},
+			jsonObject{"headers": jsonObject{"apns-priority": "10"}}
+	}
+	return jsonObject{"priority": "normal"},
+		jsonObject{"headers": jsonObject{"apns-priority": "5"}}
+}
+
+func (n *FCMNotifier) Send(target NotificationTarget, notification Notification) error {
+	data := map[string]string{
+		"reminder_id": strconv.FormatUint(uint64(notification.ReminderID), 10),
+		"type":        notification.Type,
+		"priority":    notification.Priority,
+	}
+	if notification.ReferenceID != nil {
+		data["reference_type"] = notification.ReferenceType
+		data["reference_id"] = strconv.FormatUint(uint64(*notification.ReferenceID), 10)
+	}
+
+	android, apns := fcmPlatformOptions(notification.Priority)
+	payload, _ := json.Marshal(jsonObject{
+		"message": jsonObject{
+			"token":        target.Secret,
+			"notification": jsonObject{"title": notification.Title, "body": notification.Body},
+			"data":         data,
+			"android":      android,
+			"apns":         apns,
+		},
+	})
+
+	token, err := n.token()
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", n.Endpoint, url.PathEscape(n.ProjectID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := notificationHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	var fcmError struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &fcmError)
+	code := fcmError.Error.Status
+	for _, detail := range fcmError.Error.Details {
+		if detail.ErrorCode != "" {
+			code = detail.ErrorCode
+		}
+	}
+	err = fmt.Errorf("fcm: %d %s %s", resp.StatusCode, code, fcmError.Error.Message)
+
+	switch {
+	// INVALID_ARGUMENT suele ser un error del payload, no del token: queda como fallo
+	// permanente del envío (default) sin desactivar el dispositivo
+	case code == "UNREGISTERED" || code == "NOT_FOUND" || code == "SENDER_ID_MISMATCH":
+		// El token ya no sirve: no volver a usarlo (la app enviará uno nuevo al iniciar sesión)
+		if target.DeviceSessionID != nil {
+			config.DB.Model(&models.DeviceSession{}).Where("id = ?", *target.DeviceSessionID).
+				UpdateColumn("is_fcm_active", false)
+		}
+		return permanent(err)
+	case resp.StatusCode == http.StatusUnauthorized:
+		n.mu.Lock()
+		n.accessToken = "" // Pedir otro en el próximo intento
+		n.mu.Unlock()
+		return err
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return err
+	default:
+		return permanent(err)
+	}
+}
+
+// Access token para FCM: el fijo o uno de la cuenta de servicio (se renueva antes de vencer)
+func (n *FCMNotifier) token() (string, error) {
+	if n.StaticToken != "" {
+		return n.StaticToken, nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.accessToken != "" && time.Now().Before(n.expiresAt.Add(-time.Minute)) {
+		return n.accessToken, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(n.ServiceAccount.PrivateKey))
+	if err != nil {
+		return "", permanent(fmt.Errorf("fcm: invalid service account key: %w", err))
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   n.ServiceAccount.ClientEmail,
+		"scope": "https://www.googleapis.com/auth/firebase.messaging",
+		"aud":   n.ServiceAccount.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", permanent(err)
+	}
+
+	resp, err := notificationHTTPClient.PostForm(n.ServiceAccount.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode/100 != 2 || json.NewDecoder(resp.Body).Decode(&result) != nil || result.AccessToken == "" {
+		return "", fmt.Errorf("fcm: could not obtain access token (%d)", resp.StatusCode)
+	}
+
+	n.accessToken = result.AccessToken
+	n.expiresAt = now.Add(time.Duration(result.ExpiresIn) * time.Second)
+	return n.accessToken, nil
+}
+
+// ─── Email (SMTP) ───────────────────────────────────────────────────────────
+
+// Correo por SMTP (SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM)
+type EmailNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (n *EmailNotifier) Channel() string { return models.ChannelEmail }
+
+func (n *EmailNotifier) Targets(user *models.User) []NotificationTarget {
+	if !user.EmailNotifications || user.Email == "" {
+		return nil
+	}
+	return []NotificationTarget{{Address: user.Email, Secret: user.Email}}
+}
+
+func (n *EmailNotifier) Send(target NotificationTarget, notification Notification) error {
+	from := n.From
+	if from == "" {
+		from = n.Username
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", target.Secret)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.EmailSubject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(notification.EmailBody, "\n", "\r\n") + "\r\n")
+
+	err := n.sendMail(from, target.Secret, msg.Bytes())
+
+	// Respuestas 5xx del servidor (dirección rechazada, autenticación...) no mejoran reintentando
+	var smtpError *textproto.Error
+	if errors.As(err, &smtpError) && smtpError.Code >= 500 {
+		return permanent(err)
+	}
+	return err
+}
+
+// Tiempo máximo de toda la conversación SMTP: un servidor colgado no bloquea el job
+const smtpTimeout = 30 * time.Second
+
+// Como smtp.SendMail (STARTTLS si el servidor lo ofrece), pero con timeout al conectar
+// y un deadline para toda la conversación
+func (n *EmailNotifier) sendMail(from string, to string, message []byte) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(n.Host, n.Port), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.Host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return err
+		}
+	}
+
+	// SMTP_FROM puede traer nombre ("CuentasClaras <no-reply@...>"); el sobre lleva solo la dirección
+	envelopeFrom := from
+	if address, err := mail.ParseAddress(from); err == nil {
+		envelopeFrom = address.Address
+	}
+	if err := client.Mail(envelopeFrom); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// ─── Webhook ────────────────────────────────────────────────────────────────
+
+// POST JSON a la URL que el usuario configuró en su perfil
+type WebhookNotifier struct{}
+
+func (n *WebhookNotifier) Channel() string { return models.ChannelWebhook }
+
+func (n *WebhookNotifier) Targets(user *models.User) []NotificationTarget {
+	if user.WebhookURL == "" {
+		return nil
+	}
+	// En el historial solo el host: la ruta suele llevar el secreto
+	address := user.WebhookURL
+	if parsed, err := url.Parse(user.WebhookURL); err == nil {
+		address = parsed.Host
+	}
+	return []NotificationTarget{{Address: address, Secret: user.WebhookURL, SigningKey: user.WebhookSecret}}
+}
+
+func (n *WebhookNotifier) Send(target NotificationTarget, notification Notification) error {
+	payload, _ := json.Marshal(jsonObject{
+		"event":        "reminder",
+		"notification": notification,
+		"sent_at":      time.Now().UTC(),
+	})
+
+	req, err := http.NewRequest(http.MethodPost, target.Secret, bytes.NewReader(payload))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "CuentasClaras-Webhook/1.0")
+
+	// Firma para que el receptor verifique el origen: HMAC-SHA256("<timestamp>.<cuerpo>").
+	// Las URLs configuradas antes de existir la firma no tienen secreto
+	if target.SigningKey != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-CuentasClaras-Timestamp", timestamp)
+		req.Header.Set("X-CuentasClaras-Signature", "sha256="+signWebhook(target.SigningKey, timestamp, payload))
+	}
+
+	resp, err := webhookHTTPClient.Do(req)
+	if errors.Is(err, ErrWebhookAddressNotAllowed) {
+		return permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = fmt.Errorf("webhook: %d", resp.StatusCode)
+	// Las redirecciones no se siguen: reintentar no cambia nada
+	if resp.StatusCode/100 == 3 {
+		return permanent(err)
+	}
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanent(err)
+	}
+	return err
+}
+
+func signWebhook(key string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var ErrWebhookAddressNotAllowed = errors.New("webhook address not allowed")
+
+// Cliente para los webhooks. La URL la elige el usuario, así que no puede llegar a la
+// red interna: la IP se revisa al conectar (después de resolver el DNS, así un nombre
+// que apunte a 127.0.0.1 tampoco pasa), no se usa proxy ni se siguen redirecciones
+var webhookHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		Proxy:               nil,
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: webhookDialControl}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Rangos que no son de loopback, privados ni link-local pero tampoco son Internet
+var webhookBlockedNetworks = parseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "64:ff9b::/96")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhookIPAllowed(ip) {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+// Solo direcciones públicas, salvo WEBHOOK_ALLOW_PRIVATE_NETWORKS=true (desarrollo)
+func webhookIPAllowed(ip net.IP) bool {
+	if os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true" {
+		return true
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range webhookBlockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validar la URL de webhook al guardarla: http(s) y un host que resuelva solo a
+// direcciones públicas. Al enviar se vuelve a revisar (el DNS puede cambiar)
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Hostname() == "" {
+		return errors.New("use an http or https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return errors.New("host could not be resolved")
+	}
+	for _, address := range addresses {
+		if !webhookIPAllowed(address.IP) {
+			return errors.New("host must not point to a private, loopback or link-local address")
+		}
+	}
+	return nil
+}
+
+// Mapa para armar los cuerpos JSON
+type jsonObject map[string]interface{}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecurringTransactionService struct{}
+
+// Tope de ocurrencias por plantilla en una corrida (evita bucles con fechas muy antiguas)
+const maxAutoGenerateCatchUp = 400
+
+// Valores opcionales al registrar una ocurrencia
+type ExecuteRecurringTransactionInput struct {
+	AccountID *uint      // Puede ser diferente al original
+	Amount    *float64   // Puede ser diferente al original
+	Date      *time.Time // Fecha real del movimiento
+	Notes     string     // Notas del movimiento específico
+	Partial   bool       // Abono: el periodo sigue pendiente hasta completar el monto
+}
+
+// Qué cambió al editar una plantilla (los campos ya vienen aplicados y validados)
+type UpdateRecurringTransactionInput struct {
+	Rescheduled   bool // Otra regla o fecha de fin
+	AmountChanged bool
+}
+
+// Registrar un pago de la ocurrencia pendiente y, si queda cubierta, avanzar a la
+// siguiente en una sola transacción. Devuelve la transacción de AccountID (en
+// transferencias, la de salida)
+func (rs *RecurringTransactionService) Execute(userID uint, recurringID uint, input ExecuteRecurringTransactionInput) (*models.Transaction, *models.RecurringTransaction, *models.RecurringOccurrence, error) {
+	var recurring models.RecurringTransaction
+	var occurrence *models.RecurringOccurrence
+	var transaction *models.Transaction
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if err := rs.findActive(tx, userID, recurringID, &recurring); err != nil {
+			return err
+		}
+
+		occurrence, err = models.CurrentOccurrence(tx, &recurring)
+		if errors.Is(err, models.ErrOccurrenceClosed) {
+			return ErrOccurrenceAlreadyPosted
+		}
+		if err != nil {
+			return err
+		}
+
+		// Cada pago de un vencimiento se registra una sola vez (a mano o automáticamente);
+		// los abonos posteriores al primero llevan su número
+		occurrenceKey := recurring.OccurrenceKey(occurrence.ScheduledDate)
+		if occurrence.Payments > 0 {
+			occurrenceKey = fmt.Sprintf("%s:%d", occurrenceKey, occurrence.Payments+1)
+		}
+		var posted int64
+		tx.Unscoped().Model(&models.Transaction{}).Where("idempotency_key = ?", occurrenceKey).Count(&posted)
+		if posted > 0 {
+			return ErrOccurrenceAlreadyPosted
+		}
+
+		// Usar valores por defecto si no se proporcionan
+		accountID := recurring.AccountID
+		if input.AccountID != nil {
+			accountID = *input.AccountID
+		}
+
+		// Por defecto se paga lo que falta del periodo
+		amount := occurrence.Remaining()
+		if input.Amount != nil {
+			amount = *input.Amount
+		}
+
+		date := time.Now()
+		if input.Date != nil {
+			date = *input.Date
+		}
+
+		// Verificar que la cuenta pertenece al usuario
+		var account models.Account
+		if err := tx.Where("id = ? AND user_id = ?", accountID, userID).First(&account).Error; err != nil {
+			return ErrAccountNotFound
+		}
+
+		switch {
+		case recurring.LoanID != nil:
+			transaction, err = rs.postLoanInstallment(tx, &recurring, accountID, amount, date, input.Notes, occurrenceKey)
+		case recurring.IsTransfer():
+			transaction, err = rs.postTransfer(tx, &recurring, &account, amount, date, input.Notes, occurrenceKey)
+		default:
+			// Dirección y signo como en CreateTransaction
+			direction := recurring.Direction()
+			transaction = &models.Transaction{
+				UserID:         userID,
+				AccountID:      accountID,
+				Amount:         models.SignedAmount(direction, amount),
+				Direction:      direction,
+				Description:    recurring.Description,
+				Date:           date,
+				Notes:          input.Notes,
+				Type:           recurring.TransactionType(),
+				CategoryID:     recurring.CategoryID,
+				ReferenceID:    &recurring.ID,
+				ReferenceType:  "recurring_transaction",
+				IdempotencyKey: &occurrenceKey,
+			}
+			err = tx.Create(transaction).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// La cuota que cierra el préstamo es la última
+		loanClosed := false
+		if recurring.LoanID != nil {
+			var loan models.Loan
+			loanClosed = tx.First(&loan, *recurring.LoanID).Error == nil && loan.IsClosed()
+		}
+
+		// Registrar el pago en el periodo (el monto real: una cuota no pasa del saldo)
+		occurrence.PaidAmount = models.RoundMoney(occurrence.PaidAmount + math.Abs(transaction.Amount))
+		occurrence.Payments++
+		occurrence.PaidAt = &date
+		occurrence.TransactionID = &transaction.ID
+
+		if input.Partial && occurrence.Remaining() > 0 && !loanClosed {
+			occurrence.Status = models.OccurrencePartial
+			return tx.Save(occurrence).Error
+		}
+
+		occurrence.Status = models.OccurrencePaid
+		if err := tx.Save(occurrence).Error; err != nil {
+			return err
+		}
+		if loanClosed {
+			return rs.deactivate(tx, &recurring)
+		}
+		return rs.advance(tx, &recurring, occurrence)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rs.refreshReminders(&recurring)
+	if recurring.LoanID != nil {
+		reminderService := &ReminderService{}
+		reminderService.CreateRemindersForLoan(&models.Loan{ID: *recurring.LoanID})
+	}
+
+	// Cargar relaciones para la respuesta
+	config.DB.Preload("Account").Preload("Category").First(transaction, transaction.ID)
+
+	return transaction, &recurring, occurrence, nil
+}
+
+// Omitir la ocurrencia pendiente (o lo que falta de un abono) sin registrar movimiento
+func (rs *RecurringTransactionService) Skip(userID uint, recurringID uint, note string) (*models.RecurringTransaction, *models.RecurringOccurrence, error) {
+	var recurring models.RecurringTransaction
+	var occurrence *models.RecurringOccurrence
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if err := rs.findActive(tx, userID, recurringID, &recurring); err != nil {
+			return err
+		}
+
+		occurrence, err = models.CurrentOccurrence(tx, &recurring)
+		if errors.Is(err, models.ErrOccurrenceClosed) {
+			return ErrOccurrenceAlreadyPosted
+		}
+		if err != nil {
+			return err
+		}
+
+		occurrence.Status = models.OccurrenceSkipped
+		if note != "" {
+			occurrence.Note = note
+		}
+		if err := tx.Save(occurrence).Error; err != nil {
+			return err
+		}
+		return rs.advance(tx, &recurring, occurrence)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rs.refreshReminders(&recurring)
+	return &recurring, occurrence, nil
+}
+
+// Posponer la ocurrencia pendiente hasta date, sin pasar del siguiente vencimiento
+// ni de EndDate. Los siguientes periodos conservan sus fechas
+func (rs *RecurringTransactionService) Postpone(userID uint, recurringID uint, date time.Time, note string) (*models.RecurringTransaction, *models.RecurringOccurrence, error) {
+	var recurring models.RecurringTransaction
+	var occurrence *models.RecurringOccurrence
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if err := rs.findActive(tx, userID, recurringID, &recurring); err != nil {
+			return err
+		}
+
+		occurrence, err = models.CurrentOccurrence(tx, &recurring)
+		if errors.Is(err, models.ErrOccurrenceClosed) {
+			return ErrOccurrenceAlreadyPosted
+		}
+		if err != nil {
+			return err
+		}
+
+		if !date.After(occurrence.DueDate) || (recurring.EndDate != nil && date.After(*recurring.EndDate)) {
+			return ErrInvalidPostponeDate
+		}
+		if next, ok := recurring.OccurrenceAfter(occurrence.ScheduledDate); ok && !date.Before(next) {
+			return ErrInvalidPostponeDate
+		}
+
+		// Un abono sigue como tal; solo cambia su fecha
+		occurrence.DueDate = date
+		if occurrence.Status == models.OccurrenceDue {
+			occurrence.Status = models.OccurrencePostponed
+		}
+		if note != "" {
+			occurrence.Note = note
+		}
+		if err := tx.Save(occurrence).Error; err != nil {
+			return err
+		}
+
+		recurring.NextDueDate = date
+		return tx.Model(&recurring).Update("next_due_date", recurring.NextDueDate).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rs.refreshReminders(&recurring)
+	return &recurring, occurrence, nil
+}
+
+// Guardar los cambios de una plantilla y ajustar su periodo pendiente en una sola
+// transacción: sin pagos toma el monto nuevo y, con otra regla, se vuelve a crear.
+// Después se rehacen sus recordatorios (o se cancelan si quedó inactiva)
+func (rs *RecurringTransactionService) Update(recurring *models.RecurringTransaction, input UpdateRecurringTransactionInput) error {
+	// Con otra regla o fin, el vencimiento pendiente pasa a la primera ocurrencia válida
+	if input.Rescheduled {
+		nextDueDate, ok := recurring.OccurrenceFrom(recurring.NextDueDate)
+		if !ok && recurring.IsActive {
+			return ErrRecurrenceExhausted
+		}
+		if ok {
+			recurring.NextDueDate = nextDueDate
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Account", "ToAccount", "Category", "Loan").Save(recurring).Error; err != nil {
+			return err
+		}
+
+		openOccurrences := tx.Where("recurring_transaction_id = ? AND status IN ? AND payments = 0", recurring.ID, models.OpenOccurrenceStatuses)
+		if input.Rescheduled {
+			return openOccurrences.Delete(&models.RecurringOccurrence{}).Error
+		}
+		if input.AmountChanged {
+			return openOccurrences.Model(&models.RecurringOccurrence{}).Update("amount", recurring.Amount).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// El vencimiento, el monto o el estado pudieron cambiar
+	rs.refreshReminders(recurring)
+	return nil
+}
+
+// Cargar una plantilla del usuario que aún tenga vencimientos
+func (rs *RecurringTransactionService) findActive(tx *gorm.DB, userID uint, recurringID uint, recurring *models.RecurringTransaction) error {
+	if err := tx.Where("id = ? AND user_id = ?", recurringID, userID).First(recurring).Error; err != nil {
+		return ErrRecurringTransactionNotFound
+	}
+	if !recurring.IsActive {
+		return ErrRecurringTransactionInactive
+	}
+	if recurring.HasEnded() {
+		return ErrRecurringTransactionEnded
+	}
+	return nil
+}
+
+// Pasar al vencimiento que sigue a la fecha programada de occurrence; si la regla
+// se agotó (COUNT, UNTIL o EndDate), se desactiva
+func (rs *RecurringTransactionService) advance(tx *gorm.DB, recurring *models.RecurringTransaction, occurrence *models.RecurringOccurrence) error {
+	next, ok := recurring.OccurrenceAfter(occurrence.ScheduledDate)
+	if !ok {
+		return rs.deactivate(tx, recurring)
+	}
+	recurring.NextDueDate = next
+	return tx.Model(recurring).Update("next_due_date", recurring.NextDueDate).Error
+}
+
+// Reabrir la ocurrencia pagada por una transacción que se anula y, si el pago la
+// había cerrado, volver la plantilla a ese vencimiento. Devuelve la plantilla (nil
+// si la transacción no venía de una)
+func (rs *RecurringTransactionService) reopenOccurrence(tx *gorm.DB, transaction *models.Transaction, amount float64) (*models.RecurringTransaction, error) {
+	occurrence, err := models.OccurrenceForTransaction(tx, transaction)
+	if err != nil || occurrence == nil {
+		return nil, err
+	}
+
+	wasPaid := occurrence.Status == models.OccurrencePaid
+	if err := occurrence.Reopen(tx, amount, transaction.ID); err != nil {
+		return nil, err
+	}
+
+	var recurring models.RecurringTransaction
+	if err := tx.First(&recurring, occurrence.RecurringTransactionID).Error; err != nil {
+		return nil, err
+	}
+	if !wasPaid || (recurring.IsActive && !recurring.NextDueDate.After(occurrence.ScheduledDate)) {
+		return &recurring, nil
+	}
+
+	// El pago había avanzado la plantilla (o la desactivó al saldar el préstamo)
+	recurring.NextDueDate = occurrence.ScheduledDate
+	recurring.IsActive = true
+	if err := tx.Model(&recurring).Updates(map[string]interface{}{
+		"next_due_date": recurring.NextDueDate,
+		"is_active":     true,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &recurring, nil
+}
+
+func (rs *RecurringTransactionService) deactivate(tx *gorm.DB, recurring *models.RecurringTransaction) error {
+	recurring.IsActive = false
+	return tx.Model(recurring).Update("is_active", false).Error
+}
+
+// Recordatorios del próximo vencimiento (fuera de la transacción)
+func (rs *RecurringTransactionService) refreshReminders(recurring *models.RecurringTransaction) {
+	reminderService := &ReminderService{}
+	if recurring.IsActive {
+		reminderService.CreateRemindersForRecurringTransaction(recurring)
+	} else {
+		reminderService.CancelRemindersForRecurringTransaction(recurring.ID)
+	}
+}
+
+// Transferencia: sale de la cuenta origen y entra a la de destino (misma moneda)
+func (rs *RecurringTransactionService) postTransfer(tx *gorm.DB, recurring *models.RecurringTransaction, from *models.Account, amount float64, date time.Time, notes string, occurrenceKey string) (*models.Transaction, error) {
+	var to models.Account
+	if recurring.ToAccountID == nil || tx.Where("id = ? AND user_id = ?", *recurring.ToAccountID, recurring.UserID).First(&to).Error != nil {
+		return nil, ErrAccountNotFound
+	}
+	if to.ID == from.ID || to.Currency != from.Currency {
+		return nil, ErrInvalidTransfer
+	}
+
+	outgoing := models.Transaction{
+		UserID:         recurring.UserID,
+		AccountID:      from.ID,
+		Amount:         models.SignedAmount("out", amount),
+		Direction:      "out",
+		Description:    recurring.Description,
+		Date:           date,
+		Notes:          notes,
+		Type:           "transfer_out",
+		ReferenceID:    &recurring.ID,
+		ReferenceType:  "recurring_transaction",
+		IdempotencyKey: &occurrenceKey,
+	}
+	if err := tx.Create(&outgoing).Error; err != nil {
+		return nil, err
+	}
+
+	incomingKey := occurrenceKey + ":in"
+	incoming := models.Transaction{
+		UserID:         recurring.UserID,
+		AccountID:      to.ID,
+		Amount:         models.SignedAmount("in", amount),
+		Direction:      "in",
+		Description:    recurring.Description,
+		Date:           date,
+		Notes:          notes,
+		Type:           "transfer_in",
+		ReferenceID:    &recurring.ID,
+		ReferenceType:  "recurring_transaction",
+		IdempotencyKey: &incomingKey,
+	}
+	if err := tx.Create(&incoming).Error; err != nil {
+		return nil, err
+	}
+
+	return &outgoing, nil
+}
+
+// Cuota de préstamo: un pago confirmado (sin pasar del saldo) con su transacción
+func (rs *RecurringTransactionService) postLoanInstallment(tx *gorm.DB, recurring *models.RecurringTransaction, accountID uint, amount float64, date time.Time, notes string, occurrenceKey string) (*models.Transaction, error) {
+	var loan models.Loan
+	if err := tx.Where("id = ? AND user_id = ?", *recurring.LoanID, recurring.UserID).First(&loan).Error; err != nil {
+		return nil, ErrLoanNotFound
+	}
+	if loan.IsClosed() {
+		return nil, ErrLoanClosed
+	}
+
+	balance := loan.GetBalance(tx)
+	if amount > balance {
+		amount = balance
+	}
+
+	payment := models.LoanPayment{
+		LoanID:      loan.ID,
+		UserID:      recurring.UserID,
+		AccountID:   accountID,
+		Amount:      amount,
+		Date:        date,
+		Description: recurring.Description,
+		Notes:       notes,
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		return nil, err
+	}
+
+	// Recargar con los campos desencriptados
+	if err := tx.First(&payment, payment.ID).Error; err != nil {
+		return nil, err
+	}
+
+	// En un préstamo vinculado, la contraparte recibe el pago como pendiente
+	if loan.IsLinked() {
+		var counterpart models.Loan
+		if err := tx.First(&counterpart, *loan.LinkedLoanID).Error; err != nil {
+			return nil, err
+		}
+		if err := models.MirrorLoanPayment(tx, &payment, &counterpart); err != nil {
+			return nil, err
+		}
+	}
+
+	loanService := &LoanService{}
+	return loanService.postLoanPayment(tx, &payment, &loan, &occurrenceKey)
+}
+
+// Registrar las ocurrencias vencidas de las plantillas con AutoGenerate, poniéndose
+// al día si el job no corrió (caída del servidor) y sin pasar de EndDate. Si alguna
+// falla devuelve error para que el scheduler reintente
+func (rs *RecurringTransactionService) ProcessAutoGenerate(now time.Time) error {
+	// Candidatas con un día de margen: el vencimiento llega según la zona de cada usuario
+	var templates []models.RecurringTransaction
+	if err := config.DB.Where("auto_generate = ? AND is_active = ? AND next_due_date <= ?", true, true, now.AddDate(0, 0, 1)).
+		Find(&templates).Error; err != nil {
+		return err
+	}
+
+	reminderService := &ReminderService{}
+	users := map[uint]*models.User{}
+	failed := 0
+	for _, recurring := range templates {
+		loc := reminderService.cachedUser(users, recurring.UserID).Location()
+		for i := 0; i < maxAutoGenerateCatchUp; i++ {
+			if !recurring.IsActive || recurring.HasEnded() || !recurring.IsDueBy(now, loc) {
+				break
+			}
+
+			// La transacción lleva la fecha del vencimiento, no la de hoy
+			dueDate := recurring.NextDueDate
+			transaction, updated, _, err := rs.Execute(recurring.UserID, recurring.ID, ExecuteRecurringTransactionInput{
+				Date:  &dueDate,
+				Notes: "Registrado automáticamente",
+			})
+
+			if errors.Is(err, ErrOccurrenceAlreadyPosted) {
+				// Ya se registró (p. ej. se retrocedió la fecha a mano): solo avanzar
+				next, ok := recurring.NextOccurrence()
+				if !ok {
+					config.DB.Model(&recurring).Update("is_active", false)
+					break
+				}
+				recurring.NextDueDate = next
+				config.DB.Model(&recurring).Update("next_due_date", recurring.NextDueDate)
+				continue
+			}
+			if errors.Is(err, ErrLoanClosed) || errors.Is(err, ErrLoanNotFound) {
+				// Préstamo pagado o eliminado: no quedan cuotas por registrar
+				config.DB.Model(&recurring).Update("is_active", false)
+				reminderService.CancelRemindersForRecurringTransaction(recurring.ID)
+				break
+			}
+			if err != nil {
+				log.Printf("Auto-generate failed for recurring transaction %d: %v", recurring.ID, err)
+				failed++
+				break
+			}
+
+			reminderService.NotifyRecurringTransactionPosted(updated, transaction)
+			recurring = *updated
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("auto-generate failed for %d of %d recurring transactions", failed, len(templates))
+	}
+	return nil
+}
@@ -2,156 +2,603 @@ package services
 
 import (
 	"cuentas-claras/config"
+	"cuentas-claras/i18n"
 	"cuentas-claras/models"
-	"fmt"
+	"encoding/json"
 	"log"
+	"math"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type ReminderService struct{}
 
-// Crear recordatorios para un gasto recurrente
-func (rs *ReminderService) CreateRemindersForRecurringExpense(recurringExpense *models.RecurringExpense) error {
+// Cada cuántos días se repite el aviso de vencido si el usuario no lo configuró
+const (
+	recurringOverdueNudgeDays = 1
+	loanOverdueNudgeDays      = 3
+)
+
+// Hora local (zona del usuario) a la que se programan los recordatorios de vencimiento.
+// Los días de anticipación son preferencia del usuario (recurring_due y loan_due)
+const reminderHour = 9
+
+// Crear recordatorios para una transacción recurrente, uno por cada día de aviso
+// que el usuario eligió (por defecto 2 días antes, 1 día antes y el mismo día)
+func (rs *ReminderService) CreateRemindersForRecurringTransaction(recurring *models.RecurringTransaction) error {
 	// Eliminar recordatorios anteriores pendientes
+	rs.CancelRemindersForRecurringTransaction(recurring.ID)
+
+	preference := models.LoadNotificationPreference(config.DB, recurring.UserID, models.NotifyRecurringDue)
+	if !preference.Enabled {
+		return nil
+	}
+
+	user := rs.loadUser(recurring.UserID)
+	loc := user.Location()
+	text := user.Localizer()
+	currency := accountCurrency(recurring.AccountID)
+
+	// Solo crear recordatorios si la fecha es futura
+	now := time.Now()
+	for _, days := range preference.LeadDayList() {
+		reminder := models.Reminder{
+			UserID:        recurring.UserID,
+			Template:      models.TemplateRecurringUpcoming,
+			Type:          "recurring_transaction",
+			ReferenceID:   &recurring.ID,
+			ReferenceType: "recurring_transaction",
+			RemindAt:      models.LocalDateAt(recurring.NextDueDate.AddDate(0, 0, -days), loc, reminderHour),
+			Priority:      leadReminderPriority(days),
+		}
+		if !reminder.RemindAt.After(now) {
+			continue
+		}
+
+		switch days {
+		case 0:
+			reminder.Template = models.TemplateRecurringDueToday
+		case 1:
+			reminder.Template = models.TemplateRecurringDueTomorrow
+		}
+		renderReminder(&reminder, text, models.ReminderParams{
+			Description: recurring.Description,
+			Amount:      recurring.Amount,
+			Currency:    currency,
+			Days:        days,
+		})
+		config.DB.Create(&reminder)
+	}
+
+	return nil
+}
+
+// Cancelar recordatorios pendientes de una transacción recurrente (terminada o desactivada)
+func (rs *ReminderService) CancelRemindersForRecurringTransaction(recurringID uint) {
 	config.DB.Where("reference_id = ? AND reference_type = ? AND is_sent = false",
-		recurringExpense.ID, "recurring_expense").Delete(&models.Reminder{})
-
-	// Recordatorio 2 días antes
-	reminder2Days := models.Reminder{
-		UserID:        recurringExpense.UserID,
-		Title:         fmt.Sprintf("Próximo: %s", recurringExpense.Description),
-		Description:   fmt.Sprintf("Vence en 2 días - %.2f %s", recurringExpense.Amount, "PEN"),
-		Type:          "recurring_expense",
-		ReferenceID:   &recurringExpense.ID,
-		ReferenceType: "recurring_expense",
-		RemindAt:      recurringExpense.NextDueDate.AddDate(0, 0, -2),
+		recurringID, "recurring_transaction").Delete(&models.Reminder{})
+}
+
+// Avisar que se registró automáticamente una transacción recurrente
+func (rs *ReminderService) NotifyRecurringTransactionPosted(recurring *models.RecurringTransaction, transaction *models.Transaction) {
+	text := rs.loadUser(recurring.UserID).Localizer()
+
+	// Referencia a la transacción para que no se borre al refrescar los de la plantilla
+	reminder := models.Reminder{
+		UserID:        recurring.UserID,
+		Template:      models.TemplateRecurringPosted,
+		Type:          "recurring_transaction",
+		ReferenceID:   &transaction.ID,
+		ReferenceType: "transaction",
+		RemindAt:      time.Now(),
 		Priority:      "normal",
 	}
+	renderReminder(&reminder, text, models.ReminderParams{
+		Description: recurring.Description,
+		Transfer:    recurring.IsTransfer(),
+		Income:      transaction.Direction == "in",
+		Amount:      math.Abs(transaction.Amount),
+		Currency:    transaction.Account.Currency,
+		Date:        &transaction.Date,
+		Account:     transaction.Account.Name,
+	})
 
-	// Recordatorio 1 día antes
-	reminder1Day := models.Reminder{
-		UserID:        recurringExpense.UserID,
-		Title:         fmt.Sprintf("Mañana vence: %s", recurringExpense.Description),
-		Description:   fmt.Sprintf("Vence mañana - %.2f %s", recurringExpense.Amount, "PEN"),
-		Type:          "recurring_expense",
-		ReferenceID:   &recurringExpense.ID,
-		ReferenceType: "recurring_expense",
-		RemindAt:      recurringExpense.NextDueDate.AddDate(0, 0, -1),
-		Priority:      "high",
+	config.DB.Create(&reminder)
+	rs.deliver(&reminder)
+}
+
+// Crear (o refrescar) recordatorios para el vencimiento de un préstamo, uno por cada
+// día de aviso (por defecto 3 días antes y el mismo día)
+func (rs *ReminderService) CreateRemindersForLoan(loan *models.Loan) error {
+	// Eliminar recordatorios anteriores pendientes
+	rs.CancelRemindersForLoan(loan.ID)
+
+	// Recargar para tener campos desencriptados y status actualizado
+	var current models.Loan
+	if err := config.DB.First(&current, loan.ID).Error; err != nil {
+		return err
 	}
 
-	// Recordatorio el día mismo
-	reminderToday := models.Reminder{
-		UserID:        recurringExpense.UserID,
-		Title:         fmt.Sprintf("¡Vence hoy! %s", recurringExpense.Description),
-		Description:   fmt.Sprintf("Vence hoy - %.2f %s", recurringExpense.Amount, "PEN"),
-		Type:          "recurring_expense",
-		ReferenceID:   &recurringExpense.ID,
-		ReferenceType: "recurring_expense",
-		RemindAt:      recurringExpense.NextDueDate,
-		Priority:      "high",
+	if current.DueDate == nil || current.IsClosed() {
+		return nil
+	}
+
+	preference := models.LoadNotificationPreference(config.DB, current.UserID, models.NotifyLoanDue)
+	if !preference.Enabled {
+		return nil
 	}
 
+	dueDate := *current.DueDate
+	user := rs.loadUser(current.UserID)
+	loc := user.Location()
+	text := user.Localizer()
+	balance := current.GetBalance(config.DB)
+	currency := accountCurrency(current.AccountID)
+
 	// Solo crear recordatorios si la fecha es futura
 	now := time.Now()
-	if reminder2Days.RemindAt.After(now) {
-		config.DB.Create(&reminder2Days)
+	for _, days := range preference.LeadDayList() {
+		reminder := models.Reminder{
+			UserID:        current.UserID,
+			Template:      models.TemplateLoanUpcoming,
+			Type:          "loan",
+			ReferenceID:   &current.ID,
+			ReferenceType: "loan",
+			RemindAt:      models.LocalDateAt(dueDate.AddDate(0, 0, -days), loc, reminderHour),
+			Priority:      leadReminderPriority(days),
+		}
+		if !reminder.RemindAt.After(now) {
+			continue
+		}
+
+		if days == 0 {
+			reminder.Template = models.TemplateLoanDueToday
+		}
+		renderReminder(&reminder, text, models.ReminderParams{
+			Person:   current.PersonName,
+			Given:    current.Type == "given",
+			Amount:   balance,
+			Currency: currency,
+			Days:     days,
+		})
+		config.DB.Create(&reminder)
+	}
+
+	return nil
+}
+
+// Los avisos de la víspera y del mismo día van con prioridad alta
+func leadReminderPriority(days int) string {
+	if days <= 1 {
+		return "high"
+	}
+	return "normal"
+}
+
+// Avisar al dueño que la contraparte reportó un pago desde un enlace compartido
+func (rs *ReminderService) NotifyLoanPaymentReported(loan *models.Loan, payment *models.LoanPayment) error {
+	text := rs.loadUser(loan.UserID).Localizer()
+
+	// Referencia al pago para que no se borre al refrescar los del préstamo
+	reminder := models.Reminder{
+		UserID:        loan.UserID,
+		Template:      models.TemplateLoanPaymentReported,
+		Type:          "loan",
+		ReferenceID:   &payment.ID,
+		ReferenceType: "loan_payment",
+		RemindAt:      time.Now(),
+		Priority:      "high",
+	}
+	renderReminder(&reminder, text, models.ReminderParams{
+		Person:   loan.PersonName,
+		Given:    loan.Type == "given",
+		Amount:   payment.Amount,
+		Currency: accountCurrency(loan.AccountID),
+		Date:     &payment.Date,
+	})
+	return config.DB.Create(&reminder).Error
+}
+
+// Avisar al invitado que otro usuario quiere llevar un préstamo con él
+func (rs *ReminderService) NotifyLoanInvitation(link *models.LoanLink, loan *models.Loan, inviterName string) error {
+	text := rs.loadUser(link.InviteeID).Localizer()
+
+	// Desde el lado del invitado el préstamo es el espejo
+	reminder := models.Reminder{
+		UserID:        link.InviteeID,
+		Template:      models.TemplateLoanInvitation,
+		Type:          "loan",
+		ReferenceID:   &link.ID,
+		ReferenceType: "loan_link",
+		RemindAt:      time.Now(),
+		Priority:      "normal",
+	}
+	renderReminder(&reminder, text, models.ReminderParams{
+		Description: loan.Description,
+		Person:      inviterName,
+		Given:       models.MirrorLoanType(loan.Type) == "given",
+		Amount:      loan.Amount,
+		Currency:    accountCurrency(loan.AccountID),
+	})
+	if err := config.DB.Create(&reminder).Error; err != nil {
+		return err
+	}
+	rs.deliver(&reminder)
+	return nil
+}
+
+// Avisar que un inicio de sesión cerró las sesiones de los demás dispositivos
+func (rs *ReminderService) NotifyNewSession(session *models.DeviceSession) error {
+	preference := models.LoadNotificationPreference(config.DB, session.UserID, models.NotifySecurity)
+	if !preference.Enabled {
+		return nil
 	}
-	if reminder1Day.RemindAt.After(now) {
-		config.DB.Create(&reminder1Day)
+
+	text := rs.loadUser(session.UserID).Localizer()
+	reminder := models.Reminder{
+		UserID:        session.UserID,
+		Template:      models.TemplateSecurityNewSession,
+		Type:          "security",
+		ReferenceID:   &session.ID,
+		ReferenceType: "device_session",
+		RemindAt:      time.Now(),
+		Priority:      "high",
 	}
-	if reminderToday.RemindAt.After(now) {
-		config.DB.Create(&reminderToday)
+	renderReminder(&reminder, text, models.ReminderParams{
+		Description: session.DeviceName,
+		Date:        &session.LoginAt,
+	})
+	if err := config.DB.Create(&reminder).Error; err != nil {
+		return err
 	}
+	rs.deliver(&reminder)
+	return nil
+}
 
+// Guardar un recordatorio personalizado; si ya venció se envía en el momento
+func (rs *ReminderService) CreateCustomReminder(reminder *models.Reminder) error {
+	reminder.Type = "custom"
+	if err := config.DB.Create(reminder).Error; err != nil {
+		return err
+	}
+	if !reminder.RemindAt.After(time.Now()) {
+		rs.deliver(reminder)
+	}
 	return nil
 }
 
-// Procesar recordatorios pendientes (Job diario)
-func (rs *ReminderService) ProcessPendingReminders() {
+// Cancelar recordatorios pendientes de un préstamo (cerrado, eliminado o con nueva fecha)
+func (rs *ReminderService) CancelRemindersForLoan(loanID uint) {
+	config.DB.Where("reference_id = ? AND reference_type = ? AND is_sent = false",
+		loanID, "loan").Delete(&models.Reminder{})
+}
+
+// Volver a programar los recordatorios de vencimiento de un usuario (p. ej. al cambiar
+// de zona o de idioma)
+func (rs *ReminderService) RescheduleRemindersForUser(userID uint) {
+	var templates []models.RecurringTransaction
+	config.DB.Where("user_id = ? AND is_active = true", userID).Find(&templates)
+	for i := range templates {
+		rs.CreateRemindersForRecurringTransaction(&templates[i])
+	}
+
+	var loans []models.Loan
+	config.DB.Where("user_id = ? AND due_date IS NOT NULL AND status NOT IN ?", userID, models.ClosedLoanStatuses).Find(&loans)
+	for i := range loans {
+		rs.CreateRemindersForLoan(&loans[i])
+	}
+}
+
+// Texto según quién debe a quién
+func loanReminderSubject(text *i18n.Localizer, loan *models.Loan) string {
+	return loanSubject(text, loan.Type == "given", loan.PersonName)
+}
+
+func loanSubject(text *i18n.Localizer, given bool, person string) string {
+	return text.T("loan.subject", map[string]interface{}{"Given": given, "Person": person})
+}
+
+// Título y descripción del recordatorio según su plantilla, en el idioma del usuario.
+// Guarda los datos para poder generarlo de nuevo si cambia el idioma
+func renderReminder(reminder *models.Reminder, text *i18n.Localizer, params models.ReminderParams) {
+	if encoded, err := json.Marshal(params); err == nil {
+		reminder.Params = string(encoded)
+	}
+
+	data := map[string]interface{}{
+		"Description": params.Description,
+		"Person":      params.Person,
+		"Subject":     loanSubject(text, params.Given, params.Person),
+		"Account":     params.Account,
+		"Amount":      text.Money(params.Amount, params.Currency),
+		"Days":        params.Days,
+		"Transfer":    params.Transfer,
+		"Income":      params.Income,
+	}
+	if params.Date != nil {
+		data["Date"] = text.Day(*params.Date)
+	}
+
+	reminder.Title = text.T("reminder."+reminder.Template+".title", data)
+	reminder.Description = text.T("reminder."+reminder.Template+".body", data)
+}
+
+// Volver a generar en el idioma actual el texto de todos los recordatorios automáticos
+// del usuario, enviados o no (los creados antes de guardar sus datos quedan igual)
+func (rs *ReminderService) RerenderRemindersForUser(userID uint) error {
+	var reminders []models.Reminder
+	if err := config.DB.Where("user_id = ? AND template <> '' AND params <> ''", userID).Find(&reminders).Error; err != nil {
+		return err
+	}
+
+	text := rs.loadUser(userID).Localizer()
+	for i := range reminders {
+		reminder := &reminders[i]
+		var params models.ReminderParams
+		if err := json.Unmarshal([]byte(reminder.Params), &params); err != nil {
+			log.Printf("Could not read params of reminder %d: %v", reminder.ID, err)
+			continue
+		}
+
+		renderReminder(reminder, text, params)
+		if err := config.DB.Model(reminder).UpdateColumns(map[string]interface{}{
+			"title":       reminder.Title,
+			"description": reminder.Description,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Preferencias del tipo del recordatorio (todo permitido si no tiene tipo)
+func reminderPreference(reminder *models.Reminder) models.NotificationPreference {
+	notificationType := models.NotificationTypeFor(reminder.Template)
+	if notificationType == "" {
+		return models.DefaultNotificationPreference(reminder.UserID, "")
+	}
+	return models.LoadNotificationPreference(config.DB, reminder.UserID, notificationType)
+}
+
+// Moneda de una cuenta (PEN si no se encuentra)
+func accountCurrency(accountID uint) string {
+	var account models.Account
+	config.DB.Select("id", "currency").Where("id = ?", accountID).Limit(1).Find(&account)
+	if account.Currency == "" {
+		log.Printf("Account %d not found for reminder, using PEN", accountID)
+		return "PEN"
+	}
+	return account.Currency
+}
+
+// Enviar los recordatorios que ya vencieron (job del scheduler, cada minuto)
+func (rs *ReminderService) DeliverDueReminders(now time.Time) error {
 	var reminders []models.Reminder
 
 	// Buscar recordatorios que deben enviarse
-	config.DB.Where("remind_at <= ? AND is_sent = false AND is_active = true", time.Now()).
-		Find(&reminders)
+	if err := config.DB.Where("remind_at <= ? AND is_sent = false AND is_active = true", now).
+		Find(&reminders).Error; err != nil {
+		return err
+	}
 
 	for _, reminder := range reminders {
-		// Enviar notificación
-		rs.SendNotification(&reminder)
+		rs.deliver(&reminder)
+	}
+	return nil
+}
 
-		// Marcar como enviado
-		reminder.MarkAsSent(config.DB)
+// Verificar transacciones recurrentes vencidas
+func (rs *ReminderService) CheckOverdueRecurringTransactions() error {
+	var overdue []models.RecurringTransaction
 
-		log.Printf("Reminder sent: %s to user %d", reminder.Title, reminder.UserID)
+	// Candidatas: el día de vencimiento ya pasó en alguna zona; cada una se evalúa
+	// en la zona de su usuario
+	now := time.Now()
+	if err := config.DB.Where("next_due_date < ? AND is_active = true", now.AddDate(0, 0, 1)).
+		Find(&overdue).Error; err != nil {
+		return err
 	}
 
-	// También verificar gastos recurrentes vencidos
-	rs.CheckOverdueRecurringExpenses()
+	users := map[uint]*models.User{}
+	preferences := map[uint]*models.NotificationPreference{}
+	for _, recurring := range overdue {
+		user := rs.cachedUser(users, recurring.UserID)
+		daysOverdue := models.DaysPastDue(recurring.NextDueDate, now, user.Location())
+		if daysOverdue < 1 {
+			continue
+		}
+
+		preference := rs.cachedPreference(preferences, recurring.UserID, models.NotifyOverdue)
+		if !preference.Enabled {
+			continue
+		}
+
+		// Crear recordatorio de vencido si toca repetirlo (los anteriores a las plantillas
+		// se reconocen por el título)
+		alerts := config.DB.Where("reference_id = ? AND reference_type = ?", recurring.ID, "recurring_transaction").
+			Where("template = ? OR title LIKE ?", models.TemplateRecurringOverdue, "¡VENCIDO!%")
+		priority, due := overdueAlertDue(alerts, preference, recurringOverdueNudgeDays, models.LocalDateAt(recurring.NextDueDate, user.Location(), 0), now)
+
+		if due {
+			overdueReminder := models.Reminder{
+				UserID:        recurring.UserID,
+				Template:      models.TemplateRecurringOverdue,
+				Type:          "recurring_transaction",
+				ReferenceID:   &recurring.ID,
+				ReferenceType: "recurring_transaction",
+				RemindAt:      time.Now(),
+				Priority:      priority,
+			}
+			text := user.Localizer()
+			renderReminder(&overdueReminder, text, models.ReminderParams{
+				Description: recurring.Description,
+				Amount:      recurring.Amount,
+				Currency:    accountCurrency(recurring.AccountID),
+				Days:        daysOverdue,
+			})
+
+			if err := config.DB.Create(&overdueReminder).Error; err != nil {
+				return err
+			}
+			rs.deliver(&overdueReminder)
+		}
+	}
+	return nil
 }
 
-// Verificar gastos recurrentes vencidos
-func (rs *ReminderService) CheckOverdueRecurringExpenses() {
-	var overdueExpenses []models.RecurringExpense
+// Verificar préstamos vencidos y no pagados
+func (rs *ReminderService) CheckOverdueLoans() error {
+	var overdueLoans []models.Loan
+
+	// Candidatos: el día de vencimiento ya pasó en alguna zona
+	now := time.Now()
+
+	// Las cuotas vencen sin que nadie escriba (las consultas no recalculan): actualizar
+	// el estado de los préstamos con cuotas impagas cuya fecha ya pasó
+	var installmentLoans []models.Loan
+	dueInstallments := config.DB.Model(&models.LoanInstallment{}).Select("loan_id").
+		Where("status IN ? AND due_date < ?", []string{"pending", "partial_paid"}, now)
+	if err := config.DB.Where("id IN (?) AND status NOT IN ?", dueInstallments, models.ClosedLoanStatuses).
+		Find(&installmentLoans).Error; err != nil {
+		return err
+	}
+	for i := range installmentLoans {
+		if err := installmentLoans[i].UpdateStatus(config.DB); err != nil {
+			return err
+		}
+	}
 
-	// Buscar gastos recurrentes vencidos (más de 1 día de retraso)
-	yesterday := time.Now().AddDate(0, 0, -1)
-	config.DB.Where("next_due_date < ? AND is_active = true", yesterday).
-		Find(&overdueExpenses)
+	if err := config.DB.Where("due_date IS NOT NULL AND due_date < ? AND status NOT IN ?", now.AddDate(0, 0, 1), models.ClosedLoanStatuses).
+		Find(&overdueLoans).Error; err != nil {
+		return err
+	}
 
-	for _, expense := range overdueExpenses {
-		// Crear recordatorio de vencido si no existe uno reciente
-		var existingReminder models.Reminder
-		err := config.DB.Where("reference_id = ? AND reference_type = ? AND title LIKE ?",
-			expense.ID, "recurring_expense", "¡VENCIDO!%").
-			Where("created_at > ?", time.Now().AddDate(0, 0, -1)).
-			First(&existingReminder).Error
+	users := map[uint]*models.User{}
+	preferences := map[uint]*models.NotificationPreference{}
+	for _, loan := range overdueLoans {
+		user := rs.cachedUser(users, loan.UserID)
+		daysOverdue := models.DaysPastDue(*loan.DueDate, now, user.Location())
+		if daysOverdue < 1 {
+			continue
+		}
 
-		if err != nil { // No existe, crear nuevo
+		// El status puede estar desactualizado
+		if err := loan.UpdateStatus(config.DB); err != nil {
+			return err
+		}
+		if loan.IsClosed() {
+			rs.CancelRemindersForLoan(loan.ID)
+			continue
+		}
+
+		preference := rs.cachedPreference(preferences, loan.UserID, models.NotifyOverdue)
+		if !preference.Enabled {
+			continue
+		}
+
+		// Insistir cada loanOverdueNudgeDays días salvo que el usuario elija otra frecuencia
+		alerts := config.DB.Where("reference_id = ? AND reference_type = ?", loan.ID, "loan").
+			Where("template = ? OR title LIKE ?", models.TemplateLoanOverdue, "¡Préstamo vencido!%")
+		priority, due := overdueAlertDue(alerts, preference, loanOverdueNudgeDays, models.LocalDateAt(*loan.DueDate, user.Location(), 0), now)
+
+		if due {
 			overdueReminder := models.Reminder{
-				UserID: expense.UserID,
-				Title:  fmt.Sprintf("¡VENCIDO! %s", expense.Description),
-				Description: fmt.Sprintf("Lleva %d días vencido - %.2f %s",
-					int(time.Since(expense.NextDueDate).Hours()/24), expense.Amount, "PEN"),
-				Type:          "recurring_expense",
-				ReferenceID:   &expense.ID,
-				ReferenceType: "recurring_expense",
+				UserID:        loan.UserID,
+				Template:      models.TemplateLoanOverdue,
+				Type:          "loan",
+				ReferenceID:   &loan.ID,
+				ReferenceType: "loan",
 				RemindAt:      time.Now(),
-				Priority:      "high",
+				Priority:      priority,
 			}
+			text := user.Localizer()
+			renderReminder(&overdueReminder, text, models.ReminderParams{
+				Person:   loan.PersonName,
+				Given:    loan.Type == "given",
+				Amount:   loan.GetBalance(config.DB),
+				Currency: accountCurrency(loan.AccountID),
+				Days:     daysOverdue,
+			})
 
-			config.DB.Create(&overdueReminder)
-			rs.SendNotification(&overdueReminder)
-			overdueReminder.MarkAsSent(config.DB)
+			if err := config.DB.Create(&overdueReminder).Error; err != nil {
+				return err
+			}
+			rs.deliver(&overdueReminder)
+		}
+	}
+	return nil
+}
 
-			log.Printf("Overdue reminder sent: %s to user %d", overdueReminder.Title, overdueReminder.UserID)
+// Si toca un nuevo aviso de vencido y con qué prioridad. alerts filtra los avisos del
+// mismo objeto; se cuentan los enviados desde el vencimiento: se repite cada
+// RepeatDays días y la prioridad sube según el escalamiento del usuario
+func overdueAlertDue(alerts *gorm.DB, preference *models.NotificationPreference, defaultRepeatDays int, dueSince time.Time, now time.Time) (string, bool) {
+	var previous []models.Reminder
+	alerts.Where("created_at >= ?", dueSince.UTC()).Order("created_at desc").Find(&previous)
+
+	if len(previous) > 0 {
+		repeatDays := preference.RepeatDays(defaultRepeatDays)
+		if repeatDays == 0 || previous[0].CreatedAt.After(now.AddDate(0, 0, -repeatDays)) {
+			return "", false
 		}
 	}
+	return preference.PriorityFor(len(previous)), true
 }
 
-// Enviar notificación (placeholder por ahora)
-func (rs *ReminderService) SendNotification(reminder *models.Reminder) {
-	// Por ahora solo log, después implementaremos FCM/WebSocket
-	log.Printf("NOTIFICATION: %s - %s (User: %d)", reminder.Title, reminder.Description, reminder.UserID)
+// Enviar un recordatorio ya guardado; en las horas de silencio del usuario queda
+// pendiente hasta que terminen y lo envía el job
+func (rs *ReminderService) deliver(reminder *models.Reminder) {
+	user := rs.loadUser(reminder.UserID)
+	if until, quiet := user.QuietUntil(time.Now()); quiet {
+		reminder.RemindAt = until.UTC()
+		config.DB.Model(reminder).Update("remind_at", reminder.RemindAt)
+		log.Printf("Reminder deferred until %s (quiet hours): %s to user %d", until.Format(time.RFC3339), reminder.Title, reminder.UserID)
+		return
+	}
 
-	// TODO: Implementar FCM push notification
-	// TODO: Implementar WebSocket notification
-	// TODO: Implementar in-app notification
+	rs.SendNotification(reminder, user)
+
+	// Sin notificaciones in-app (en general o para su tipo) queda en la bandeja, pero ya
+	// leído (no suma al badge)
+	preference := reminderPreference(reminder)
+	if !user.InAppNotifications || !preference.AllowsChannel(models.ChannelInApp) {
+		now := time.Now()
+		reminder.ReadAt = &now
+	}
+	reminder.MarkAsSent(config.DB)
+	log.Printf("Reminder sent: %s to user %d", reminder.Title, reminder.UserID)
 }
 
-// Iniciar el job scheduler (corre una vez al día)
-func (rs *ReminderService) StartDailyJob() {
-	go func() {
-		// Ejecutar inmediatamente al iniciar
-		rs.ProcessPendingReminders()
+// Usuario con su zona y horas de silencio (valores por defecto si no existe)
+func (rs *ReminderService) loadUser(userID uint) *models.User {
+	var user models.User
+	config.DB.First(&user, userID)
+	return &user
+}
 
-		// Luego cada 24 horas
-		ticker := time.NewTicker(24 * time.Hour)
-		defer ticker.Stop()
+// Preferencias de un tipo, consultando las de cada usuario una sola vez por corrida
+func (rs *ReminderService) cachedPreference(cache map[uint]*models.NotificationPreference, userID uint, notificationType string) *models.NotificationPreference {
+	if preference, ok := cache[userID]; ok {
+		return preference
+	}
+	preference := models.LoadNotificationPreference(config.DB, userID, notificationType)
+	cache[userID] = &preference
+	return &preference
+}
 
-		for range ticker.C {
-			rs.ProcessPendingReminders()
-		}
-	}()
+// Usuario (zona, idioma) consultando cada uno una sola vez por corrida
+func (rs *ReminderService) cachedUser(cache map[uint]*models.User, userID uint) *models.User {
+	if user, ok := cache[userID]; ok {
+		return user
+	}
+	user := rs.loadUser(userID)
+	cache[userID] = user
+	return user
+}
 
-	log.Println("Daily reminder job started")
+// Enviar la notificación por los canales que el usuario tenga activos
+func (rs *ReminderService) SendNotification(reminder *models.Reminder, user *models.User) {
+	notificationService := &NotificationService{}
+	notificationService.Notify(reminder, user)
 }
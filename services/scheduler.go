@@ -0,0 +1,255 @@
+package services
+
+import (
+	"crypto/rand"
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job registrado: nombre único, cron y la función a ejecutar
+type Job struct {
+	Name     string
+	Schedule string
+	Run      func(now time.Time) error
+}
+
+// Scheduler respaldado por la base de datos (tablas scheduled_jobs y job_runs)
+type Scheduler struct {
+	instanceID string
+	jobs       []*scheduledJob
+}
+
+type scheduledJob struct {
+	Job
+	cron *CronSchedule
+}
+
+// Parámetros del scheduler
+const (
+	schedulerPollInterval = 15 * time.Second // Resolución: menos de un minuto de retraso
+	jobLease              = 5 * time.Minute  // Si la instancia muere, otra lo toma al vencer
+	jobMaxRetries         = 3                // Reintentos antes de esperar al siguiente horario
+	jobRetryBase          = time.Minute      // 1, 2, 4 minutos...
+	jobHistoryRetention   = 30 * 24 * time.Hour
+)
+
+func NewScheduler() *Scheduler {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &Scheduler{instanceID: fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))}
+}
+
+// Registrar un job y crear (o actualizar) su fila. Si cambia el cron se recalcula
+// la próxima ejecución
+func (s *Scheduler) Register(job Job) error {
+	cron, err := ParseCron(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+
+	now := time.Now().UTC()
+	var row models.ScheduledJob
+	err = config.DB.Where("name = ?", job.Name).First(&row).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		row = models.ScheduledJob{Name: job.Name, Schedule: job.Schedule, NextRunAt: cron.Next(now)}
+		if err := config.DB.Create(&row).Error; err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case row.Schedule != job.Schedule:
+		config.DB.Model(&row).Updates(map[string]interface{}{
+			"schedule":    job.Schedule,
+			"next_run_at": cron.Next(now),
+		})
+	}
+
+	s.jobs = append(s.jobs, &scheduledJob{Job: job, cron: cron})
+	return nil
+}
+
+// Revisar los jobs vencidos cada schedulerPollInterval
+func (s *Scheduler) Start() {
+	go func() {
+		s.tick()
+
+		ticker := time.NewTicker(schedulerPollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.tick()
+		}
+	}()
+
+	log.Printf("Job scheduler started (%d jobs, instance %s)", len(s.jobs), s.instanceID)
+}
+
+func (s *Scheduler) tick() {
+	for _, job := range s.jobs {
+		if row, ok := s.acquire(job.Name); ok {
+			go s.execute(job, row)
+		}
+	}
+}
+
+// Tomar el lease de un job vencido. El UPDATE condicional es atómico: si otra
+// instancia lo tomó primero no afecta filas
+func (s *Scheduler) acquire(name string) (*models.ScheduledJob, bool) {
+	now := time.Now().UTC()
+	result := config.DB.Model(&models.ScheduledJob{}).
+		Where("name = ? AND is_active = true AND next_run_at <= ?", name, now).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Updates(map[string]interface{}{
+			"locked_by":    s.instanceID,
+			"locked_until": now.Add(jobLease),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	var row models.ScheduledJob
+	if err := config.DB.Where("name = ?", name).First(&row).Error; err != nil {
+		return nil, false
+	}
+	return &row, true
+}
+
+// Ejecutar un job con el lease tomado, registrar la ejecución y programar la siguiente
+func (s *Scheduler) execute(job *scheduledJob, row *models.ScheduledJob) {
+	started := time.Now().UTC()
+	run := models.JobRun{
+		JobID:      row.ID,
+		JobName:    row.Name,
+		InstanceID: s.instanceID,
+		Attempt:    row.Failures + 1,
+		Status:     models.JobRunning,
+		StartedAt:  started,
+	}
+	config.DB.Create(&run)
+
+	// Renovar el lease mientras corre
+	done := make(chan struct{})
+	go s.renewLease(row.ID, done)
+
+	err := runJob(job, started)
+	close(done)
+
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(started).Milliseconds()
+
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+		"last_run_at":  started,
+	}
+
+	if err == nil {
+		run.Status = models.JobSucceeded
+		updates["last_status"] = models.JobSucceeded
+		updates["last_error"] = ""
+		updates["failures"] = 0
+		updates["next_run_at"] = job.cron.Next(finished)
+	} else {
+		run.Status = models.JobFailed
+		run.Error = truncateError(err.Error())
+		updates["last_status"] = models.JobFailed
+		updates["last_error"] = run.Error
+
+		// Reintentar con backoff; agotados los reintentos, esperar al siguiente horario
+		next := job.cron.Next(finished)
+		failures := row.Failures + 1
+		if failures <= jobMaxRetries {
+			retry := finished.Add(jobRetryBase << (failures - 1))
+			if retry.Before(next) {
+				next = retry
+			}
+		} else {
+			failures = 0
+		}
+		updates["failures"] = failures
+		updates["next_run_at"] = next
+		log.Printf("Job %s failed (attempt %d), next run at %s: %v", row.Name, run.Attempt, next.Format(time.RFC3339), err)
+	}
+
+	config.DB.Save(&run)
+	config.DB.Model(&models.ScheduledJob{}).Where("id = ? AND locked_by = ?", row.ID, s.instanceID).Updates(updates)
+}
+
+// Ejecutar la función del job convirtiendo un panic en error
+func runJob(job *scheduledJob, now time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(now)
+}
+
+func (s *Scheduler) renewLease(jobID uint, done <-chan struct{}) {
+	ticker := time.NewTicker(jobLease / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			config.DB.Model(&models.ScheduledJob{}).Where("id = ? AND locked_by = ?", jobID, s.instanceID).
+				Update("locked_until", time.Now().UTC().Add(jobLease))
+		}
+	}
+}
+
+// Jobs de la aplicación: recordatorios, registro automático, reintentos y limpieza
+func StartJobs() {
+	reminderService := &ReminderService{}
+	recurringTransactionService := &RecurringTransactionService{}
+	notificationService := &NotificationService{}
+	subscriptionDetector := &SubscriptionDetector{}
+
+	scheduler := NewScheduler()
+	jobs := []Job{
+		// Registrar antes de revisar vencidos para que no figuren como vencidas
+		{Name: "recurring_auto_post", Schedule: "0 * * * *", Run: recurringTransactionService.ProcessAutoGenerate},
+		{Name: "overdue_checks", Schedule: "10 * * * *", Run: func(now time.Time) error {
+			// Revisar los préstamos aunque fallen las recurrentes
+			recurringErr := reminderService.CheckOverdueRecurringTransactions()
+			loanErr := reminderService.CheckOverdueLoans()
+			return errors.Join(recurringErr, loanErr)
+		}},
+		{Name: "deliver_reminders", Schedule: "* * * * *", Run: func(now time.Time) error {
+			return reminderService.DeliverDueReminders(now)
+		}},
+		{Name: "notification_deliveries", Schedule: "* * * * *", Run: func(now time.Time) error {
+			return notificationService.SendPending()
+		}},
+		{Name: "recurring_suggestions", Schedule: "0 4 * * *", Run: subscriptionDetector.RefreshAll},
+		{Name: "cleanup", Schedule: "30 3 * * *", Run: cleanupOldRecords},
+	}
+
+	for _, job := range jobs {
+		if err := scheduler.Register(job); err != nil {
+			log.Printf("Could not register job: %v", err)
+		}
+	}
+	scheduler.Start()
+}
+
+// Borrar historial viejo: ejecuciones de jobs y eventos en tiempo real ya vencidos
+func cleanupOldRecords(now time.Time) error {
+	if err := config.DB.Where("started_at < ?", now.Add(-jobHistoryRetention)).Delete(&models.JobRun{}).Error; err != nil {
+		return err
+	}
+	return config.DB.Where("created_at < ?", now.Add(-eventRetention)).Delete(&models.UserEvent{}).Error
+}
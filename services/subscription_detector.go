@@ -0,0 +1,374 @@
+package services
+
+import (
+	"cuentas-claras/config"
+	"cuentas-claras/models"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SubscriptionDetector struct{}
+
+// Parámetros del análisis
+const (
+	subscriptionHistoryMonths = 25   // Alcanza para ver dos cobros anuales
+	subscriptionMinConfidence = 0.5  // Por debajo no se sugiere
+	subscriptionAmountPercent = 0.15 // Tolerancia de monto dentro de un grupo
+	subscriptionAmountMinimum = 1.0  // Tolerancia mínima absoluta
+)
+
+// Periodo candidato: días esperados entre cobros y tolerancia
+type subscriptionPeriod struct {
+	frequency  string
+	days       float64
+	tolerance  float64
+	minSamples int
+}
+
+var subscriptionPeriods = []subscriptionPeriod{
+	{"weekly", 7, 1, 4},
+	{"monthly", 30.4, 4, 3},
+	{"yearly", 365.25, 12, 2},
+}
+
+// Analizar los gastos del usuario y guardar las sugerencias nuevas o actualizadas.
+// Las aceptadas y descartadas no vuelven a aparecer
+func (sd *SubscriptionDetector) Refresh(userID uint) error {
+	now := time.Now()
+	loc := models.UserLocation(config.DB, userID)
+
+	var transactions []models.Transaction
+	err := config.DB.Where("user_id = ? AND type = ? AND date >= ?", userID, "expense", now.AddDate(0, -subscriptionHistoryMonths, 0)).
+		Where("reference_type IS NULL OR reference_type <> ?", "recurring_transaction").
+		Order("date asc").Find(&transactions).Error
+	if err != nil {
+		return err
+	}
+
+	// Lo que ya está registrado como plantilla no se sugiere
+	var templates []models.RecurringTransaction
+	config.DB.Where("user_id = ? AND is_active = true", userID).Find(&templates)
+	registered := map[string]bool{}
+	for _, recurring := range templates {
+		registered[models.NormalizeDescription(recurring.Description)] = true
+	}
+
+	groups := map[string][]models.Transaction{}
+	for _, transaction := range transactions {
+		name := models.NormalizeDescription(transaction.Description)
+		if name == "" || registered[name] {
+			continue
+		}
+		groups[name] = append(groups[name], transaction)
+	}
+
+	for name, group := range groups {
+		for _, cluster := range clusterByAmount(group) {
+			suggestion, ok := detectSubscription(name, cluster, now, loc)
+			if !ok {
+				continue
+			}
+			suggestion.UserID = userID
+			if err := saveSuggestion(config.DB, suggestion); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Analizar a todos los usuarios (job diario); sigue con los demás si uno falla
+func (sd *SubscriptionDetector) RefreshAll(now time.Time) error {
+	var userIDs []uint
+	if err := config.DB.Model(&models.User{}).Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, userID := range userIDs {
+		if err := sd.Refresh(userID); err != nil {
+			log.Printf("Could not refresh recurring suggestions for user %d: %v", userID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("suggestion refresh failed for %d of %d users", failed, len(userIDs))
+	}
+	return nil
+}
+
+// Sugerencias pendientes del usuario, de mayor a menor confianza
+func (sd *SubscriptionDetector) Pending(userID uint) ([]models.RecurringSuggestion, error) {
+	var suggestions []models.RecurringSuggestion
+	err := config.DB.Preload("Account").Preload("Category").
+		Where("user_id = ? AND status = ?", userID, models.SuggestionPending).
+		Order("confidence desc").Find(&suggestions).Error
+	return suggestions, err
+}
+
+// Sugerencia del usuario que todavía no fue aceptada ni descartada
+func (sd *SubscriptionDetector) FindPending(userID uint, suggestionID uint) (*models.RecurringSuggestion, error) {
+	var suggestion models.RecurringSuggestion
+	if err := config.DB.Where("id = ? AND user_id = ?", suggestionID, userID).First(&suggestion).Error; err != nil {
+		return nil, ErrSuggestionNotFound
+	}
+	if suggestion.Status != models.SuggestionPending {
+		return nil, ErrSuggestionNotPending
+	}
+	return &suggestion, nil
+}
+
+// Aceptar una sugerencia guardando la plantilla ya armada y validada por quien llama.
+// Solo la primera aceptación crea la plantilla
+func (sd *SubscriptionDetector) Accept(suggestion *models.RecurringSuggestion, recurring *models.RecurringTransaction) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := sd.markPending(tx, suggestion, models.SuggestionAccepted); err != nil {
+			return err
+		}
+		if err := tx.Create(recurring).Error; err != nil {
+			return err
+		}
+		return tx.Model(suggestion).Update("recurring_transaction_id", recurring.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	suggestion.RecurringTransactionID = &recurring.ID
+	recurringService := &RecurringTransactionService{}
+	recurringService.refreshReminders(recurring)
+	return nil
+}
+
+// Descartar una sugerencia: el mismo patrón no vuelve a sugerirse
+func (sd *SubscriptionDetector) Dismiss(userID uint, suggestionID uint) (*models.RecurringSuggestion, error) {
+	suggestion, err := sd.FindPending(userID, suggestionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := sd.markPending(config.DB, suggestion, models.SuggestionDismissed); err != nil {
+		return nil, err
+	}
+	return suggestion, nil
+}
+
+// Cambiar el estado solo si sigue pendiente (otra petición pudo ganarle)
+func (sd *SubscriptionDetector) markPending(tx *gorm.DB, suggestion *models.RecurringSuggestion, status string) error {
+	result := tx.Model(&models.RecurringSuggestion{}).
+		Where("id = ? AND status = ?", suggestion.ID, models.SuggestionPending).
+		Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSuggestionNotPending
+	}
+	suggestion.Status = status
+	return nil
+}
+
+// Crear la sugerencia o actualizar su evidencia si sigue pendiente
+func saveSuggestion(db *gorm.DB, suggestion *models.RecurringSuggestion) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"description":    suggestion.Description,
+			"account_id":     suggestion.AccountID,
+			"category_id":    suggestion.CategoryID,
+			"amount":         suggestion.Amount,
+			"confidence":     suggestion.Confidence,
+			"occurrences":    suggestion.Occurrences,
+			"last_date":      suggestion.LastDate,
+			"next_due_date":  suggestion.NextDueDate,
+			"transaction_id": suggestion.TransactionID,
+			"updated_at":     time.Now(),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: "recurring_suggestions", Name: "status"}, Value: models.SuggestionPending},
+		}},
+	}).Create(suggestion).Error
+}
+
+// Separar un grupo por monto: cada transacción va al grupo cuyo promedio esté
+// dentro de la tolerancia (un mismo comercio puede tener dos suscripciones)
+func clusterByAmount(transactions []models.Transaction) [][]models.Transaction {
+	type cluster struct {
+		sum   float64
+		items []models.Transaction
+	}
+	clusters := []*cluster{}
+
+	for _, transaction := range transactions {
+		amount := math.Abs(transaction.Amount)
+		var match *cluster
+		for _, c := range clusters {
+			mean := c.sum / float64(len(c.items))
+			if math.Abs(amount-mean) <= math.Max(mean*subscriptionAmountPercent, subscriptionAmountMinimum) {
+				match = c
+				break
+			}
+		}
+		if match == nil {
+			match = &cluster{}
+			clusters = append(clusters, match)
+		}
+		match.sum += amount
+		match.items = append(match.items, transaction)
+	}
+
+	result := make([][]models.Transaction, 0, len(clusters))
+	for _, c := range clusters {
+		result = append(result, c.items)
+	}
+	return result
+}
+
+// Buscar un periodo regular en las transacciones (ordenadas por fecha). La confianza
+// combina la regularidad de los intervalos, la estabilidad del monto, la cantidad
+// de cobros y si el último es reciente
+func detectSubscription(name string, transactions []models.Transaction, now time.Time, loc *time.Location) (*models.RecurringSuggestion, bool) {
+	if len(transactions) < 2 {
+		return nil, false
+	}
+
+	intervals := make([]float64, 0, len(transactions)-1)
+	for i := 1; i < len(transactions); i++ {
+		intervals = append(intervals, transactions[i].Date.Sub(transactions[i-1].Date).Hours()/24)
+	}
+	typical := median(intervals)
+
+	for _, period := range subscriptionPeriods {
+		if len(transactions) < period.minSamples || math.Abs(typical-period.days) > period.tolerance {
+			continue
+		}
+
+		regular := 0
+		for _, days := range intervals {
+			if math.Abs(days-period.days) <= period.tolerance {
+				regular++
+			}
+		}
+		regularity := float64(regular) / float64(len(intervals))
+
+		amounts := make([]float64, len(transactions))
+		for i, transaction := range transactions {
+			amounts[i] = math.Abs(transaction.Amount)
+		}
+		amount := median(amounts)
+		stability := math.Max(0, 1-coefficientOfVariation(amounts))
+
+		samples := math.Min(1, float64(len(transactions))/float64(period.minSamples+2))
+
+		last := transactions[len(transactions)-1]
+		recency := 1.0
+		if now.Sub(last.Date).Hours()/24 > period.days*1.5+period.tolerance {
+			recency = 0.5 // Probablemente se canceló
+		}
+
+		confidence := models.RoundMoney(regularity * stability * (0.5 + 0.5*samples) * recency)
+		if confidence < subscriptionMinConfidence {
+			return nil, false
+		}
+
+		return &models.RecurringSuggestion{
+			Key:           fmt.Sprintf("%s|%s|%.0f", name, period.frequency, amount),
+			Description:   suggestionDescription(last.Description),
+			AccountID:     last.AccountID,
+			CategoryID:    mostCommonCategory(transactions),
+			Amount:        models.RoundMoney(amount),
+			Frequency:     period.frequency,
+			Confidence:    confidence,
+			Occurrences:   len(transactions),
+			LastDate:      last.Date,
+			NextDueDate:   nextSuggestedDueDate(last.Date, period.frequency, now, loc),
+			TransactionID: last.ID,
+			Status:        models.SuggestionPending,
+		}, true
+	}
+
+	return nil, false
+}
+
+// Siguiente cobro esperado después del último, sin quedar en el pasado
+func nextSuggestedDueDate(last time.Time, frequency string, now time.Time, loc *time.Location) time.Time {
+	next := last
+	for next.Equal(last) || models.DaysPastDue(next, now, loc) > 0 {
+		switch frequency {
+		case "weekly":
+			next = next.AddDate(0, 0, 7)
+		case "yearly":
+			next = next.AddDate(1, 0, 0)
+		default:
+			next = next.AddDate(0, 1, 0)
+		}
+	}
+	return next
+}
+
+// Descripción para la plantilla: la última, sin los números de referencia que cambian
+// en cada cobro ("NETFLIX.COM 4829" -> "NETFLIX.COM")
+func suggestionDescription(description string) string {
+	words := []string{}
+	for _, word := range strings.Fields(description) {
+		if strings.IndexFunc(word, unicode.IsLetter) >= 0 {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return description
+	}
+	return strings.Join(words, " ")
+}
+
+func mostCommonCategory(transactions []models.Transaction) *uint {
+	counts := map[uint]int{}
+	var best *uint
+	for _, transaction := range transactions {
+		if transaction.CategoryID == nil {
+			continue
+		}
+		id := *transaction.CategoryID
+		counts[id]++
+		if best == nil || counts[id] > counts[*best] {
+			best = &id
+		}
+	}
+	return best
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// Desviación estándar relativa al promedio (0 si todos los montos son iguales)
+func coefficientOfVariation(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance/float64(len(values))) / mean
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"os"
 	"time"
 
@@ -57,3 +59,62 @@ func ValidateAccessToken(tokenString string) (*jwt.Token, error) {
 		return []byte(os.Getenv("JWT_SECRET")), nil
 	})
 }
+
+// Generar token firmado para compartir un préstamo (expira en expiresAt)
+func GenerateLoanShareToken(shareID string, loanID uint, expiresAt time.Time) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["share_id"] = shareID
+	claims["loan_id"] = loanID
+	claims["type"] = "loan_share"
+	claims["exp"] = expiresAt.Unix()
+
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// Verificar la firma de un token de préstamo compartido y devolver su share_id.
+// La expiración y revocación se controlan con el registro en BD, así también
+// se pueden registrar los accesos con tokens vencidos
+func ValidateLoanShareToken(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return "", errors.New("invalid share token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["type"] != "loan_share" {
+		return "", errors.New("invalid share token")
+	}
+
+	shareID, ok := claims["share_id"].(string)
+	if !ok || shareID == "" {
+		return "", errors.New("invalid share token")
+	}
+	return shareID, nil
+}
+
+// Generar token opaco para el feed de calendario
+func GenerateFeedToken() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Generar secreto para firmar los webhooks de un usuario (HMAC-SHA256)
+func GenerateWebhookSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(bytes), nil
+}
+
+// Hash del token del feed para buscarlo en BD (SHA-256, se puede indexar)
+func HashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}